@@ -2,23 +2,29 @@ package main
 
 import (
 	"app/internal/application"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 )
 
 func main() {
 	// env
-	// ...
+	// - flags take precedence over environment variables, and these over the config file, see -help
+	cfg, err := application.LoadConfigServerChi(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	// app
-	// - config
-	cfg := &application.ConfigServerChi{
-		ServerAddress: ":8080",
-		LoaderFilePath: "docs/db/vehicles_100.json",
-	}
 	app := application.NewServerChi(cfg)
 	// - run
 	if err := app.Run(); err != nil {
-		fmt.Println(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
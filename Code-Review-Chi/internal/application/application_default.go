@@ -1,42 +1,136 @@
 package application
 
 import (
+	"app/internal"
+	"app/internal/eventbus"
 	"app/internal/handler"
 	"app/internal/loader"
 	"app/internal/repository"
 	"app/internal/service"
+	"app/internal/storer"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// StorageMap keeps the vehicles in memory, persisted to a JSON file after every change
+	StorageMap = "map"
+	// StorageSQLite keeps the vehicles in a SQLite database
+	StorageSQLite = "sqlite"
 )
 
 // ConfigServerChi is a struct that represents the configuration for ServerChi
 type ConfigServerChi struct {
 	// ServerAddress is the address where the server will be listening
 	ServerAddress string
-	// LoaderFilePath is the path to the file that contains the vehicles
+	// ReadHeaderTimeout is the maximum duration to read the headers of a request, by default 5s
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout is the maximum duration to read a whole request, body included, by default 1m
+	ReadTimeout time.Duration
+	// WriteTimeout is the maximum duration from the end of the headers of a request to the end
+	// of its response, by default 1m; it also bounds streamed responses such as exports
+	WriteTimeout time.Duration
+	// IdleTimeout is the maximum duration to wait for the next request of a keep-alive connection, by default 2m
+	IdleTimeout time.Duration
+	// ShutdownTimeout is the maximum duration to drain the in-flight requests after SIGINT or SIGTERM,
+	// by default 15s; the connections still open after it are closed
+	ShutdownTimeout time.Duration
+	// LoaderFilePath is the path to the file that contains the vehicles, by default docs/db/vehicles_100.json
 	LoaderFilePath string
+	// LoaderFormat is the format of the file that contains the vehicles: json, csv or yaml
+	// by default it is chosen by the extension of LoaderFilePath
+	LoaderFormat string
+	// StorerFilePath is the path to the file where the vehicles are persisted in JSON format
+	// after every change, for StorageMap; by default it is the same as LoaderFilePath if the vehicles are loaded from JSON
+	StorerFilePath string
+	// Storage is where the vehicles are kept: StorageMap (default) or StorageSQLite
+	Storage string
+	// SQLitePath is the path to the SQLite database, for StorageSQLite
+	// if the database has no vehicles, the vehicles of LoaderFilePath are imported as seed data
+	SQLitePath string
+	// Synonyms are the synonyms of the values of the text fields, by JSON name of the field
+	// e.g. {"fuel_type": {"petrol": "gasoline"}}, by default internal.DefaultVehicleSynonyms
+	Synonyms map[string]map[string]string
+	// EventsReplaySize is the number of the last events of the vehicles kept to replay them to the clients
+	// of GET /vehicles/events that reconnect, by default eventbus.DefaultReplaySize
+	EventsReplaySize int
 }
 
 // NewServerChi is a function that returns a new instance of ServerChi
 func NewServerChi(cfg *ConfigServerChi) *ServerChi {
 	// default values
-	defaultConfig := &ConfigServerChi{
-		ServerAddress: ":8080",
-	}
+	defaultConfig := DefaultConfigServerChi()
 	if cfg != nil {
 		if cfg.ServerAddress != "" {
 			defaultConfig.ServerAddress = cfg.ServerAddress
 		}
+		if cfg.ReadHeaderTimeout != 0 {
+			defaultConfig.ReadHeaderTimeout = cfg.ReadHeaderTimeout
+		}
+		if cfg.ReadTimeout != 0 {
+			defaultConfig.ReadTimeout = cfg.ReadTimeout
+		}
+		if cfg.WriteTimeout != 0 {
+			defaultConfig.WriteTimeout = cfg.WriteTimeout
+		}
+		if cfg.IdleTimeout != 0 {
+			defaultConfig.IdleTimeout = cfg.IdleTimeout
+		}
+		if cfg.ShutdownTimeout != 0 {
+			defaultConfig.ShutdownTimeout = cfg.ShutdownTimeout
+		}
 		if cfg.LoaderFilePath != "" {
 			defaultConfig.LoaderFilePath = cfg.LoaderFilePath
 		}
+		if cfg.LoaderFormat != "" {
+			defaultConfig.LoaderFormat = cfg.LoaderFormat
+		}
+		if cfg.StorerFilePath != "" {
+			defaultConfig.StorerFilePath = cfg.StorerFilePath
+		}
+		if cfg.Storage != "" {
+			defaultConfig.Storage = cfg.Storage
+		}
+		if cfg.SQLitePath != "" {
+			defaultConfig.SQLitePath = cfg.SQLitePath
+		}
+		if cfg.Synonyms != nil {
+			defaultConfig.Synonyms = cfg.Synonyms
+		}
+		if cfg.EventsReplaySize != 0 {
+			defaultConfig.EventsReplaySize = cfg.EventsReplaySize
+		}
 	}
 
 	return &ServerChi{
-		serverAddress:  defaultConfig.ServerAddress,
-		loaderFilePath: defaultConfig.LoaderFilePath,
+		serverAddress:     defaultConfig.ServerAddress,
+		readHeaderTimeout: defaultConfig.ReadHeaderTimeout,
+		readTimeout:       defaultConfig.ReadTimeout,
+		writeTimeout:      defaultConfig.WriteTimeout,
+		idleTimeout:       defaultConfig.IdleTimeout,
+		shutdownTimeout:   defaultConfig.ShutdownTimeout,
+		loaderFilePath:    defaultConfig.LoaderFilePath,
+		loaderFormat:      defaultConfig.LoaderFormat,
+		storerFilePath:    defaultConfig.StorerFilePath,
+		storage:           defaultConfig.Storage,
+		sqlitePath:        defaultConfig.SQLitePath,
+		synonyms:          defaultConfig.Synonyms,
+		eventsReplaySize:  defaultConfig.EventsReplaySize,
 	}
 }
 
@@ -44,30 +138,179 @@ func NewServerChi(cfg *ConfigServerChi) *ServerChi {
 type ServerChi struct {
 	// serverAddress is the address where the server will be listening
 	serverAddress string
+	// readHeaderTimeout, readTimeout, writeTimeout and idleTimeout are the timeouts of the server
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	// shutdownTimeout is the maximum duration to drain the in-flight requests on a signal
+	shutdownTimeout time.Duration
 	// loaderFilePath is the path to the file that contains the vehicles
 	loaderFilePath string
+	// loaderFormat is the format of the file that contains the vehicles, empty to choose it by extension
+	loaderFormat string
+	// storerFilePath is the path to the file where the vehicles are persisted
+	storerFilePath string
+	// storage is where the vehicles are kept
+	storage string
+	// sqlitePath is the path to the SQLite database
+	sqlitePath string
+	// synonyms are the synonyms of the values of the text fields
+	synonyms map[string]map[string]string
+	// eventsReplaySize is the number of the last events of the vehicles kept for replay
+	eventsReplaySize int
+
+	// mu guards the fields of the running server
+	mu sync.Mutex
+	// server is the running server, nil until Run listens
+	server *http.Server
+	// addr is the address the running server listens on, e.g. with the port chosen for ":0"
+	addr string
+	// drained is closed once the in-flight requests of the running server are drained
+	drained chan struct{}
+	// drain closes drained once
+	drain sync.Once
+	// db is the SQLite database of the running server, closed when Run returns
+	db *sql.DB
+	// changes is the change log file of the running server with map storage, closed when Run returns
+	changes *repository.VehicleChangeFile
 }
 
-// Run is a method that runs the application
+// Run is a method that runs the application until Shutdown is called or the process receives SIGINT or SIGTERM
+// on a signal the server stops accepting connections and drains the in-flight requests for up to
+// the shutdown timeout, then closes the connections still open
 func (a *ServerChi) Run() (err error) {
 	// dependencies
-	// - loader
-	ld := loader.NewVehicleJSONFile(a.loaderFilePath)
-	db, err := ld.Load()
+	// - repository
+	nm := internal.NewTextNormalizer(a.synonyms)
+	// - change log
+	//   it is kept next to the vehicles: in a file next to the storer file, or in the SQLite database
+	var rp internal.VehicleRepository
+	var lg internal.VehicleChangeLog
+	switch a.storage {
+	case StorageMap:
+		rp, lg, err = a.newVehicleMap(nm)
+	case StorageSQLite:
+		rp, lg, err = a.newVehicleSQLite(nm)
+	default:
+		err = fmt.Errorf("unknown storage %s, must be %s or %s", a.storage, StorageMap, StorageSQLite)
+	}
+	defer a.close()
 	if err != nil {
 		return
 	}
-	// - repository
-	rp := repository.NewVehicleMap(db)
+	// - event bus
+	//   the events are only kept in memory, so their ids start again on every run
+	bus := eventbus.NewVehicleBus(a.eventsReplaySize)
 	// - service
-	sv := service.NewVehicleDefault(rp)
+	//   every change is recorded in the change log, then published on the event bus, by the observers of the repository
+	//   a change that can not be recorded is still published, and the error of the log is logged
+	sv := service.NewVehicleAudit(service.NewVehicleDefault(rp, nm), lg)
+	rp.SetObserver(internal.VehicleObservers{sv, service.NewVehicleNotifier(bus)})
 	// - handler
-	hd := handler.NewVehicleDefault(sv)
+	hd := handler.NewVehicleDefault(sv, nm)
+	hh := handler.NewVehicleHistory(sv)
+	he := handler.NewVehicleEvents(bus, nm)
 	// router
+	rt := NewRouter(hd, hh, he)
+
+	// server
+	// - signals are handled before the server is reported as running by Addr
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", a.serverAddress)
+	if err != nil {
+		return
+	}
+	srv := &http.Server{
+		Handler:           rt,
+		ReadHeaderTimeout: a.readHeaderTimeout,
+		ReadTimeout:       a.readTimeout,
+		WriteTimeout:      a.writeTimeout,
+		IdleTimeout:       a.idleTimeout,
+	}
+	// - the streams of events never end by themselves, so they are ended for the shutdown to drain them
+	srv.RegisterOnShutdown(bus.Close)
+	a.mu.Lock()
+	a.server, a.addr = srv, ln.Addr().String()
+	a.drained, a.drain = make(chan struct{}), sync.Once{}
+	drained := a.drained
+	a.mu.Unlock()
+
+	// run server
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+
+	select {
+	case err = <-served:
+		// - Shutdown was called, the dependencies are released once it drains the requests
+		if errors.Is(err, http.ErrServerClosed) {
+			<-drained
+			err = nil
+		}
+	case <-signals.Done():
+		// - a second signal terminates the process without waiting for the drain
+		stop()
+		ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		defer cancel()
+		err = a.Shutdown(ctx)
+	}
+	return
+}
+
+// Addr is a method that returns the address the server listens on, or an empty string if it is not running yet
+func (a *ServerChi) Addr() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.addr
+}
+
+// Shutdown is a method that stops the server gracefully: it stops accepting connections and waits
+// for the in-flight requests until ctx is done, then closes the connections still open
+// Run returns once the requests are drained
+func (a *ServerChi) Shutdown(ctx context.Context) (err error) {
+	a.mu.Lock()
+	srv, drained, drain := a.server, a.drained, &a.drain
+	a.mu.Unlock()
+	if srv == nil {
+		return
+	}
+	defer drain.Do(func() { close(drained) })
+
+	if err = srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		err = fmt.Errorf("the in-flight requests were not drained: %w", err)
+	}
+	return
+}
+
+// close is a method that releases the running server and the resources of its dependencies
+func (a *ServerChi) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.server, a.addr = nil, ""
+	if a.db != nil {
+		a.db.Close()
+		a.db = nil
+	}
+	if a.changes != nil {
+		a.changes.Close()
+		a.changes = nil
+	}
+}
+
+// NewRouter is a function that returns the router of the application, with the middlewares and every route of hd, hh and he
+func NewRouter(hd *handler.VehicleDefault, hh *handler.VehicleHistory, he *handler.VehicleEvents) *chi.Mux {
 	rt := chi.NewRouter()
 	// - middlewares
+	rt.Use(middleware.RequestID)
 	rt.Use(middleware.Logger)
 	rt.Use(middleware.Recoverer)
+	rt.Use(handler.Actor)
 	// - endpoints
 	rt.Route("/vehicles", func(rt chi.Router) {
 		// - GET /vehicles
@@ -83,25 +326,138 @@ func (a *ServerChi) Run() (err error) {
 
 		rt.Post("/batch", hd.AddVehicles())
 
+		rt.Post("/import", hd.ImportVehicles())
+
+		rt.Get("/export", hd.ExportVehicles())
+
+		rt.Get("/events", he.Stream())
+
 		rt.Put("/{id}/update_speed", hd.UpdateSpeed())
 
 		rt.Put("/{id}/update_fuel", hd.UpdateFuel())
 
 		rt.Get("/fuel_type/{type}", hd.FindByFuelType())
 
+		rt.Get("/{id}", hd.FindById())
+
+		rt.Get("/registration/{plate}", hd.FindByRegistration())
+
+		rt.Put("/{id}", hd.UpdateVehicle())
+
+		rt.Patch("/{id}", hd.PatchVehicle())
+
 		rt.Delete("/{id}", hd.DeleteVehicle())
 
+		rt.Post("/{id}/restore", hd.RestoreVehicle())
+
+		rt.Get("/{id}/history", hh.GetHistory())
+
 		rt.Get("/transmission/{type}", hd.FindByTransmissionType())
 
 		rt.Get("/average_capacity/brand/{brand}", hd.GetAveragePassengersByBrand())
 
+		rt.Get("/stats", hd.GetStats())
+
+		rt.Get("/enums", hd.GetEnums())
+
 		rt.Get("/dimensions", hd.FindByDimensions())
 
 		rt.Get("/weight", hd.FindByWeightRange())
 
 	})
 
-	// run server
-	err = http.ListenAndServe(a.serverAddress, rt)
+	return rt
+}
+
+// load is a method that returns the vehicles of the loader file, with the enums parsed with the synonyms of nm
+func (a *ServerChi) load(nm *internal.TextNormalizer) (db map[int]internal.Vehicle, err error) {
+	ld, err := loader.NewVehicleLoader(a.loaderFilePath, a.loaderFormat, nm)
+	if err != nil {
+		return
+	}
+
+	return ld.Load()
+}
+
+// newVehicleMap is a method that returns a repository of the vehicles of the loader file, kept in memory,
+// and a change log persisted next to the storer file, e.g. vehicles.changes.ndjson for vehicles.json
+func (a *ServerChi) newVehicleMap(nm *internal.TextNormalizer) (rp internal.VehicleRepository, lg internal.VehicleChangeLog, err error) {
+	// - loader
+	db, err := a.load(nm)
+	if err != nil {
+		return
+	}
+	// - storer
+	//   the vehicles are stored as JSON, so by default they are only written back to a JSON loader file
+	storerFilePath := a.storerFilePath
+	if storerFilePath == "" {
+		format := a.loaderFormat
+		if format == "" {
+			format, _ = loader.Format(a.loaderFilePath)
+		}
+		if !strings.EqualFold(format, loader.FormatJSON) {
+			err = fmt.Errorf("a storer file path is required to persist vehicles loaded from %s", format)
+			return
+		}
+		storerFilePath = a.loaderFilePath
+	}
+	st := storer.NewVehicleJSONFile(storerFilePath)
+	// - change log
+	changes, err := repository.NewVehicleChangeFile(strings.TrimSuffix(storerFilePath, filepath.Ext(storerFilePath)) + ".changes.ndjson")
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	a.changes = changes
+	a.mu.Unlock()
+
+	rp = repository.NewVehicleFile(repository.NewVehicleMap(db, nm), st)
+	lg = changes
+	return
+}
+
+// newVehicleSQLite is a method that returns a repository of the vehicles of the SQLite database and its change log
+// the schema is migrated, and the vehicles of the loader file (if any) are imported when the database is empty
+func (a *ServerChi) newVehicleSQLite(nm *internal.TextNormalizer) (rp internal.VehicleRepository, lg internal.VehicleChangeLog, err error) {
+	if a.sqlitePath == "" {
+		err = fmt.Errorf("a sqlite path is required for %s storage", StorageSQLite)
+		return
+	}
+	// the setup is not bound to any request
+	ctx := context.Background()
+	// - database
+	//   a single connection serializes the writes, and busy_timeout waits for other processes
+	db, err := sql.Open("sqlite", a.sqlitePath)
+	if err != nil {
+		return
+	}
+	db.SetMaxOpenConns(1)
+	a.mu.Lock()
+	a.db = db
+	a.mu.Unlock()
+	if _, err = db.ExecContext(ctx, "PRAGMA busy_timeout = 5000"); err != nil {
+		return
+	}
+	if err = repository.MigrateSQLite(ctx, db); err != nil {
+		return
+	}
+	sq := repository.NewVehicleSQLite(db, nm)
+	//   the keys depend on the synonyms, which may have changed since the last run
+	if err = sq.Rekey(ctx); err != nil {
+		return
+	}
+	// - seed
+	if a.loaderFilePath != "" {
+		var v map[int]internal.Vehicle
+		if v, err = a.load(nm); err != nil {
+			return
+		}
+		if _, err = sq.Seed(ctx, v); err != nil {
+			return
+		}
+	}
+
+	rp = sq
+	lg = repository.NewVehicleChangeSQLite(db)
 	return
 }
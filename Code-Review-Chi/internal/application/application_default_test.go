@@ -0,0 +1,249 @@
+package application_test
+
+import (
+	"app/internal/application"
+	"app/internal/repository/repositorytest"
+	"app/internal/storer"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// run is a function that runs a server on a free port, of the vehicles of repositorytest.Vehicles
+// unless cfg has a loader file path; it returns the server, its base URL and the result of Run
+func run(t *testing.T, cfg application.ConfigServerChi) (app *application.ServerChi, url string, done <-chan error) {
+	if cfg.LoaderFilePath == "" {
+		cfg.LoaderFilePath = filepath.Join(t.TempDir(), "vehicles.json")
+		require.NoError(t, storer.NewVehicleJSONFile(cfg.LoaderFilePath).Store(repositorytest.Vehicles()))
+	}
+	cfg.ServerAddress = "127.0.0.1:0"
+
+	app = application.NewServerChi(&cfg)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- app.Run()
+	}()
+	require.Eventually(t, func() bool { return app.Addr() != "" }, 5*time.Second, 10*time.Millisecond)
+
+	return app, "http://" + app.Addr(), errs
+}
+
+// importSlowly is a function that starts an import whose body is written by the returned writer
+// the response is sent to the returned channel once the body is closed
+func importSlowly(t *testing.T, url string) (body *io.PipeWriter, res <-chan *http.Response) {
+	pr, pw := io.Pipe()
+	responses := make(chan *http.Response, 1)
+	go func() {
+		r, err := http.Post(url+"/vehicles/import", "application/x-ndjson", pr)
+		if err != nil {
+			r = nil
+		}
+		responses <- r
+	}()
+	_, err := fmt.Fprintln(pw, `{"id":5,"brand":"Fiat","model":"Uno","registration":"EEE-555","color":"Grey","year":1995,"passengers":5,"max_speed":150,"fuel_type":"gasoline","transmission":"manual","weight":800}`)
+	require.NoError(t, err)
+
+	return pw, responses
+}
+
+// TestServerChi_Shutdown tests that Shutdown drains the in-flight requests and stops the server
+func TestServerChi_Shutdown(t *testing.T) {
+	t.Run("drains in-flight requests", func(t *testing.T) {
+		// arrange
+		app, url, done := run(t, application.ConfigServerChi{})
+		body, res := importSlowly(t, url)
+		time.Sleep(100 * time.Millisecond)
+
+		// act
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- app.Shutdown(context.Background())
+		}()
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, body.Close())
+
+		// assert
+		r := <-res
+		require.NotNil(t, r)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusCreated, r.StatusCode)
+		require.NoError(t, <-shutdown)
+		require.NoError(t, <-done)
+		require.Empty(t, app.Addr())
+		_, err := http.Get(url + "/vehicles")
+		require.Error(t, err)
+	})
+
+	t.Run("closes requests not drained in time", func(t *testing.T) {
+		// arrange
+		app, url, done := run(t, application.ConfigServerChi{})
+		body, res := importSlowly(t, url)
+		time.Sleep(100 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// act
+		err := app.Shutdown(ctx)
+
+		// assert
+		// - the client only reports the closed connection once it stops sending the body
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, body.Close())
+		require.Nil(t, <-res)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("on SIGTERM", func(t *testing.T) {
+		// arrange
+		_, _, done := run(t, application.ConfigServerChi{ShutdownTimeout: time.Second})
+
+		// act
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+		// assert
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the server did not stop")
+		}
+	})
+}
+
+// TestServerChi_Timeouts tests that a request whose body is not sent in time is cut by the read timeout
+func TestServerChi_Timeouts(t *testing.T) {
+	// arrange
+	app, url, done := run(t, application.ConfigServerChi{ReadTimeout: 100 * time.Millisecond})
+	body, res := importSlowly(t, url)
+
+	// act
+	time.Sleep(300 * time.Millisecond)
+	require.NoError(t, body.Close())
+	r := <-res
+
+	// assert
+	// - the upload can not be read after the timeout, so the import fails as an invalid body
+	require.NotNil(t, r)
+	defer r.Body.Close()
+	require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-done)
+}
+
+// TestServerChi_Events tests that a stream of events outlives the write timeout and is ended by Shutdown
+func TestServerChi_Events(t *testing.T) {
+	// arrange
+	app, url, done := run(t, application.ConfigServerChi{WriteTimeout: 100 * time.Millisecond})
+	res, err := http.Get(url + "/vehicles/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	sc := bufio.NewScanner(res.Body)
+	time.Sleep(300 * time.Millisecond)
+
+	// act
+	r, err := http.Post(url+"/vehicles", "application/json", strings.NewReader(`{"id":5,"brand":"Fiat","model":"Uno","registration":"EEE-555",`+
+		`"color":"Grey","year":1995,"passengers":5,"max_speed":150,"fuel_type":"gasoline","transmission":"manual","weight":800}`))
+	require.NoError(t, err)
+	r.Body.Close()
+	require.Equal(t, http.StatusCreated, r.StatusCode)
+	var lines []string
+	for sc.Scan() && !strings.HasPrefix(sc.Text(), "data: ") {
+		lines = append(lines, sc.Text())
+	}
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- app.Shutdown(context.Background())
+	}()
+
+	// assert
+	require.Contains(t, lines, "event: created")
+	_, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, <-shutdown)
+	require.NoError(t, <-done)
+}
+
+// TestServerChi_History tests that with map storage the changes of the vehicles are kept after a restart
+func TestServerChi_History(t *testing.T) {
+	// arrange
+	path := filepath.Join(t.TempDir(), "vehicles.json")
+	require.NoError(t, storer.NewVehicleJSONFile(path).Store(repositorytest.Vehicles()))
+	app, url, done := run(t, application.ConfigServerChi{LoaderFilePath: path})
+	req, err := http.NewRequest(http.MethodPut, url+"/vehicles/1/update_speed", strings.NewReader(`{"max_speed":120}`))
+	require.NoError(t, err)
+	req.Header.Set("If-Match", `"1"`)
+	r, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	r.Body.Close()
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-done)
+
+	// act
+	app, url, done = run(t, application.ConfigServerChi{LoaderFilePath: path})
+	r, err = http.Get(url + "/vehicles/1/history")
+	require.NoError(t, err)
+	defer r.Body.Close()
+
+	// assert
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	var body struct {
+		Data []struct {
+			Operation string `json:"operation"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	require.Len(t, body.Data, 1)
+	require.Equal(t, "update", body.Data[0].Operation)
+	require.FileExists(t, filepath.Join(filepath.Dir(path), "vehicles.changes.ndjson"))
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-done)
+}
+
+// TestServerChi_Synonyms tests that the configured synonyms are accepted by the writes as they are by the filters
+func TestServerChi_Synonyms(t *testing.T) {
+	// arrange
+	app, url, done := run(t, application.ConfigServerChi{Synonyms: map[string]map[string]string{"fuel_type": {"nafta": "gasoline"}}})
+	body := `{"id":5,"brand":"Fiat","model":"Uno","registration":"EEE-555","color":"Grey","year":1995,"passengers":5,` +
+		`"max_speed":150,"fuel_type":"Nafta","transmission":"manual","weight":800}`
+
+	// act
+	created, err := http.Post(url+"/vehicles", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	created.Body.Close()
+	found, err := http.Get(url + "/vehicles/5")
+	require.NoError(t, err)
+	defer found.Body.Close()
+	filtered, err := http.Get(url + "/vehicles/fuel_type/nafta")
+	require.NoError(t, err)
+	defer filtered.Body.Close()
+
+	// assert
+	require.Equal(t, http.StatusCreated, created.StatusCode)
+	require.Equal(t, http.StatusOK, found.StatusCode)
+	var vehicle struct {
+		Data struct {
+			FuelType string `json:"fuel_type"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(found.Body).Decode(&vehicle))
+	require.Equal(t, "gasoline", vehicle.Data.FuelType)
+	require.Equal(t, http.StatusOK, filtered.StatusCode)
+	var vehicles struct {
+		Data map[string]any `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(filtered.Body).Decode(&vehicles))
+	require.Contains(t, vehicles.Data, "5")
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-done)
+}
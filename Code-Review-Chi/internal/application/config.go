@@ -0,0 +1,123 @@
+package application
+
+import (
+	"app/internal/eventbus"
+	"app/internal/loader"
+	"appconfig"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidConfig is an error that represents a configuration the server can not run with
+	ErrInvalidConfig = appconfig.ErrInvalidConfig
+)
+
+// DefaultConfigServerChi is a function that returns the configuration used for the settings that are not given
+func DefaultConfigServerChi() *ConfigServerChi {
+	return &ConfigServerChi{
+		ServerAddress:     ":8080",
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   15 * time.Second,
+		LoaderFilePath:    "docs/db/vehicles_100.json",
+		Storage:           StorageMap,
+		EventsReplaySize:  eventbus.DefaultReplaySize,
+	}
+}
+
+// configOptions are the settings of ConfigServerChi
+var configOptions = []appconfig.Option[*ConfigServerChi]{
+	appconfig.Text("server_address", "address where the server listens, e.g. :8080", func(cfg *ConfigServerChi) *string { return &cfg.ServerAddress }),
+	appconfig.Duration("read_header_timeout", "maximum duration to read the headers of a request", func(cfg *ConfigServerChi) *time.Duration { return &cfg.ReadHeaderTimeout }),
+	appconfig.Duration("read_timeout", "maximum duration to read a request", func(cfg *ConfigServerChi) *time.Duration { return &cfg.ReadTimeout }),
+	appconfig.Duration("write_timeout", "maximum duration to write a response", func(cfg *ConfigServerChi) *time.Duration { return &cfg.WriteTimeout }),
+	appconfig.Duration("idle_timeout", "maximum duration of an idle keep-alive connection", func(cfg *ConfigServerChi) *time.Duration { return &cfg.IdleTimeout }),
+	appconfig.Duration("shutdown_timeout", "maximum duration to drain the requests on SIGINT or SIGTERM", func(cfg *ConfigServerChi) *time.Duration { return &cfg.ShutdownTimeout }),
+	appconfig.Text("loader_file_path", "path to the file of the vehicles", func(cfg *ConfigServerChi) *string { return &cfg.LoaderFilePath }),
+	appconfig.Text("loader_format", "format of the file of the vehicles: json, csv or yaml (default by extension)", func(cfg *ConfigServerChi) *string { return &cfg.LoaderFormat }),
+	appconfig.Text("storer_file_path", "path to the JSON file where the vehicles are persisted, for map storage", func(cfg *ConfigServerChi) *string { return &cfg.StorerFilePath }),
+	appconfig.Text("storage", "where the vehicles are kept: map or sqlite", func(cfg *ConfigServerChi) *string { return &cfg.Storage }),
+	appconfig.Text("sqlite_path", "path to the SQLite database, for sqlite storage", func(cfg *ConfigServerChi) *string { return &cfg.SQLitePath }),
+	appconfig.Int("events_replay_size", "number of the last vehicle events kept to replay them on reconnect", func(cfg *ConfigServerChi) *int { return &cfg.EventsReplaySize }),
+	// - synonyms are only read from the config file, as an object by field, e.g. {"fuel_type": {"petrol": "gasoline"}}
+	{Key: "synonyms", Decode: func(cfg *ConfigServerChi, raw json.RawMessage) error {
+		if json.Unmarshal(raw, &cfg.Synonyms) != nil {
+			return errors.New("must be an object of objects of strings by field")
+		}
+		return nil
+	}},
+}
+
+// LoadConfigServerChi is a function that returns the configuration given by the command line args,
+// the environment variables looked up with lookupEnv and an optional config file, over DefaultConfigServerChi (see appconfig.Load)
+// durations in the config file are strings such as "30s"
+func LoadConfigServerChi(args []string, lookupEnv func(key string) (string, bool)) (cfg *ConfigServerChi, err error) {
+	cfg = DefaultConfigServerChi()
+	if err = appconfig.Load(cfg, "vehicles", configOptions, args, lookupEnv); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Validate is a method that returns an error reporting every setting the server can not run with
+func (c *ConfigServerChi) Validate() (err error) {
+	var errs []error
+	invalid := func(key string, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, key, fmt.Sprintf(format, a...)))
+	}
+
+	// server
+	errs = append(errs, appconfig.ValidateAddress("server_address", c.ServerAddress))
+	timeouts := []struct {
+		key string
+		d   time.Duration
+	}{
+		{"read_header_timeout", c.ReadHeaderTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			invalid(t.key, "must not be negative")
+		}
+	}
+
+	// events
+	if c.EventsReplaySize < 0 {
+		invalid("events_replay_size", "must not be negative")
+	}
+
+	// storage
+	switch c.Storage {
+	case StorageMap:
+		if c.LoaderFilePath == "" {
+			invalid("loader_file_path", "is required for %s storage", StorageMap)
+		}
+	case StorageSQLite:
+		if c.SQLitePath == "" {
+			invalid("sqlite_path", "is required for %s storage", StorageSQLite)
+		}
+	default:
+		invalid("storage", "%q must be %s or %s", c.Storage, StorageMap, StorageSQLite)
+	}
+
+	// loader
+	switch strings.ToLower(c.LoaderFormat) {
+	case "", loader.FormatJSON, loader.FormatCSV, loader.FormatYAML:
+	default:
+		invalid("loader_format", "%q must be %s, %s or %s", c.LoaderFormat, loader.FormatJSON, loader.FormatCSV, loader.FormatYAML)
+	}
+	if c.LoaderFilePath != "" {
+		errs = append(errs, appconfig.ValidateFile("loader_file_path", c.LoaderFilePath))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,103 @@
+package application_test
+
+import (
+	"app/internal/application"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env is a function that returns a lookup of the variables of vars
+func env(vars map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+// TestLoadConfigServerChi tests the precedence and validation of the sources of the configuration
+func TestLoadConfigServerChi(t *testing.T) {
+	// vehicles is a file the loader path can point to
+	vehicles := filepath.Join(t.TempDir(), "vehicles.json")
+	require.NoError(t, os.WriteFile(vehicles, []byte("[]"), 0o644))
+	// file is a config file
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"server_address": ":9001",
+		"read_timeout": "10s",
+		"write_timeout": "20s",
+		"loader_file_path": "`+vehicles+`",
+		"synonyms": {"color": {"rojo": "red"}}
+	}`), 0o644))
+
+	t.Run("defaults", func(t *testing.T) {
+		// arrange
+		expected := application.DefaultConfigServerChi()
+		expected.LoaderFilePath = vehicles
+
+		// act
+		cfg, err := application.LoadConfigServerChi([]string{"-loader-file-path", vehicles}, env(nil))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, expected, cfg)
+	})
+
+	t.Run("flags over environment over file", func(t *testing.T) {
+		// arrange
+		args := []string{"-config", file, "-server-address", ":9003"}
+		vars := map[string]string{"SERVER_ADDRESS": ":9002", "READ_TIMEOUT": "15s", "IDLE_TIMEOUT": "", "EVENTS_REPLAY_SIZE": "50"}
+
+		// act
+		cfg, err := application.LoadConfigServerChi(args, env(vars))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, ":9003", cfg.ServerAddress)
+		require.Equal(t, 15*time.Second, cfg.ReadTimeout)
+		require.Equal(t, 20*time.Second, cfg.WriteTimeout)
+		require.Equal(t, 2*time.Minute, cfg.IdleTimeout)
+		require.Equal(t, 50, cfg.EventsReplaySize)
+		require.Equal(t, vehicles, cfg.LoaderFilePath)
+		require.Equal(t, map[string]map[string]string{"color": {"rojo": "red"}}, cfg.Synonyms)
+	})
+
+	t.Run("config file from the environment", func(t *testing.T) {
+		// act
+		cfg, err := application.LoadConfigServerChi(nil, env(map[string]string{"CONFIG_FILE": file}))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, ":9001", cfg.ServerAddress)
+	})
+
+	t.Run("every invalid setting is reported", func(t *testing.T) {
+		// arrange
+		bad := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(bad, []byte(`{"storage": "sqlite", "timeout": "1s", "idle_timeout": 5}`), 0o644))
+		args := []string{"-config", bad, "-read-timeout", "5", "-loader-file-path", "missing.json"}
+		vars := map[string]string{"SERVER_ADDRESS": "localhost", "LOADER_FORMAT": "xml", "EVENTS_REPLAY_SIZE": "-1"}
+
+		// act
+		cfg, err := application.LoadConfigServerChi(args, env(vars))
+
+		// assert
+		require.Nil(t, cfg)
+		require.ErrorIs(t, err, application.ErrInvalidConfig)
+		for _, problem := range []string{
+			bad + ": idle_timeout: must be a string",
+			bad + ": timeout: unknown setting",
+			"-read-timeout: time: missing unit",
+			"server_address: \"localhost\" must be host:port",
+			"sqlite_path: is required for sqlite storage",
+			"loader_format: \"xml\" must be json, csv or yaml",
+			"events_replay_size: must not be negative",
+			"loader_file_path: stat missing.json",
+		} {
+			require.Contains(t, err.Error(), problem)
+		}
+	})
+}
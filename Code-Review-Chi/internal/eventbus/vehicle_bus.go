@@ -0,0 +1,125 @@
+package eventbus
+
+import (
+	"app/internal"
+	"sync"
+)
+
+const (
+	// DefaultReplaySize is the number of events kept for replay when none is given
+	DefaultReplaySize = 1000
+	// subscriberBuffer is the number of events a subscriber may fall behind before it is dropped
+	subscriberBuffer = 64
+)
+
+// NewVehicleBus is a function that returns a new instance of VehicleBus
+// replaySize is the number of events kept for replay, DefaultReplaySize if it is not positive
+func NewVehicleBus(replaySize int) *VehicleBus {
+	// default values
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+
+	return &VehicleBus{
+		replaySize:  replaySize,
+		subscribers: make(map[int]chan internal.VehicleEvent),
+	}
+}
+
+// VehicleBus is a struct that implements the internal.VehicleEventBus interface in memory
+// the last events published are kept in a bounded buffer to replay them, so the ids of the events
+// start again from 1 on every run; it is safe for concurrent use by multiple goroutines
+type VehicleBus struct {
+	// mu guards the fields of the bus
+	mu sync.Mutex
+	// lastId is the id of the last event published
+	lastId int
+	// replay are the last events published, oldest first, up to replaySize
+	replay     []internal.VehicleEvent
+	replaySize int
+	// subscribers are the channels of the subscribers, by subscription
+	subscribers map[int]chan internal.VehicleEvent
+	// lastSubscription is the last subscription made
+	lastSubscription int
+	// closed is true once the bus is closed
+	closed bool
+}
+
+// Publish is a method that sends an event to every subscriber, returning it with the id set by the bus
+// a subscriber whose channel is full is dropped instead of blocking the publisher
+func (b *VehicleBus) Publish(e internal.VehicleEvent) (published internal.VehicleEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	e.Id = b.lastId
+	if len(b.replay) == b.replaySize {
+		copy(b.replay, b.replay[1:])
+		b.replay = b.replay[:len(b.replay)-1]
+	}
+	b.replay = append(b.replay, e)
+
+	for sub, ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			close(ch)
+			delete(b.subscribers, sub)
+		}
+	}
+
+	return e
+}
+
+// Subscribe is a method that returns the events after the one with id lastId still kept for replay,
+// and a channel of the events published from then on
+// a lastId after the last event published, e.g. of a previous run, replays every event kept
+// the channel of a closed bus is closed
+func (b *VehicleBus) Subscribe(lastId int) (replay []internal.VehicleEvent, events <-chan internal.VehicleEvent, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastId > 0 {
+		if lastId > b.lastId {
+			lastId = 0
+		}
+		for _, e := range b.replay {
+			if e.Id > lastId {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	ch := make(chan internal.VehicleEvent, subscriberBuffer)
+	if b.closed {
+		close(ch)
+		return replay, ch, func() {}
+	}
+	b.lastSubscription++
+	sub := b.lastSubscription
+	b.subscribers[sub] = ch
+
+	unsubscribe = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if ch, ok := b.subscribers[sub]; ok {
+			close(ch)
+			delete(b.subscribers, sub)
+		}
+	}
+	return replay, ch, unsubscribe
+}
+
+// Close is a method that closes the channel of every subscriber, e.g. to end their streams on shutdown
+// the events published after it are still kept for replay
+func (b *VehicleBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub, ch := range b.subscribers {
+		close(ch)
+		delete(b.subscribers, sub)
+	}
+}
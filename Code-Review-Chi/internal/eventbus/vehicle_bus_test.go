@@ -0,0 +1,134 @@
+package eventbus_test
+
+import (
+	"app/internal"
+	"app/internal/eventbus"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// publish is a function that publishes n events of vehicles 1 to n on the bus
+func publish(bus *eventbus.VehicleBus, n int) {
+	for i := 1; i <= n; i++ {
+		bus.Publish(internal.VehicleEvent{Type: internal.EventCreated, Vehicle: internal.Vehicle{Id: i}})
+	}
+}
+
+// eventIds is a function that returns the ids of the events
+func eventIds(events []internal.VehicleEvent) []int {
+	ids := make([]int, len(events))
+	for i, e := range events {
+		ids[i] = e.Id
+	}
+	return ids
+}
+
+// TestVehicleBus_Publish tests that the events are sent to the subscribers with increasing ids
+func TestVehicleBus_Publish(t *testing.T) {
+	// arrange
+	bus := eventbus.NewVehicleBus(10)
+	_, first, unsubscribe := bus.Subscribe(0)
+	defer unsubscribe()
+	_, second, unsubscribe := bus.Subscribe(0)
+	defer unsubscribe()
+
+	// act
+	published := bus.Publish(internal.VehicleEvent{Type: internal.EventUpdated, Vehicle: internal.Vehicle{Id: 7}})
+
+	// assert
+	require.Equal(t, 1, published.Id)
+	require.Equal(t, published, <-first)
+	require.Equal(t, published, <-second)
+	require.Equal(t, 2, bus.Publish(internal.VehicleEvent{Type: internal.EventDeleted}).Id)
+}
+
+// TestVehicleBus_Subscribe tests the replay of the events kept by the bus
+func TestVehicleBus_Subscribe(t *testing.T) {
+	cases := []struct {
+		name     string
+		lastId   int
+		expected []int
+	}{
+		{name: "without last id", lastId: 0, expected: []int{}},
+		{name: "after last id", lastId: 4, expected: []int{5, 6}},
+		{name: "last id older than the events kept", lastId: 1, expected: []int{3, 4, 5, 6}},
+		{name: "last event", lastId: 6, expected: []int{}},
+		{name: "last id of a previous run", lastId: 99, expected: []int{3, 4, 5, 6}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			bus := eventbus.NewVehicleBus(4)
+			publish(bus, 6)
+
+			// act
+			replay, events, unsubscribe := bus.Subscribe(c.lastId)
+			defer unsubscribe()
+
+			// assert
+			require.Equal(t, c.expected, eventIds(replay))
+			bus.Publish(internal.VehicleEvent{Type: internal.EventCreated})
+			require.Equal(t, 7, (<-events).Id)
+		})
+	}
+}
+
+// TestVehicleBus_Unsubscribe tests the subscribers whose channel is closed
+func TestVehicleBus_Unsubscribe(t *testing.T) {
+	t.Run("unsubscribe", func(t *testing.T) {
+		// arrange
+		bus := eventbus.NewVehicleBus(0)
+		_, events, unsubscribe := bus.Subscribe(0)
+
+		// act
+		unsubscribe()
+		unsubscribe()
+		publish(bus, 1)
+
+		// assert
+		_, open := <-events
+		require.False(t, open)
+	})
+
+	t.Run("subscriber that does not keep up", func(t *testing.T) {
+		// arrange
+		bus := eventbus.NewVehicleBus(0)
+		_, slow, unsubscribe := bus.Subscribe(0)
+		defer unsubscribe()
+
+		// act
+		publish(bus, 100)
+
+		// assert
+		// - the events sent before it was dropped are still received, then the channel is closed
+		received := 0
+		for range slow {
+			received++
+		}
+		require.Positive(t, received)
+		require.Less(t, received, 100)
+		replay, _, unsubscribe := bus.Subscribe(received)
+		defer unsubscribe()
+		require.Len(t, replay, 100-received)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		// arrange
+		bus := eventbus.NewVehicleBus(0)
+		_, before, _ := bus.Subscribe(0)
+
+		// act
+		bus.Close()
+		publish(bus, 1)
+		replay, after, _ := bus.Subscribe(0)
+
+		// assert
+		_, open := <-before
+		require.False(t, open)
+		_, open = <-after
+		require.False(t, open)
+		require.Empty(t, replay)
+	})
+}
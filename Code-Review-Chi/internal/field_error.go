@@ -0,0 +1,31 @@
+package internal
+
+// NewFieldError is a function that returns a new instance of FieldError
+func NewFieldError(err error, field string, message string) *FieldError {
+	return &FieldError{
+		Err:     err,
+		Field:   field,
+		Message: message,
+	}
+}
+
+// FieldError is a struct that represents an error caused by a single field
+// it wraps the sentinel error that describes the failure, so errors.Is keeps working
+type FieldError struct {
+	// Err is the sentinel error, e.g. ErrFieldRequired
+	Err error
+	// Field is the JSON name of the field, e.g. "passengers"
+	Field string
+	// Message describes the failure
+	Message string
+}
+
+// Error is a method that returns the error message
+func (e *FieldError) Error() string {
+	return e.Err.Error() + ": " + e.Message
+}
+
+// Unwrap is a method that returns the sentinel error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
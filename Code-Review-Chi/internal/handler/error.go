@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"app/internal"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bootcamp-go/web/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var (
+	// errInvalidParam is returned when a route or query param is missing or malformed
+	errInvalidParam = errors.New("invalid param")
+	// errInvalidBody is returned when the body of the request can not be decoded
+	errInvalidBody = errors.New("invalid body")
+	// errBodyTooLarge is returned when the body of the request is larger than the route accepts
+	errBodyTooLarge = errors.New("body too large")
+	// errUnsupportedMediaType is returned when the body of the request has a content type the route does not accept
+	errUnsupportedMediaType = errors.New("unsupported media type")
+	// errPreconditionRequired is returned when a change of a vehicle is requested without the If-Match header
+	errPreconditionRequired = errors.New("precondition required")
+)
+
+// statusClientClosedRequest is the status of a request the client canceled before it was answered
+// the client does not read it, but it is logged and seen by the middlewares
+const statusClientClosedRequest = 499
+
+// ErrorJSON is a struct that represents an error in JSON format
+type ErrorJSON struct {
+	// Code is a stable, machine readable identifier of the error, e.g. "vehicle_not_found"
+	Code string `json:"code"`
+	// Message is a human readable description of the error
+	Message string `json:"message"`
+	// Field is the JSON name of the field or param that caused the error, if any
+	Field string `json:"field,omitempty"`
+	// RequestID is the id of the request, see middleware.RequestID
+	RequestID string `json:"request_id,omitempty"`
+	// Violations are the invalid fields of a request that failed validation
+	Violations []ViolationJSON `json:"violations,omitempty"`
+}
+
+// ViolationJSON is a struct that represents a validation rule broken by a field in JSON format
+type ViolationJSON struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// errorStatus is a struct that represents the HTTP status and code of an error
+type errorStatus struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorStatuses maps the known errors to their HTTP status and code
+// errors are checked in order with errors.Is, unknown errors are internal errors
+var errorStatuses = []errorStatus{
+	{internal.ErrVehicleNotFound, http.StatusNotFound, "vehicle_not_found"},
+	{internal.ErrVehiclesNotFound, http.StatusNotFound, "vehicles_not_found"},
+	{internal.ErrVehicleAlreadyExists, http.StatusConflict, "vehicle_already_exists"},
+	{internal.ErrVehicleRegistrationAlreadyExists, http.StatusConflict, "registration_already_exists"},
+	{internal.ErrVehicleVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+	{internal.ErrVehicleNotRetired, http.StatusConflict, "vehicle_not_retired"},
+	{internal.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{internal.ErrFieldRequired, http.StatusBadRequest, "field_required"},
+	{internal.ErrInvalidFieldEnum, http.StatusBadRequest, "invalid_enum"},
+	{internal.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter"},
+	{internal.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+	{internal.ErrInvalidStats, http.StatusBadRequest, "invalid_stats"},
+	{errInvalidParam, http.StatusBadRequest, "invalid_param"},
+	{errInvalidBody, http.StatusBadRequest, "invalid_body"},
+	{errBodyTooLarge, http.StatusRequestEntityTooLarge, "body_too_large"},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{errPreconditionRequired, http.StatusPreconditionRequired, "precondition_required"},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, "request_timeout"},
+	{context.Canceled, statusClientClosedRequest, "request_canceled"},
+}
+
+// toErrorJSON is a function that maps an error to its HTTP status and JSON representation
+// the message of internal errors is not exposed to the client
+func toErrorJSON(err error) (status int, e ErrorJSON) {
+	status, e = http.StatusInternalServerError, ErrorJSON{Code: "internal_error", Message: "internal server error"}
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.err) {
+			status, e = s.status, ErrorJSON{Code: s.code, Message: err.Error()}
+			break
+		}
+	}
+
+	var fe *internal.FieldError
+	if errors.As(err, &fe) {
+		e.Field = fe.Field
+	}
+
+	var ve *internal.ValidationError
+	if errors.As(err, &ve) {
+		e.Message = internal.ErrValidation.Error()
+		e.Violations = make([]ViolationJSON, len(ve.Violations))
+		for i, v := range ve.Violations {
+			e.Violations[i] = ViolationJSON{Field: v.Field, Rule: v.Rule, Message: v.Message}
+		}
+	}
+
+	return
+}
+
+// writeError is a function that writes err to the response as {"error": ErrorJSON}
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeErrorFields(w, r, err, nil)
+}
+
+// writeErrorFields is a function that writes err to the response as writeError, with fields next to the error,
+// e.g. what a request did before it failed
+func writeErrorFields(w http.ResponseWriter, r *http.Request, err error, fields map[string]any) {
+	status, e := toErrorJSON(err)
+	e.RequestID = middleware.GetReqID(r.Context())
+	if status == http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", e.RequestID, r.Method, r.URL.Path, err)
+	}
+
+	body := map[string]any{
+		"error": e,
+	}
+	for k, v := range fields {
+		body[k] = v
+	}
+	response.JSON(w, status, body)
+}
+
+// paramText is a function that returns the route param name, which is required
+func paramText(r *http.Request, name string) (s string, err error) {
+	s = chi.URLParam(r, name)
+	if s == "" {
+		err = internal.NewFieldError(errInvalidParam, name, name+" is required")
+	}
+	return
+}
+
+// paramInt is a function that returns the route param name as an integer
+func paramInt(r *http.Request, name string) (n int, err error) {
+	n, err = strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		err = internal.NewFieldError(errInvalidParam, name, name+" must be an integer")
+	}
+	return
+}
+
+// queryFloat is a function that returns the query param name as a float
+func queryFloat(r *http.Request, name string) (f float64, err error) {
+	f, err = strconv.ParseFloat(r.URL.Query().Get(name), 64)
+	if err != nil {
+		err = internal.NewFieldError(errInvalidParam, name, name+" must be a number")
+	}
+	return
+}
@@ -6,12 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bootcamp-go/web/request"
 	"github.com/bootcamp-go/web/response"
-	"github.com/go-chi/chi/v5"
 )
 
 // VehicleJSON is a struct that represents a vehicle in JSON format
@@ -30,6 +31,65 @@ type VehicleJSON struct {
 	Height          float64 `json:"height"`
 	Length          float64 `json:"length"`
 	Width           float64 `json:"width"`
+	// Version is the version of the vehicle, also sent as its ETag; it is ignored in a request
+	Version int `json:"version,omitempty"`
+	// RetiredAt is when the vehicle was retired, only for retired vehicles; it is ignored in a request
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+// toVehicleJSON is a function that serializes a vehicle to its JSON representation
+func toVehicleJSON(v internal.Vehicle) VehicleJSON {
+	return VehicleJSON{
+		ID:              v.Id,
+		Version:         v.Version,
+		Brand:           v.Brand,
+		Model:           v.Model,
+		Registration:    v.Registration,
+		Color:           v.Color,
+		FabricationYear: v.FabricationYear,
+		Capacity:        v.Capacity,
+		MaxSpeed:        v.MaxSpeed,
+		FuelType:        string(v.FuelType),
+		Transmission:    string(v.Transmission),
+		Weight:          v.Weight,
+		Height:          v.Height,
+		Length:          v.Length,
+		Width:           v.Width,
+		RetiredAt:       retiredAt(v),
+	}
+}
+
+// retiredAt is a function that returns when the vehicle was retired, or nil if it is in service
+func retiredAt(v internal.Vehicle) *time.Time {
+	if !v.Retired() {
+		return nil
+	}
+	t := v.RetiredAt.UTC()
+	return &t
+}
+
+// toVehicle is a method that deserializes the JSON representation to a vehicle
+func (v VehicleJSON) toVehicle() internal.Vehicle {
+	return internal.Vehicle{
+		Id: v.ID,
+		VehicleAttributes: internal.VehicleAttributes{
+			Brand:           v.Brand,
+			Model:           v.Model,
+			Registration:    v.Registration,
+			Color:           v.Color,
+			FabricationYear: v.FabricationYear,
+			Capacity:        v.Capacity,
+			MaxSpeed:        v.MaxSpeed,
+			FuelType:        internal.FuelType(v.FuelType),
+			Transmission:    internal.Transmission(v.Transmission),
+			Weight:          v.Weight,
+			Dimensions: internal.Dimensions{
+				Height: v.Height,
+				Length: v.Length,
+				Width:  v.Width,
+			},
+		},
+	}
 }
 
 type UpdateSpeedJSON struct {
@@ -41,14 +101,22 @@ type UpdateFuelJSON struct {
 }
 
 // NewVehicleDefault is a function that returns a new instance of VehicleDefault
-func NewVehicleDefault(sv internal.VehicleService) *VehicleDefault {
-	return &VehicleDefault{sv: sv}
+// nm is the normalizer the fuel type and transmission of the imports are parsed with, by default one with internal.DefaultVehicleSynonyms
+func NewVehicleDefault(sv internal.VehicleService, nm *internal.TextNormalizer) *VehicleDefault {
+	// default values
+	if nm == nil {
+		nm = internal.NewTextNormalizer(nil)
+	}
+
+	return &VehicleDefault{sv: sv, nm: nm}
 }
 
 // VehicleDefault is a struct with methods that represent handlers for vehicles
 type VehicleDefault struct {
 	// sv is the service that will be used by the handler
 	sv internal.VehicleService
+	// nm is the normalizer the fuel type and transmission of the imports are parsed with
+	nm *internal.TextNormalizer
 }
 
 type DimensionQueryParams struct {
@@ -80,43 +148,150 @@ func parseRange(rangeStr string) (min float64, max float64, err error) {
 	return
 }
 
+// parseVehicleFilter is a function that builds a vehicle filter from query params
+// - field=value matches the field exactly
+// - field=min-max matches a numeric field in the inclusive range
+// - field_gte=value and field_lte=value bound a numeric field
+func parseVehicleFilter(query url.Values) (f internal.VehicleFilter, err error) {
+	for key, values := range query {
+		field, op := key, internal.FilterEq
+		switch {
+		case strings.HasSuffix(key, "_gte"):
+			field, op = strings.TrimSuffix(key, "_gte"), internal.FilterGte
+		case strings.HasSuffix(key, "_lte"):
+			field, op = strings.TrimSuffix(key, "_lte"), internal.FilterLte
+		}
+
+		for _, value := range values {
+			// numeric range
+			if op == internal.FilterEq && internal.IsNumberField(field) && strings.Contains(value, "-") {
+				min, max, err := parseRange(value)
+				if err != nil {
+					return nil, internal.NewFieldError(internal.ErrInvalidFilter, field, fmt.Sprintf("%s: %v", key, err))
+				}
+				f = append(f, internal.NumberGte(field, min), internal.NumberLte(field, max))
+				continue
+			}
+
+			c, err := internal.NewVehicleCondition(field, op, value)
+			if err != nil {
+				return nil, err
+			}
+			f = append(f, c)
+		}
+	}
+
+	return
+}
+
+const (
+	// defaultPageLimit is the page size used when the request does not set a limit
+	defaultPageLimit = 50
+	// maxPageLimit is the maximum page size a request can ask for
+	maxPageLimit = 1000
+)
+
+// parseVehicleQuery is a function that builds a vehicle query from query params
+// - limit and offset select the page, sort the order (e.g. sort=max_speed,-year)
+// - include_retired=true searches the retired vehicles too, see parseRetired
+// - every other param is part of the filter, see parseVehicleFilter
+func parseVehicleQuery(query url.Values) (q internal.VehicleQuery, err error) {
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = values
+	}
+
+	// page
+	q.Limit = defaultPageLimit
+	if limit := params.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit <= 0 || q.Limit > maxPageLimit {
+			err = internal.NewFieldError(internal.ErrInvalidQuery, "limit", fmt.Sprintf("limit must be an integer between 1 and %d", maxPageLimit))
+			return
+		}
+	}
+	if offset := params.Get("offset"); offset != "" {
+		q.Offset, err = strconv.Atoi(offset)
+		if err != nil || q.Offset < 0 {
+			err = internal.NewFieldError(internal.ErrInvalidQuery, "offset", "offset must be a positive integer")
+			return
+		}
+	}
+
+	// sort
+	q.Sort, err = internal.ParseVehicleSort(params.Get("sort"))
+	if err != nil {
+		return
+	}
+
+	// retired
+	q.Retired, err = parseRetired(params)
+	if err != nil {
+		return
+	}
+
+	// filter
+	params.Del("limit")
+	params.Del("offset")
+	params.Del("sort")
+	params.Del(includeRetiredParam)
+	q.Filter, err = parseVehicleFilter(params)
+	return
+}
+
+// pageLink is a function that returns the link to the page of the request starting at offset
+func pageLink(r *http.Request, offset int) string {
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	return r.URL.Path + "?" + query.Encode()
+}
+
 // GetAll is a method that returns a handler for the route GET /vehicles
+// the vehicles can be filtered by any combination of fields, sorted and paginated, see parseVehicleQuery
 func (h *VehicleDefault) GetAll() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// request
-		// ...
+		q, err := parseVehicleQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
 		// process
-		// - get all vehicles
-		v, err := h.sv.FindAll()
+		// - get a page of the vehicles matching the query
+		v, total, err := h.sv.Query(r.Context(), q)
 		if err != nil {
-			response.JSON(w, http.StatusInternalServerError, nil)
+			writeError(w, r, err)
 			return
 		}
 
 		// response
-		data := make(map[int]VehicleJSON)
-		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
+		data := make([]VehicleJSON, 0, len(v))
+		for _, value := range v {
+			data = append(data, toVehicleJSON(value))
+		}
+
+		// - links to the next and previous pages
+		var next, previous any
+		if q.Offset+len(v) < total {
+			next = pageLink(r, q.Offset+len(v))
+		}
+		if q.Offset > 0 {
+			prev := q.Offset - q.Limit
+			if prev < 0 {
+				prev = 0
 			}
+			previous = pageLink(r, prev)
 		}
+
 		response.JSON(w, http.StatusOK, map[string]any{
-			"message": "success",
-			"data":    data,
+			"message":  "success",
+			"data":     data,
+			"total":    total,
+			"limit":    q.Limit,
+			"offset":   q.Offset,
+			"next":     next,
+			"previous": previous,
 		})
 	}
 }
@@ -128,59 +303,23 @@ func (h *VehicleDefault) AddVehicle() http.HandlerFunc {
 		var body VehicleJSON
 
 		if err := request.JSON(r, &body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
-			return
-		}
-
-		vehicle := internal.Vehicle{
-			Id: body.ID,
-			VehicleAttributes: internal.VehicleAttributes{
-				Brand:           body.Brand,
-				Model:           body.Model,
-				Registration:    body.Registration,
-				Color:           body.Color,
-				FabricationYear: body.FabricationYear,
-				Capacity:        body.Capacity,
-				MaxSpeed:        body.MaxSpeed,
-				FuelType:        body.FuelType,
-				Transmission:    body.Transmission,
-				Weight:          body.Weight,
-				Dimensions: internal.Dimensions{
-					Height: body.Height,
-					Length: body.Length,
-					Width:  body.Width,
-				},
-			},
+			writeError(w, r, fmt.Errorf("%w: body must be valid JSON", errInvalidBody))
+			return
 		}
 
-		if err := h.sv.AddVehicle(vehicle); err != nil {
-			fmt.Printf("error: %v", err)
-			switch {
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			case errors.Is(err, internal.ErrVehicleAlreadyExists):
-				response.Text(w, http.StatusConflict, "vehicle already exists")
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		vehicle := body.toVehicle()
+
+		if err := h.sv.AddVehicle(r.Context(), vehicle); err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		data := VehicleJSON{
-			ID:              vehicle.Id,
-			Brand:           vehicle.VehicleAttributes.Brand,
-			Model:           vehicle.VehicleAttributes.Model,
-			Registration:    vehicle.VehicleAttributes.Registration,
-			Color:           vehicle.VehicleAttributes.Color,
-			FabricationYear: vehicle.VehicleAttributes.FabricationYear,
-			Capacity:        vehicle.VehicleAttributes.Capacity,
-			MaxSpeed:        vehicle.VehicleAttributes.MaxSpeed,
-			FuelType:        vehicle.VehicleAttributes.FuelType,
-			Transmission:    vehicle.VehicleAttributes.Transmission,
-			Height:          vehicle.VehicleAttributes.Dimensions.Height,
-			Length:          vehicle.VehicleAttributes.Dimensions.Length,
-			Width:           vehicle.VehicleAttributes.Dimensions.Width,
+		// respond with the vehicle as stored, e.g. with its canonical fuel type
+		if stored, err := h.sv.FindById(r.Context(), vehicle.Id, internal.ExcludeRetired); err == nil {
+			vehicle = stored
 		}
+		data := toVehicleJSON(vehicle)
+		setETag(w, vehicle)
 
 		response.JSON(w, http.StatusCreated, map[string]any{
 			"message": "Vehicle added successfully",
@@ -195,53 +334,33 @@ func (h *VehicleDefault) FindByColorAndYear() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		color := chi.URLParam(r, "color")
-		year := chi.URLParam(r, "year")
-
-		if color == "" || year == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		yearInt, err := strconv.Atoi(year)
+		color, err := paramText(r, "color")
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: year must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.FindByColorAndYear(color, yearInt)
+		yearInt, err := paramInt(r, "year")
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		v, err := h.sv.FindByColorAndYear(r.Context(), color, yearInt, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -255,60 +374,39 @@ func (h *VehicleDefault) FindByColorAndYear() http.HandlerFunc {
 func (h *VehicleDefault) FindByBrandAndYearRange() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		brand := chi.URLParam(r, "brand")
-		startYear := chi.URLParam(r, "start_year")
-		endYear := chi.URLParam(r, "end_year")
-
-		if brand == "" || startYear == "" || endYear == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		startYearInt, err := strconv.Atoi(startYear)
+		brand, err := paramText(r, "brand")
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: start_year must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
-		endYearInt, err := strconv.Atoi(endYear)
+		startYearInt, err := paramInt(r, "start_year")
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: end_year must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.FindByBrandAndYearRange(brand, startYearInt, endYearInt)
+		endYearInt, err := paramInt(r, "end_year")
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		v, err := h.sv.FindByBrandAndYearRange(r.Context(), brand, startYearInt, endYearInt, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -323,25 +421,21 @@ func (h *VehicleDefault) FindByBrandAndYearRange() http.HandlerFunc {
 func (h *VehicleDefault) GetAverageSpeedByBrand() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		brand := chi.URLParam(r, "brand")
-
-		if brand == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.GetAverageSpeedByBrand(brand)
+		brand, err := paramText(r, "brand")
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		v, err := h.sv.GetAverageSpeedByBrand(r.Context(), brand, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
@@ -357,87 +451,116 @@ func (h *VehicleDefault) GetAverageSpeedByBrand() http.HandlerFunc {
 	}
 }
 
+// BatchResultJSON is a struct that represents the result of a vehicle of a batch in JSON format
+type BatchResultJSON struct {
+	Index int `json:"index"`
+	ID    int `json:"id"`
+	// Status is the outcome of the vehicle, see internal.BatchStatus
+	Status string `json:"status"`
+	// Code is the HTTP status the vehicle would have on its own, e.g. 409 for a duplicate
+	Code  int        `json:"code"`
+	Error *ErrorJSON `json:"error,omitempty"`
+}
+
+// batchStatusCodes maps the status of a vehicle of a batch to its HTTP status
+var batchStatusCodes = map[internal.BatchStatus]int{
+	internal.BatchCreated:   http.StatusCreated,
+	internal.BatchDuplicate: http.StatusConflict,
+	internal.BatchInvalid:   http.StatusUnprocessableEntity,
+	internal.BatchSkipped:   http.StatusFailedDependency,
+}
+
+// AddVehicles is a method that returns a handler for the route POST /vehicles/batch
+// - mode is atomic (default) to add every vehicle or none, or best_effort to add every valid one
+// - the response has the result of each vehicle by index, with status 201 if every vehicle
+// was created or 207 (multi-status) otherwise
 func (h *VehicleDefault) AddVehicles() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		mode := internal.BatchMode(r.URL.Query().Get("mode"))
+		if mode == "" {
+			mode = internal.BatchAtomic
+		}
+		if !mode.IsValid() {
+			writeError(w, r, internal.NewFieldError(errInvalidParam, "mode", "mode must be atomic or best_effort"))
+			return
+		}
+
 		var body []VehicleJSON
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body) == 0 {
+			writeError(w, r, fmt.Errorf("%w: body must be a non empty JSON array", errInvalidBody))
 			return
 		}
 
 		vehicles := make([]internal.Vehicle, len(body))
 		for key, value := range body {
-			vehicles[key] = internal.Vehicle{
-				Id: value.ID,
-				VehicleAttributes: internal.VehicleAttributes{
-					Brand:           value.Brand,
-					Model:           value.Model,
-					Registration:    value.Registration,
-					Color:           value.Color,
-					FabricationYear: value.FabricationYear,
-					Capacity:        value.Capacity,
-					MaxSpeed:        value.MaxSpeed,
-					FuelType:        value.FuelType,
-					Transmission:    value.Transmission,
-					Dimensions: internal.Dimensions{
-						Height: value.Height,
-						Length: value.Length,
-						Width:  value.Width,
-					},
-				},
-			}
+			vehicles[key] = value.toVehicle()
 		}
 
-		if err := h.sv.AddVehicles(vehicles); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			case errors.Is(err, internal.ErrVehicleAlreadyExists):
-				response.Text(w, http.StatusConflict, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
+		results, err := h.sv.AddVehicles(r.Context(), vehicles, mode)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		code, created := http.StatusCreated, 0
+		data := make([]BatchResultJSON, len(results))
+		for i, result := range results {
+			data[i] = BatchResultJSON{
+				Index:  result.Index,
+				ID:     result.Id,
+				Status: string(result.Status),
+				Code:   batchStatusCodes[result.Status],
+			}
+			if result.Err != nil {
+				_, e := toErrorJSON(result.Err)
+				data[i].Error = &e
 			}
 
-			return
+			if result.Status == internal.BatchCreated {
+				created++
+			} else {
+				code = http.StatusMultiStatus
+			}
 		}
+
+		response.JSON(w, code, map[string]any{
+			"message": fmt.Sprintf("%d of %d vehicles created", created, len(results)),
+			"mode":    mode,
+			"data":    data,
+		})
 	}
 }
 
 func (h *VehicleDefault) UpdateSpeed() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		idInt, err := strconv.Atoi(id)
+		version, err := h.ifMatch(r, idInt)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: id must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
 		var body UpdateSpeedJSON
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
+			writeError(w, r, fmt.Errorf("%w: body must be valid JSON", errInvalidBody))
 			return
 		}
 
-		if err := h.sv.UpdatePartials(idInt, map[string]interface{}{"max_speed": body.MaxSpeed}); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehicleNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		if err := h.sv.UpdatePartials(r.Context(), idInt, version, map[string]interface{}{"max_speed": body.MaxSpeed}); err != nil {
+			writeError(w, r, err)
 			return
 		}
+		if v, err := h.sv.FindById(r.Context(), idInt, internal.ExcludeRetired); err == nil {
+			setETag(w, v)
+		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
 			"message": "success",
@@ -449,46 +572,27 @@ func (h *VehicleDefault) UpdateSpeed() http.HandlerFunc {
 func (h *VehicleDefault) FindByFuelType() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		fuelType := chi.URLParam(r, "type")
-
-		if fuelType == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.FindByFuelType(fuelType)
+		fuelType, err := paramText(r, "type")
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		v, err := h.sv.FindByFuelType(r.Context(), fuelType, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -501,79 +605,81 @@ func (h *VehicleDefault) FindByFuelType() http.HandlerFunc {
 func (h *VehicleDefault) DeleteVehicle() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		idInt, err := strconv.Atoi(id)
+		version, err := h.ifMatch(r, idInt)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: id must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
-		if err := h.sv.DeleteVehicle(idInt); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehicleNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		if err := h.sv.DeleteVehicle(r.Context(), idInt, version); err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		response.JSON(w, http.StatusNoContent, map[string]any{})
+		w.WriteHeader(http.StatusNoContent)
 
 	}
 }
 
-func (h *VehicleDefault) FindByTransmissionType() http.HandlerFunc {
+// RestoreVehicle is a method that returns a handler for the route POST /vehicles/{id}/restore
+// a vehicle retired by DELETE /vehicles/{id} is put back in service, and responded as it is stored
+func (h *VehicleDefault) RestoreVehicle() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-		transmissionType := chi.URLParam(r, "type")
+		if err = h.sv.RestoreVehicle(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-		if transmissionType == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		v, err := h.sv.FindById(r.Context(), id, internal.ExcludeRetired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.FindByTransmissionType(transmissionType)
+		setETag(w, v)
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "Vehicle restored successfully",
+			"data":    toVehicleJSON(v),
+		})
+	}
+}
+
+func (h *VehicleDefault) FindByTransmissionType() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		retired, err := parseRetired(r.URL.Query())
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		transmissionType, err := paramText(r, "type")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
+		v, err := h.sv.FindByTransmissionType(r.Context(), transmissionType, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -586,35 +692,31 @@ func (h *VehicleDefault) FindByTransmissionType() http.HandlerFunc {
 func (h *VehicleDefault) UpdateFuel() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		idInt, err := strconv.Atoi(id)
+		version, err := h.ifMatch(r, idInt)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: id must be an integer")
+			writeError(w, r, err)
 			return
 		}
 
 		var body UpdateFuelJSON
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid body")
+			writeError(w, r, fmt.Errorf("%w: body must be valid JSON", errInvalidBody))
 			return
 		}
 
-		if err := h.sv.UpdatePartials(idInt, map[string]interface{}{"fuel_type": body.FuelType}); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehicleNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		if err := h.sv.UpdatePartials(r.Context(), idInt, version, map[string]interface{}{"fuel_type": body.FuelType}); err != nil {
+			writeError(w, r, err)
 			return
 		}
+		if v, err := h.sv.FindById(r.Context(), idInt, internal.ExcludeRetired); err == nil {
+			setETag(w, v)
+		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
 			"message": "success",
@@ -626,25 +728,21 @@ func (h *VehicleDefault) UpdateFuel() http.HandlerFunc {
 func (h *VehicleDefault) GetAveragePassengersByBrand() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		brand := chi.URLParam(r, "brand")
-
-		if brand == "" {
-			response.Text(w, http.StatusBadRequest, "invalid query params")
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.GetAveragePassengersByBrand(brand)
+		brand, err := paramText(r, "brand")
 		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
-
+		v, err := h.sv.GetAveragePassengersByBrand(r.Context(), brand, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
@@ -663,9 +761,15 @@ func (h *VehicleDefault) GetAveragePassengersByBrand() http.HandlerFunc {
 func (h *VehicleDefault) FindByDimensions() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
 		var queryParams DimensionQueryParams
 		if err := r.ParseForm(); err != nil {
-			response.Text(w, http.StatusBadRequest, "error parsing query parameters")
+			writeError(w, r, fmt.Errorf("%w: %v", errInvalidParam, err))
 			return
 		}
 
@@ -674,47 +778,25 @@ func (h *VehicleDefault) FindByDimensions() http.HandlerFunc {
 
 		minLength, maxLength, err := parseRange(queryParams.LengthRange)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, err.Error())
+			writeError(w, r, internal.NewFieldError(errInvalidParam, "length", err.Error()))
 			return
 		}
 
 		minWidth, maxWidth, err := parseRange(queryParams.WidthRange)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, err.Error())
+			writeError(w, r, internal.NewFieldError(errInvalidParam, "width", err.Error()))
 			return
 		}
 
-		v, err := h.sv.FindByDimensions(minLength, maxLength, minWidth, maxWidth)
+		v, err := h.sv.FindByDimensions(r.Context(), minLength, maxLength, minWidth, maxWidth, retired)
 		if err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -728,49 +810,33 @@ func (h *VehicleDefault) FindByDimensions() http.HandlerFunc {
 func (h *VehicleDefault) FindByWeightRange() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		minWeight, err := strconv.ParseFloat(r.URL.Query().Get("min"), 64)
+		retired, err := parseRetired(r.URL.Query())
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: min must be a float")
+			writeError(w, r, err)
 			return
 		}
 
-		maxWeight, err := strconv.ParseFloat(r.URL.Query().Get("max"), 64)
+		minWeight, err := queryFloat(r, "min")
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid query params: max must be a float")
+			writeError(w, r, err)
 			return
 		}
 
-		v, err := h.sv.FindByWeightRange(minWeight, maxWeight)
+		maxWeight, err := queryFloat(r, "max")
 		if err != nil {
-			switch {
-			case errors.Is(err, internal.ErrVehiclesNotFound):
-				response.Text(w, http.StatusNotFound, err.Error())
-			case errors.Is(err, internal.ErrFieldRequired):
-				response.Text(w, http.StatusBadRequest, err.Error())
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+			writeError(w, r, err)
+			return
+		}
+
+		v, err := h.sv.FindByWeightRange(r.Context(), minWeight, maxWeight, retired)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		data := make(map[int]VehicleJSON)
 		for key, value := range v {
-			data[key] = VehicleJSON{
-				ID:              value.Id,
-				Brand:           value.Brand,
-				Model:           value.Model,
-				Registration:    value.Registration,
-				Color:           value.Color,
-				FabricationYear: value.FabricationYear,
-				Capacity:        value.Capacity,
-				MaxSpeed:        value.MaxSpeed,
-				FuelType:        value.FuelType,
-				Transmission:    value.Transmission,
-				Weight:          value.Weight,
-				Height:          value.Height,
-				Length:          value.Length,
-				Width:           value.Width,
-			}
+			data[key] = toVehicleJSON(value)
 		}
 
 		response.JSON(w, http.StatusOK, map[string]any{
@@ -780,3 +846,233 @@ func (h *VehicleDefault) FindByWeightRange() http.HandlerFunc {
 
 	}
 }
+
+// FindById is a method that returns a handler for the route GET /vehicles/{id}
+func (h *VehicleDefault) FindById() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		v, err := h.sv.FindById(r.Context(), idInt, retired)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		setETag(w, v)
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    toVehicleJSON(v),
+		})
+	}
+}
+
+// UpdateVehicle is a method that returns a handler for the route PUT /vehicles/{id}
+// the body is the whole vehicle, the id of the body is optional but must match the one of the route
+// the If-Match header is required, see ifMatch
+func (h *VehicleDefault) UpdateVehicle() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		version, err := h.ifMatch(r, idInt)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		var body VehicleJSON
+		if err := request.JSON(r, &body); err != nil {
+			writeError(w, r, fmt.Errorf("%w: body must be valid JSON", errInvalidBody))
+			return
+		}
+		if body.ID != 0 && body.ID != idInt {
+			writeError(w, r, internal.NewFieldError(errInvalidBody, "id", "id does not match the route"))
+			return
+		}
+		body.ID = idInt
+
+		if err := h.sv.UpdateVehicle(r.Context(), body.toVehicle(), version); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// respond with the vehicle as stored, with its new version
+		v, err := h.sv.FindById(r.Context(), idInt, internal.ExcludeRetired)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		setETag(w, v)
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    toVehicleJSON(v),
+		})
+	}
+}
+
+// PatchVehicle is a method that returns a handler for the route PATCH /vehicles/{id}
+// the body is any subset of the fields of VehicleJSON, except the id and the version
+// the If-Match header is required, see ifMatch
+func (h *VehicleDefault) PatchVehicle() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		idInt, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		version, err := h.ifMatch(r, idInt)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// decode numbers as json.Number so integers are not turned into floats
+		var body map[string]any
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil || len(body) == 0 {
+			writeError(w, r, fmt.Errorf("%w: body must be valid JSON", errInvalidBody))
+			return
+		}
+
+		if err := h.sv.UpdatePartials(r.Context(), idInt, version, body); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		v, err := h.sv.FindById(r.Context(), idInt, internal.ExcludeRetired)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		setETag(w, v)
+
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    toVehicleJSON(v),
+		})
+	}
+}
+
+// GetStats is a method that returns a handler for the route GET /vehicles/stats
+// - metric is a comma separated list of numeric fields, e.g. metric=max_speed,weight
+// - group_by is the field the vehicles are grouped by, e.g. group_by=brand
+// - agg is a comma separated list of aggregations, e.g. agg=avg,p95 (default avg)
+// - include_retired=true aggregates the retired vehicles too, see parseRetired
+// - every other param is part of the filter, see parseVehicleFilter
+func (h *VehicleDefault) GetStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := r.URL.Query()
+		q := internal.VehicleStatsQuery{
+			GroupBy:      params.Get("group_by"),
+			Aggregations: []string{"avg"},
+		}
+		if metric := params.Get("metric"); metric != "" {
+			q.Metrics = strings.Split(metric, ",")
+		}
+		if agg := params.Get("agg"); agg != "" {
+			q.Aggregations = strings.Split(agg, ",")
+		}
+
+		retired, err := parseRetired(params)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		q.Retired = retired
+
+		params.Del("metric")
+		params.Del("group_by")
+		params.Del("agg")
+		params.Del(includeRetiredParam)
+		f, err := parseVehicleFilter(params)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		q.Filter = f
+
+		g, err := h.sv.GetStats(r.Context(), q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		data := make([]map[string]any, 0, len(g))
+		for _, group := range g {
+			data = append(data, map[string]any{
+				"group":   group.Key,
+				"count":   group.Count,
+				"metrics": group.Metrics,
+			})
+		}
+
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message":  "success",
+			"group_by": q.GroupBy,
+			"data":     data,
+		})
+	}
+}
+
+// FindByRegistration is a method that returns a handler for the route GET /vehicles/registration/{plate}
+func (h *VehicleDefault) FindByRegistration() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		retired, err := parseRetired(r.URL.Query())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		plate, err := paramText(r, "plate")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		v, err := h.sv.FindByRegistration(r.Context(), plate, retired)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		setETag(w, v)
+
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    toVehicleJSON(v),
+		})
+	}
+}
+
+// GetEnums is a method that returns a handler for the route GET /vehicles/enums
+// it lists the allowed values of the enumerated fields of a vehicle
+func (h *VehicleDefault) GetEnums() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data": map[string]any{
+				"fuel_type":    internal.FuelTypes,
+				"transmission": internal.Transmissions,
+			},
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"app/internal"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag is a function that returns the entity tag of a version of a vehicle, e.g. "3"
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag is a function that sets the ETag header of the response to the version of the vehicle
+func setETag(w http.ResponseWriter, v internal.Vehicle) {
+	if v.Version > 0 {
+		w.Header().Set("ETag", etag(v.Version))
+	}
+}
+
+// ifMatch is a method that returns the version of the If-Match header of a change of the vehicle id, which is required
+// - * is internal.VersionAny, the vehicle is changed whatever its version is
+// - otherwise it is a comma-separated list of ETags of the vehicle (RFC 9110), weak entity tags never match as the comparison is strong
+// - for a list of many tags it is the current version of the vehicle if it is one of them; the change still fails
+// with a version mismatch if the vehicle is changed meanwhile
+func (h *VehicleDefault) ifMatch(r *http.Request, id int) (version int, err error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		err = fmt.Errorf("%w: the If-Match header must be the ETag of the vehicle", errPreconditionRequired)
+		return
+	}
+	if header == "*" {
+		return internal.VersionAny, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		n, e := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`))
+		if e != nil || n <= 0 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			err = internal.NewFieldError(errInvalidParam, "If-Match", `If-Match must be * or a list of ETags of the vehicle, e.g. "1", "2"`)
+			return
+		}
+		versions = append(versions, n)
+	}
+
+	switch len(versions) {
+	case 0:
+		err = fmt.Errorf("%w: weak entity tags never match", internal.ErrVehicleVersionMismatch)
+		return
+	case 1:
+		return versions[0], nil
+	}
+	v, err := h.sv.FindById(r.Context(), id, internal.ExcludeRetired)
+	if err != nil {
+		return
+	}
+	for _, n := range versions {
+		if n == v.Version {
+			return n, nil
+		}
+	}
+	return versions[0], nil
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"app/internal"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lastEventIdHeader is the header of a request resuming a stream after the event with its id
+	// browsers send it when an EventSource reconnects
+	lastEventIdHeader = "Last-Event-ID"
+	// eventsHeartbeat is how often a comment is written to an idle stream, so proxies and clients keep it open
+	eventsHeartbeat = 15 * time.Second
+)
+
+// VehicleEventJSON is a struct that represents an event of a vehicle in JSON format
+type VehicleEventJSON struct {
+	ID        int         `json:"id"`
+	Type      string      `json:"type"`
+	Vehicle   VehicleJSON `json:"vehicle"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// toVehicleEventJSON is a function that serializes an event of a vehicle to its JSON representation
+// the timestamp is formatted as RFC 3339 in UTC
+func toVehicleEventJSON(e internal.VehicleEvent) VehicleEventJSON {
+	return VehicleEventJSON{
+		ID:        e.Id,
+		Type:      string(e.Type),
+		Vehicle:   toVehicleJSON(e.Vehicle),
+		Timestamp: e.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// writeEvent is a function that writes an event of a vehicle as a Server-Sent Event
+// the name of the event is its type, and its data the JSON representation on a single line
+func writeEvent(w io.Writer, e internal.VehicleEvent) (err error) {
+	data, err := json.Marshal(toVehicleEventJSON(e))
+	if err != nil {
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+	return
+}
+
+// NewVehicleEvents is a function that returns a new instance of VehicleEvents
+// nm is the normalizer the filters are compared with, by default one with internal.DefaultVehicleSynonyms
+func NewVehicleEvents(bus internal.VehicleEventBus, nm *internal.TextNormalizer) *VehicleEvents {
+	// default values
+	if nm == nil {
+		nm = internal.NewTextNormalizer(nil)
+	}
+
+	return &VehicleEvents{bus: bus, nm: nm}
+}
+
+// VehicleEvents is a struct with methods that represent handlers for the events of the vehicles
+type VehicleEvents struct {
+	// bus is the bus the events are read from
+	bus internal.VehicleEventBus
+	// nm is the normalizer the filters are compared with
+	nm *internal.TextNormalizer
+}
+
+// parseEventFilter is a function that returns the filter of the events of a request, given by its
+// optional brand and fuel_type query params; the fuel type must be one of the allowed values, parsed with nm
+func parseEventFilter(r *http.Request, nm *internal.TextNormalizer) (f internal.VehicleFilter, err error) {
+	query := r.URL.Query()
+	if brand := strings.TrimSpace(query.Get("brand")); brand != "" {
+		f = append(f, internal.TextEq("brand", brand))
+	}
+	if fuelType := query.Get("fuel_type"); fuelType != "" {
+		var parsed internal.FuelType
+		if parsed, err = nm.ParseFuelType(fuelType); err != nil {
+			return
+		}
+		f = append(f, internal.TextEq("fuel_type", string(parsed)))
+	}
+	return
+}
+
+// lastEventId is a function that returns the id of the Last-Event-ID header of a request, 0 without it
+func lastEventId(r *http.Request) (id int, err error) {
+	value := strings.TrimSpace(r.Header.Get(lastEventIdHeader))
+	if value == "" {
+		return
+	}
+
+	id, err = strconv.Atoi(value)
+	if err != nil || id < 0 {
+		err = internal.NewFieldError(errInvalidParam, lastEventIdHeader, lastEventIdHeader+" must be a non negative integer")
+	}
+	return
+}
+
+// Stream is a method that returns a handler for the route GET /vehicles/events
+// it streams the events of the vehicles matching the optional brand and fuel_type query params as Server-Sent Events,
+// until the client disconnects or the server shuts down; a request with the Last-Event-ID header first replays
+// the events after that one that the bus still keeps, so a client that reconnects misses none of the recent ones
+// the stream is not bound by the write timeout of the server
+func (h *VehicleEvents) Stream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := parseEventFilter(r, h.nm)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		lastId, err := lastEventId(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// the write deadline can not be cleared on every writer, e.g. a recorder, where there is none to clear
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		replay, events, unsubscribe := h.bus.Subscribe(lastId)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		// - the headers are sent at once, so the client knows the stream is open before the first event
+		if _, err = io.WriteString(w, ": stream of the vehicle events\n\n"); err != nil {
+			return
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		send := func(e internal.VehicleEvent) error {
+			if !f.MatchNormalized(e.Vehicle, h.nm) {
+				return nil
+			}
+			if err := writeEvent(w, e); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		for _, e := range replay {
+			if err = send(e); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-events:
+				// - the bus closed the subscription: it is shutting down or the client fell behind,
+				//   and the client reconnects with the id of the last event it received
+				if !ok {
+					return
+				}
+				if err = send(e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err = io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+				if err = rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is an event read from a stream of Server-Sent Events
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]any
+}
+
+// stream is a function that opens the stream of events of the router served by srv
+// it is closed at the end of the test, or when it is not read in 5s
+func stream(t *testing.T, srv *httptest.Server, target string, lastEventId string) *bufio.Scanner {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+target, nil)
+	require.NoError(t, err)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	return bufio.NewScanner(res.Body)
+}
+
+// readEvents is a function that reads the next n events of a stream, skipping its comments
+func readEvents(t *testing.T, sc *bufio.Scanner, n int) (events []sseEvent) {
+	var e sseEvent
+	for len(events) < n && sc.Scan() {
+		field, value, _ := strings.Cut(sc.Text(), ": ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.event = value
+		case "data":
+			require.NoError(t, json.Unmarshal([]byte(value), &e.data))
+		case "":
+			if e.id != "" {
+				events = append(events, e)
+			}
+			e = sseEvent{}
+		}
+	}
+	require.Len(t, events, n, sc.Err())
+	return
+}
+
+// serveEvents is a function that serves the router and makes the changes of the vehicles streamed by the tests:
+// event 1 creates vehicle 5, a Fiat, event 2 deletes vehicle 1, a Ford, and event 3 updates vehicle 5
+func serveEvents(t *testing.T, rt *chi.Mux) {
+	status, _ := serve(t, rt, http.MethodPost, "/vehicles", "", vehicleBody("5", "EEE-555"))
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = serve(t, rt, http.MethodDelete, "/vehicles/1", `"1"`, "")
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = serve(t, rt, http.MethodPatch, "/vehicles/5", `"1"`, `{"color":"Green"}`)
+	require.Equal(t, http.StatusOK, status)
+}
+
+// TestVehicleEvents_Stream tests the stream of the events of the changes made through the router
+func TestVehicleEvents_Stream(t *testing.T) {
+	t.Run("events matching the filters", func(t *testing.T) {
+		// arrange
+		rt := newRouter()
+		srv := httptest.NewServer(rt)
+		t.Cleanup(srv.Close)
+		sc := stream(t, srv, "/vehicles/events?brand=fiat&fuel_type=petrol", "")
+
+		// act
+		serveEvents(t, rt)
+		events := readEvents(t, sc, 2)
+
+		// assert
+		require.Equal(t, "1", events[0].id)
+		require.Equal(t, "created", events[0].event)
+		require.Equal(t, 1.0, events[0].data["id"])
+		require.Equal(t, "created", events[0].data["type"])
+		require.NotEmpty(t, events[0].data["timestamp"])
+		require.Equal(t, 5.0, events[0].data["vehicle"].(map[string]any)["id"])
+		require.Equal(t, "3", events[1].id)
+		require.Equal(t, "updated", events[1].event)
+		require.Equal(t, "Green", events[1].data["vehicle"].(map[string]any)["color"])
+		require.Equal(t, 2.0, events[1].data["vehicle"].(map[string]any)["version"])
+	})
+
+	t.Run("resumed after Last-Event-ID", func(t *testing.T) {
+		// arrange
+		rt := newRouter()
+		srv := httptest.NewServer(rt)
+		t.Cleanup(srv.Close)
+		serveEvents(t, rt)
+
+		// act
+		sc := stream(t, srv, "/vehicles/events", "1")
+		events := readEvents(t, sc, 2)
+
+		// assert
+		require.Equal(t, "2", events[0].id)
+		require.Equal(t, "deleted", events[0].event)
+		deleted := events[0].data["vehicle"].(map[string]any)
+		require.Equal(t, 1.0, deleted["id"])
+		require.NotEmpty(t, deleted["retired_at"])
+		require.Equal(t, "3", events[1].id)
+		require.Equal(t, "updated", events[1].event)
+	})
+
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		// arrange
+		req := httptest.NewRequest(http.MethodGet, "/vehicles/events", nil)
+		req.Header.Set("Last-Event-ID", "abc")
+		res := httptest.NewRecorder()
+
+		// act
+		newRouter().ServeHTTP(res, req)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		var body map[string]map[string]any
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+		require.Equal(t, "invalid_param", body["error"]["code"])
+		require.Equal(t, "Last-Event-ID", body["error"]["field"])
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"app/internal"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// exportPageSize is the number of vehicles written between the flushes of an export
+const exportPageSize = 500
+
+// exportColumns are the columns of a CSV export, the JSON names of the fields of VehicleJSON
+// an export can be imported back with POST /vehicles/import
+var exportColumns = []string{
+	"id", "brand", "model", "registration", "color", "year", "passengers",
+	"max_speed", "fuel_type", "transmission", "weight", "height", "length", "width",
+}
+
+// record is a method that returns the vehicle as a CSV record, in the order of exportColumns
+func (v VehicleJSON) record() []string {
+	float := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return []string{
+		strconv.Itoa(v.ID), v.Brand, v.Model, v.Registration, v.Color, strconv.Itoa(v.FabricationYear), strconv.Itoa(v.Capacity),
+		float(v.MaxSpeed), v.FuelType, v.Transmission, float(v.Weight), float(v.Height), float(v.Length), float(v.Width),
+	}
+}
+
+// vehicleWriter is an interface that writes the vehicles of an export one by one
+type vehicleWriter interface {
+	// write is a method that writes a vehicle
+	write(v VehicleJSON) (err error)
+	// flush is a method that writes any buffered data
+	flush() (err error)
+}
+
+// csvVehicleWriter is a struct that writes vehicles as CSV records, after a header with exportColumns
+type csvVehicleWriter struct {
+	wr *csv.Writer
+}
+
+// write is a method that writes a vehicle
+func (w *csvVehicleWriter) write(v VehicleJSON) (err error) {
+	return w.wr.Write(v.record())
+}
+
+// flush is a method that writes any buffered data
+func (w *csvVehicleWriter) flush() (err error) {
+	w.wr.Flush()
+	return w.wr.Error()
+}
+
+// ndjsonVehicleWriter is a struct that writes vehicles as a JSON object per line
+type ndjsonVehicleWriter struct {
+	enc *json.Encoder
+}
+
+// write is a method that writes a vehicle
+func (w *ndjsonVehicleWriter) write(v VehicleJSON) (err error) {
+	return w.enc.Encode(v)
+}
+
+// flush is a method that writes any buffered data
+func (w *ndjsonVehicleWriter) flush() (err error) {
+	return
+}
+
+// ExportVehicles is a method that returns a handler for the route GET /vehicles/export
+// - format is csv (default) or ndjson
+// - excel=true writes a CSV that spreadsheets open as UTF-8 (byte order mark and CRLF line endings)
+// - the vehicles are filtered and sorted as in GET /vehicles, and all of them are exported unless limit is set
+// - the vehicles are read by the service in a single pass and streamed with chunked encoding, so
+// vehicles changed while the export runs may be missing or repeated
+func (h *VehicleDefault) ExportVehicles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// request
+		params := r.URL.Query()
+		format := params.Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "ndjson" {
+			writeError(w, r, internal.NewFieldError(errInvalidParam, "format", "format must be csv or ndjson"))
+			return
+		}
+		excel := params.Get("excel") == "true"
+
+		// - limit is not bounded by the page size of the listing
+		limit := 0
+		if params.Get("limit") != "" {
+			var err error
+			limit, err = strconv.Atoi(params.Get("limit"))
+			if err != nil || limit <= 0 {
+				writeError(w, r, internal.NewFieldError(internal.ErrInvalidQuery, "limit", "limit must be a positive integer"))
+				return
+			}
+		}
+		params.Del("format")
+		params.Del("excel")
+		params.Del("limit")
+
+		q, err := parseVehicleQuery(params)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// - the limit of the listing is replaced by the one of the export, 0 (no limit) unless it is set
+		q.Limit = limit
+
+		// response
+		// - the response starts with the first vehicle, so an error of the service before it can still be
+		// reported with its status; once the status is sent, a failure can only end the stream early
+		var wr vehicleWriter
+		start := func() {
+			switch format {
+			case "csv":
+				w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+				w.Header().Set("Content-Disposition", `attachment; filename="vehicles.csv"`)
+				w.WriteHeader(http.StatusOK)
+				if excel {
+					w.Write([]byte("\ufeff"))
+				}
+				cw := csv.NewWriter(w)
+				cw.UseCRLF = excel
+				cw.Write(exportColumns)
+				wr = &csvVehicleWriter{wr: cw}
+			case "ndjson":
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.Header().Set("Content-Disposition", `attachment; filename="vehicles.ndjson"`)
+				w.WriteHeader(http.StatusOK)
+				wr = &ndjsonVehicleWriter{enc: json.NewEncoder(w)}
+			}
+		}
+
+		// - the vehicles are written as the service reads them, and sent every exportPageSize vehicles
+		flusher, _ := w.(http.Flusher)
+		flush := func() (err error) {
+			if err = wr.flush(); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return
+		}
+		written := 0
+		err = h.sv.Each(r.Context(), q, func(v internal.Vehicle) (err error) {
+			if wr == nil {
+				start()
+			}
+			if err = wr.write(toVehicleJSON(v)); err != nil {
+				return
+			}
+			if written++; written%exportPageSize == 0 {
+				err = flush()
+			}
+			return
+		})
+		switch {
+		case wr == nil && err != nil:
+			writeError(w, r, err)
+		case wr == nil:
+			start()
+			flush()
+		case err == nil:
+			flush()
+		}
+	}
+}
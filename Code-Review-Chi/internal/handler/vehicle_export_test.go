@@ -0,0 +1,60 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exportCases are the requests made to GET /vehicles/export and their expected content
+var exportCases = []struct {
+	name        string
+	target      string
+	contentType string
+	body        string
+}{
+	{
+		name:        "csv",
+		target:      "/vehicles/export?brand=ford",
+		contentType: "text/csv; charset=utf-8",
+		body: "id,brand,model,registration,color,year,passengers,max_speed,fuel_type,transmission,weight,height,length,width\n" +
+			"1,Ford,Fiesta,AAA-111,Red,2010,5,180,gasoline,manual,1100,1.5,4,1.7\n" +
+			"2,Ford,Focus,BBB-222,Blue,2015,5,200,diesel,automatic,1300,1.5,4.4,1.8\n",
+	},
+	{
+		name:        "csv for excel",
+		target:      "/vehicles/export?excel=true&sort=-year&limit=1",
+		contentType: "text/csv; charset=utf-8",
+		body: "\ufeffid,brand,model,registration,color,year,passengers,max_speed,fuel_type,transmission,weight,height,length,width\r\n" +
+			"4,Toyota,Hilux,DDD-444,White,2020,2,170,diesel,manual,2100,1.8,5.3,1.9\r\n",
+	},
+	{
+		name:        "ndjson",
+		target:      "/vehicles/export?format=ndjson&color=red&offset=1",
+		contentType: "application/x-ndjson",
+		body: `{"id":3,"brand":"Toyota","model":"Corolla","registration":"CCC-333","color":"Red","year":2010,"passengers":5,` +
+			`"max_speed":190,"fuel_type":"gasoline","transmission":"automatic","weight":1250,"height":1.4,"length":4.6,"width":1.8,"version":1}` + "\n",
+	},
+}
+
+// TestVehicleDefault_ExportVehicles tests that the export streams the filtered vehicles in the requested format
+func TestVehicleDefault_ExportVehicles(t *testing.T) {
+	for _, c := range exportCases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			rt := newRouter()
+			req := httptest.NewRequest(http.MethodGet, c.target, nil)
+			res := httptest.NewRecorder()
+
+			// act
+			rt.ServeHTTP(res, req)
+
+			// assert
+			require.Equal(t, http.StatusOK, res.Code)
+			require.Equal(t, c.contentType, res.Header().Get("Content-Type"))
+			require.Equal(t, c.body, res.Body.String())
+		})
+	}
+}
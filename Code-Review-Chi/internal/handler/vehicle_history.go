@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"app/internal"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+// actorHeader is the header of a request with the actor its changes are attributed to
+const actorHeader = "X-Actor"
+
+// Actor is a middleware that sets the actor of the request context from the X-Actor header, see internal.WithActor
+// requests without the header are made by internal.AnonymousActor
+// the header is set by the client and is not authenticated, so the actor is who the client says it is:
+// it only identifies who made a change when the server is behind a proxy that authenticates the client and sets it
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := strings.TrimSpace(r.Header.Get(actorHeader)); actor != "" {
+			r = r.WithContext(internal.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FieldChangeJSON is a struct that represents the change of a field of a vehicle in JSON format
+type FieldChangeJSON struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// VehicleChangeJSON is a struct that represents a change of a vehicle in JSON format
+type VehicleChangeJSON struct {
+	ID        int               `json:"id"`
+	VehicleID int               `json:"vehicle_id"`
+	Operation string            `json:"operation"`
+	Fields    []FieldChangeJSON `json:"fields"`
+	Snapshot  VehicleJSON       `json:"snapshot"`
+	Actor     string            `json:"actor"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// toVehicleChangeJSON is a function that serializes a change of a vehicle to its JSON representation
+// the timestamp is formatted as RFC 3339 in UTC
+func toVehicleChangeJSON(c internal.VehicleChange) VehicleChangeJSON {
+	fields := make([]FieldChangeJSON, len(c.Fields))
+	for i, f := range c.Fields {
+		fields[i] = FieldChangeJSON{Field: f.Field, Before: f.Before, After: f.After}
+	}
+
+	return VehicleChangeJSON{
+		ID:        c.Id,
+		VehicleID: c.VehicleId,
+		Operation: string(c.Operation),
+		Fields:    fields,
+		Snapshot:  toVehicleJSON(c.Snapshot),
+		Actor:     c.Actor,
+		Timestamp: c.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// NewVehicleHistory is a function that returns a new instance of VehicleHistory
+func NewVehicleHistory(sv internal.VehicleHistoryService) *VehicleHistory {
+	return &VehicleHistory{sv: sv}
+}
+
+// VehicleHistory is a struct with methods that represent handlers for the change history of the vehicles
+type VehicleHistory struct {
+	// sv is the service that will be used by the handler
+	sv internal.VehicleHistoryService
+}
+
+// GetHistory is a method that returns a handler for the route GET /vehicles/{id}/history
+// the changes of the vehicle are listed oldest first
+func (h *VehicleHistory) GetHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := paramInt(r, "id")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		c, err := h.sv.History(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		data := make([]VehicleChangeJSON, len(c))
+		for i, change := range c {
+			data[i] = toVehicleChangeJSON(change)
+		}
+		response.JSON(w, http.StatusOK, map[string]any{
+			"message": "success",
+			"data":    data,
+		})
+	}
+}
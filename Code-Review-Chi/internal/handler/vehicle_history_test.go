@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleHistory tests that the changes made through the router are listed by the history of the vehicle
+func TestVehicleHistory(t *testing.T) {
+	// arrange
+	rt := newRouter()
+
+	// act
+	status, _ := serve(t, rt, http.MethodPost, "/vehicles", "", vehicleBody("5", "EEE-555"))
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = serve(t, rt, http.MethodPatch, "/vehicles/5", `"1"`, `{"color":"Green"}`)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = serve(t, rt, http.MethodDelete, "/vehicles/5", `"2"`, "")
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = serve(t, rt, http.MethodPost, "/vehicles/5/restore", "", "")
+	require.Equal(t, http.StatusOK, status)
+	status, body := serve(t, rt, http.MethodGet, "/vehicles/5/history", "", "")
+	require.Equal(t, http.StatusOK, status)
+
+	// assert
+	changes := body["data"].([]any)
+	require.Len(t, changes, 4)
+	operations := make([]string, len(changes))
+	for i, c := range changes {
+		change := c.(map[string]any)
+		operations[i] = change["operation"].(string)
+		require.Equal(t, 5.0, change["vehicle_id"])
+		require.Equal(t, "alice", change["actor"])
+		require.NotEmpty(t, change["timestamp"])
+	}
+	require.Equal(t, []string{"create", "update", "delete", "restore"}, operations)
+
+	update := changes[1].(map[string]any)
+	require.Equal(t, []any{map[string]any{"field": "color", "before": "Grey", "after": "Green"}}, update["fields"])
+	require.Equal(t, 2.0, update["snapshot"].(map[string]any)["version"])
+	deleted := changes[2].(map[string]any)
+	require.Equal(t, "Green", deleted["snapshot"].(map[string]any)["color"])
+	require.Equal(t, "", deleted["fields"].([]any)[0].(map[string]any)["after"])
+	restored := changes[3].(map[string]any)
+	require.Equal(t, 4.0, restored["snapshot"].(map[string]any)["version"])
+}
@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"app/internal"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/bootcamp-go/web/response"
+)
+
+const (
+	// importChunkSize is the number of rows added to the repository at once
+	importChunkSize = 500
+	// maxImportErrors is the maximum number of row errors reported by an import
+	maxImportErrors = 1000
+	// maxImportLine is the maximum size of a line of an import
+	maxImportLine = 1 << 20
+	// maxImportSize is the maximum size of the body of an import
+	maxImportSize = 64 << 20
+)
+
+// vehicleRow is a struct that represents a row of an import
+type vehicleRow struct {
+	// line is the line of the row in the upload, starting at 1
+	line int
+	// v is the vehicle of the row
+	v internal.Vehicle
+	// err is the reason the row can not be read, if any
+	err error
+}
+
+// vehicleRowReader is an interface that reads the rows of an import one by one
+type vehicleRowReader interface {
+	// next is a method that returns the next row, or io.EOF after the last one
+	// errors of a single row are reported in the row, err means the upload can not be read anymore
+	next() (row vehicleRow, err error)
+}
+
+// newCSVRowReader is a function that returns a reader of the rows of a CSV upload
+// the first record is the header, which maps each column to a field of the vehicle, see internal.VehicleFieldByHeader
+// the fuel type and transmission are parsed with nm
+func newCSVRowReader(r io.Reader, nm *internal.TextNormalizer) (rd *csvRowReader, err error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: the upload is empty", errInvalidBody)
+		} else {
+			err = uploadError(err)
+		}
+		return
+	}
+
+	var ve internal.ValidationError
+	fields := make([]string, len(header))
+	for i, h := range header {
+		field, ok := internal.VehicleFieldByHeader(h)
+		if !ok {
+			ve.Add(h, internal.RuleUnknown, fmt.Sprintf("column %d: %q is not a vehicle field", i+1, h))
+		}
+		fields[i] = field
+	}
+	if err = ve.Err(); err != nil {
+		return
+	}
+
+	rd = &csvRowReader{rd: cr, fields: fields, nm: nm}
+	return
+}
+
+// csvRowReader is a struct that reads the rows of a CSV upload
+type csvRowReader struct {
+	// rd is the CSV reader
+	rd *csv.Reader
+	// fields are the JSON names of the columns
+	fields []string
+	// nm is the normalizer the fuel type and transmission are parsed with
+	nm *internal.TextNormalizer
+}
+
+// next is a method that returns the next row
+func (r *csvRowReader) next() (row vehicleRow, err error) {
+	record, err := r.rd.Read()
+	var pe *csv.ParseError
+	switch {
+	case err == io.EOF:
+		return
+	case errors.As(err, &pe):
+		row.line, row.err, err = pe.StartLine, fmt.Errorf("%w: %v", errInvalidBody, pe.Err), nil
+		return
+	case err != nil:
+		err = uploadError(err)
+		return
+	}
+
+	row.line, _ = r.rd.FieldPos(0)
+	var ve internal.ValidationError
+	for i, value := range record {
+		if e := row.v.SetFieldText(r.fields[i], value, r.nm); errors.Is(e, internal.ErrInvalidFieldEnum) {
+			ve.Add(r.fields[i], internal.RuleEnum, e.Error())
+		} else if e != nil {
+			ve.Add(r.fields[i], internal.RuleType, e.Error())
+		}
+	}
+	row.err = ve.Err()
+	return
+}
+
+// newNDJSONRowReader is a function that returns a reader of the rows of an NDJSON upload
+// each line is a VehicleJSON, blank lines are skipped
+func newNDJSONRowReader(r io.Reader) *ndjsonRowReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	return &ndjsonRowReader{sc: sc}
+}
+
+// ndjsonRowReader is a struct that reads the rows of an NDJSON upload
+type ndjsonRowReader struct {
+	// sc scans the lines of the upload
+	sc *bufio.Scanner
+	// line is the number of the last line scanned
+	line int
+}
+
+// next is a method that returns the next row
+func (r *ndjsonRowReader) next() (row vehicleRow, err error) {
+	for r.sc.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		row.line = r.line
+		var v VehicleJSON
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if e := dec.Decode(&v); e != nil {
+			row.err = fmt.Errorf("%w: %v", errInvalidBody, e)
+		}
+		row.v = v.toVehicle()
+		return
+	}
+
+	err = r.sc.Err()
+	switch {
+	case err == nil:
+		err = io.EOF
+	case errors.Is(err, bufio.ErrTooLong):
+		err = fmt.Errorf("%w: line %d is longer than %d bytes", errInvalidBody, r.line+1, maxImportLine)
+	default:
+		err = uploadError(err)
+	}
+	return
+}
+
+// lineLimitReader is a struct that reads an upload failing on the first line longer than max bytes
+// so a reader that buffers whole records, e.g. csv.Reader, never buffers more than a line
+type lineLimitReader struct {
+	// rd is the reader of the upload
+	rd io.Reader
+	// max is the maximum size of a line, without the line break
+	max int
+	// line is the number of line breaks read
+	line int
+	// size is the size of the line being read
+	size int
+}
+
+// Read is a method that reads the upload, returning an error if a line is longer than max bytes
+func (r *lineLimitReader) Read(p []byte) (n int, err error) {
+	n, err = r.rd.Read(p)
+	for _, b := range p[:n] {
+		if b == '\n' {
+			r.line, r.size = r.line+1, 0
+			continue
+		}
+		if r.size++; r.size > r.max {
+			return 0, fmt.Errorf("%w: line %d is longer than %d bytes", errInvalidBody, r.line+1, r.max)
+		}
+	}
+	return
+}
+
+// uploadError is a function that returns the error of an upload that can not be read anymore
+func uploadError(err error) error {
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe):
+		return fmt.Errorf("%w: the upload is larger than %d bytes", errBodyTooLarge, mbe.Limit)
+	case errors.Is(err, errInvalidBody):
+		return err
+	default:
+		return fmt.Errorf("%w: %v", errInvalidBody, err)
+	}
+}
+
+// ImportErrorJSON is a struct that represents a row of an import that was not added in JSON format
+type ImportErrorJSON struct {
+	// Row is the line of the row in the upload, starting at 1 (the header of a CSV upload)
+	Row    int       `json:"row"`
+	ID     int       `json:"id,omitempty"`
+	Status string    `json:"status"`
+	Error  ErrorJSON `json:"error"`
+}
+
+// ImportVehicles is a method that returns a handler for the route POST /vehicles/import
+// - the body is text/csv with a header row, or application/x-ndjson with a vehicle per line
+// - the body must not be larger than maxImportSize, nor any line longer than maxImportLine
+// - rows are read, validated and added in chunks as the upload is streamed, a row that can not be
+// added does not stop the import; if the upload itself can not be read, the rows read so far are added and kept
+// - the response has the number of rows read and created and the errors by row, with status 201
+// if every row was created or 207 (multi-status) otherwise; if the import stops, with the error and its status
+func (h *VehicleDefault) ImportVehicles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// request
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+		var rd vehicleRowReader
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			cr, err := newCSVRowReader(&lineLimitReader{rd: body, max: maxImportLine}, h.nm)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			rd = cr
+		case "application/x-ndjson":
+			rd = newNDJSONRowReader(body)
+		default:
+			writeError(w, r, fmt.Errorf("%w: content type must be text/csv or application/x-ndjson", errUnsupportedMediaType))
+			return
+		}
+
+		// process
+		rows, created := 0, 0
+		errs := make([]ImportErrorJSON, 0)
+		report := func(line int, id int, status internal.BatchStatus, err error) {
+			if len(errs) < maxImportErrors {
+				_, e := toErrorJSON(err)
+				errs = append(errs, ImportErrorJSON{Row: line, ID: id, Status: string(status), Error: e})
+			}
+		}
+
+		chunk := make([]internal.Vehicle, 0, importChunkSize)
+		lines := make([]int, 0, importChunkSize)
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			results, err := h.sv.AddVehicles(r.Context(), chunk, internal.BatchBestEffort)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				if result.Status == internal.BatchCreated {
+					created++
+					continue
+				}
+				report(lines[result.Index], result.Id, result.Status, result.Err)
+			}
+			chunk, lines = chunk[:0], lines[:0]
+			return nil
+		}
+
+		summary := func() map[string]any {
+			// - rows that fail validation are reported as they are read and duplicates when their chunk is added
+			sort.Slice(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+			return map[string]any{
+				"message":          fmt.Sprintf("%d of %d rows created", created, rows),
+				"rows":             rows,
+				"created":          created,
+				"failed":           rows - created,
+				"errors":           errs,
+				"errors_truncated": rows-created > len(errs),
+			}
+		}
+
+		for {
+			row, err := rd.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// - the upload can not be read anymore: the rows read so far are added,
+				// and reported with the error, as the chunks already added are kept
+				// - the error is the one of the upload, which stopped the import: a chunk that can not be added,
+				// e.g. as the request was canceled with the upload, is not counted as created
+				flush()
+				writeErrorFields(w, r, err, summary())
+				return
+			}
+
+			rows++
+			if row.err != nil {
+				report(row.line, row.v.Id, internal.BatchInvalid, row.err)
+				continue
+			}
+			chunk = append(chunk, row.v)
+			lines = append(lines, row.line)
+			if len(chunk) == importChunkSize {
+				if err = flush(); err != nil {
+					writeErrorFields(w, r, err, summary())
+					return
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			writeErrorFields(w, r, err, summary())
+			return
+		}
+
+		// response
+		code := http.StatusCreated
+		if created < rows {
+			code = http.StatusMultiStatus
+		}
+		response.JSON(w, code, summary())
+	}
+}
@@ -0,0 +1,38 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleDefault_ImportVehicles tests that the columns of a CSV import are mapped to the fields of the vehicles by their header
+func TestVehicleDefault_ImportVehicles(t *testing.T) {
+	// arrange
+	rt := newRouter()
+	req := httptest.NewRequest(http.MethodPost, "/vehicles/import", strings.NewReader(
+		"Plate, Max-Speed,Fabrication Year,CAPACITY,fuel type,id,brand,model,color,transmission,weight,height,length,width\n"+
+			"EEE-555,150,1995,4,Petrol,5,Fiat,Uno,Grey,manual,800,1.4,3.6,1.5\n",
+	))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	res := httptest.NewRecorder()
+
+	// act
+	rt.ServeHTTP(res, req)
+	status, body := serve(t, rt, http.MethodGet, "/vehicles/5", "", "")
+
+	// assert
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	require.Equal(t, http.StatusOK, status)
+	data := body["data"].(map[string]any)
+	require.Equal(t, "EEE-555", data["registration"])
+	require.Equal(t, 150.0, data["max_speed"])
+	require.Equal(t, 1995.0, data["year"])
+	require.Equal(t, 4.0, data["passengers"])
+	require.Equal(t, "gasoline", data["fuel_type"])
+	require.Equal(t, "Uno", data["model"])
+	require.Equal(t, 1.5, data["width"])
+}
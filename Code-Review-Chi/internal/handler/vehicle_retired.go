@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"app/internal"
+	"net/url"
+	"strconv"
+)
+
+// includeRetiredParam is the query param of a request that reads the retired vehicles too, e.g. include_retired=true
+const includeRetiredParam = "include_retired"
+
+// parseRetired is a function that returns whether the reads of a request include the retired vehicles,
+// from its include_retired query param; a value that is not a boolean is an invalid param
+func parseRetired(query url.Values) (retired internal.Retired, err error) {
+	value := query.Get(includeRetiredParam)
+	if value == "" {
+		return internal.ExcludeRetired, nil
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return internal.ExcludeRetired, internal.NewFieldError(errInvalidParam, includeRetiredParam, includeRetiredParam+" must be true or false")
+	}
+	return internal.Retired(include), nil
+}
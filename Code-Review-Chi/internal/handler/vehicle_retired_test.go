@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleDefault_Retired tests that a deleted vehicle is only read with include_retired=true until it is restored
+func TestVehicleDefault_Retired(t *testing.T) {
+	// arrange
+	rt := newRouter()
+	status, _ := serve(t, rt, http.MethodDelete, "/vehicles/1", `"1"`, "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	t.Run("excluded by default", func(t *testing.T) {
+		// act
+		statusById, _ := serve(t, rt, http.MethodGet, "/vehicles/1", "", "")
+		statusByPlate, _ := serve(t, rt, http.MethodGet, "/vehicles/registration/AAA-111", "", "")
+		_, byColor := serve(t, rt, http.MethodGet, "/vehicles/color/red/year/2010", "", "")
+		_, average := serve(t, rt, http.MethodGet, "/vehicles/average_speed/brand/ford", "", "")
+
+		// assert
+		require.Equal(t, http.StatusNotFound, statusById)
+		require.Equal(t, http.StatusNotFound, statusByPlate)
+		require.Equal(t, []string{"3"}, ids(byColor["data"]))
+		require.Equal(t, 200.0, average["data"].(map[string]any)["average_speed"])
+	})
+
+	t.Run("included on request", func(t *testing.T) {
+		// act
+		statusById, byId := serve(t, rt, http.MethodGet, "/vehicles/1?include_retired=true", "", "")
+		statusByPlate, _ := serve(t, rt, http.MethodGet, "/vehicles/registration/AAA-111?include_retired=true", "", "")
+		_, byColor := serve(t, rt, http.MethodGet, "/vehicles/color/red/year/2010?include_retired=true", "", "")
+		_, average := serve(t, rt, http.MethodGet, "/vehicles/average_speed/brand/ford?include_retired=true", "", "")
+
+		// assert
+		require.Equal(t, http.StatusOK, statusById)
+		require.NotEmpty(t, byId["data"].(map[string]any)["retired_at"])
+		require.Equal(t, http.StatusOK, statusByPlate)
+		require.Equal(t, []string{"1", "3"}, ids(byColor["data"]))
+		require.Equal(t, 190.0, average["data"].(map[string]any)["average_speed"])
+	})
+
+	t.Run("not changed", func(t *testing.T) {
+		// act
+		statusPatch, _ := serve(t, rt, http.MethodPatch, "/vehicles/1", "*", `{"color":"Green"}`)
+		statusDelete, _ := serve(t, rt, http.MethodDelete, "/vehicles/1", "*", "")
+
+		// assert
+		require.Equal(t, http.StatusNotFound, statusPatch)
+		require.Equal(t, http.StatusNotFound, statusDelete)
+	})
+
+	t.Run("restored", func(t *testing.T) {
+		// act
+		statusRestore, restored := serve(t, rt, http.MethodPost, "/vehicles/1/restore", "", "")
+		statusById, _ := serve(t, rt, http.MethodGet, "/vehicles/1", "", "")
+
+		// assert
+		require.Equal(t, http.StatusOK, statusRestore)
+		require.Equal(t, 3.0, restored["data"].(map[string]any)["version"])
+		require.Nil(t, restored["data"].(map[string]any)["retired_at"])
+		require.Equal(t, http.StatusOK, statusById)
+	})
+}
@@ -0,0 +1,395 @@
+package handler_test
+
+import (
+	"app/internal"
+	"app/internal/application"
+	"app/internal/eventbus"
+	"app/internal/handler"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"app/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter is a function that returns the router of the application backed by the vehicles of repositorytest.Vehicles
+// an empty change log and an event bus without events
+func newRouter() *chi.Mux {
+	rp := repository.NewVehicleMap(repositorytest.Vehicles(), nil)
+	sv := service.NewVehicleAudit(service.NewVehicleDefault(rp, nil), repository.NewVehicleChangeMap())
+	bus := eventbus.NewVehicleBus(0)
+	rp.SetObserver(internal.VehicleObservers{sv, service.NewVehicleNotifier(bus)})
+	return application.NewRouter(handler.NewVehicleDefault(sv, nil), handler.NewVehicleHistory(sv), handler.NewVehicleEvents(bus, internal.NewTextNormalizer(nil)))
+}
+
+// serve is a function that makes a JSON request to the router as the actor alice, returning the status
+// and the decoded body of the response, nil for a response without content
+func serve(t *testing.T, rt *chi.Mux, method string, target string, ifMatch string, body string) (status int, decoded map[string]any) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "alice")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	res := httptest.NewRecorder()
+	rt.ServeHTTP(res, req)
+
+	if res.Code != http.StatusNoContent {
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &decoded), res.Body.String())
+	}
+	return res.Code, decoded
+}
+
+// routeCase is a request to a route of the router and its expected response
+type routeCase struct {
+	name        string
+	method      string
+	target      string
+	contentType string
+	// ifMatch is the If-Match header of the request, if any
+	ifMatch string
+	body    string
+	// status is the expected status code
+	status int
+	// code is the expected code of the error, for error responses
+	code string
+	// etag is the expected ETag header of the response, if any
+	etag string
+	// check asserts the decoded body of the response, if any
+	check func(t *testing.T, body map[string]any)
+}
+
+// ids is a function that returns the sorted keys of a map of vehicles by id in JSON format
+func ids(data any) []string {
+	keys := make([]string, 0)
+	for key := range data.(map[string]any) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// vehicleBody is a valid body of a new vehicle with the given id and registration
+func vehicleBody(id string, registration string) string {
+	return `{"id":` + id + `,"brand":"Fiat","model":"Uno","registration":"` + registration + `","color":"Grey","year":1995,` +
+		`"passengers":5,"max_speed":150,"fuel_type":"Petrol","transmission":"manual","weight":800,"height":1.4,"length":3.6,"width":1.5}`
+}
+
+// routeCases are the requests made to every route of the router
+var routeCases = []routeCase{
+	// GET /vehicles
+	{name: "list", method: http.MethodGet, target: "/vehicles", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 4.0, body["total"])
+		require.Len(t, body["data"], 4)
+		require.Nil(t, body["next"])
+	}},
+	{name: "list filtered and sorted", method: http.MethodGet, target: "/vehicles?brand=ford&sort=-max_speed&limit=1", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 2.0, body["total"])
+		require.Equal(t, 2.0, body["data"].([]any)[0].(map[string]any)["id"])
+		require.Equal(t, "/vehicles?brand=ford&limit=1&offset=1&sort=-max_speed", body["next"])
+	}},
+	{name: "list invalid limit", method: http.MethodGet, target: "/vehicles?limit=x", status: http.StatusBadRequest, code: "invalid_query"},
+	{name: "list invalid filter", method: http.MethodGet, target: "/vehicles?color_gte=red", status: http.StatusBadRequest, code: "invalid_filter"},
+	{name: "list including retired", method: http.MethodGet, target: "/vehicles?include_retired=true&brand=ford", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 2.0, body["total"])
+	}},
+	{name: "list invalid include retired", method: http.MethodGet, target: "/vehicles?include_retired=maybe", status: http.StatusBadRequest, code: "invalid_param"},
+	// POST /vehicles
+	{name: "add", method: http.MethodPost, target: "/vehicles", body: vehicleBody("5", "EEE-555"), status: http.StatusCreated, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, "gasoline", body["data"].(map[string]any)["fuel_type"])
+	}},
+	{name: "add duplicated id", method: http.MethodPost, target: "/vehicles", body: vehicleBody("1", "EEE-555"), status: http.StatusConflict, code: "vehicle_already_exists"},
+	{name: "add duplicated registration", method: http.MethodPost, target: "/vehicles", body: vehicleBody("5", "AAA-111"), status: http.StatusConflict, code: "registration_already_exists"},
+	{name: "add invalid", method: http.MethodPost, target: "/vehicles", body: `{"id":5,"brand":"Fiat"}`, status: http.StatusUnprocessableEntity, code: "validation_failed", check: func(t *testing.T, body map[string]any) {
+		require.NotEmpty(t, body["error"].(map[string]any)["violations"])
+	}},
+	{name: "add malformed", method: http.MethodPost, target: "/vehicles", body: `{`, status: http.StatusBadRequest, code: "invalid_body"},
+	// GET /vehicles/color/{color}/year/{year}
+	{name: "by color and year", method: http.MethodGet, target: "/vehicles/color/red/year/2010", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"1", "3"}, ids(body["data"]))
+	}},
+	{name: "by color and year not found", method: http.MethodGet, target: "/vehicles/color/Green/year/2010", status: http.StatusNotFound, code: "vehicles_not_found"},
+	{name: "by color and year invalid year", method: http.MethodGet, target: "/vehicles/color/Red/year/x", status: http.StatusBadRequest, code: "invalid_param"},
+	// GET /vehicles/brand/{brand}/year/{start_year}/{end_year}
+	{name: "by brand and years", method: http.MethodGet, target: "/vehicles/brand/Toyota/year/2010/2020", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"3", "4"}, ids(body["data"]))
+	}},
+	{name: "by brand and years not found", method: http.MethodGet, target: "/vehicles/brand/Tesla/year/2010/2020", status: http.StatusNotFound, code: "vehicles_not_found"},
+	// GET /vehicles/average_speed/brand/{brand}
+	{name: "average speed", method: http.MethodGet, target: "/vehicles/average_speed/brand/Toyota", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 180.0, body["data"].(map[string]any)["average_speed"])
+	}},
+	{name: "average speed not found", method: http.MethodGet, target: "/vehicles/average_speed/brand/Tesla", status: http.StatusNotFound, code: "vehicles_not_found"},
+	// POST /vehicles/batch
+	{name: "batch", method: http.MethodPost, target: "/vehicles/batch", body: "[" + vehicleBody("5", "EEE-555") + "," + vehicleBody("6", "FFF-666") + "]", status: http.StatusCreated},
+	{name: "batch best effort", method: http.MethodPost, target: "/vehicles/batch?mode=best_effort", body: "[" + vehicleBody("5", "EEE-555") + "," + vehicleBody("1", "FFF-666") + "]", status: http.StatusMultiStatus, check: func(t *testing.T, body map[string]any) {
+		data := body["data"].([]any)
+		require.Equal(t, "created", data[0].(map[string]any)["status"])
+		require.Equal(t, "duplicate", data[1].(map[string]any)["status"])
+	}},
+	{name: "batch invalid mode", method: http.MethodPost, target: "/vehicles/batch?mode=all", body: "[" + vehicleBody("5", "EEE-555") + "]", status: http.StatusBadRequest, code: "invalid_param"},
+	{name: "batch empty", method: http.MethodPost, target: "/vehicles/batch", body: "[]", status: http.StatusBadRequest, code: "invalid_body"},
+	// POST /vehicles/import
+	{name: "import", method: http.MethodPost, target: "/vehicles/import", contentType: "application/x-ndjson", body: vehicleBody("5", "EEE-555") + "\n" + vehicleBody("1", "FFF-666") + "\n", status: http.StatusMultiStatus, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 1.0, body["created"])
+		require.Equal(t, 2.0, body["errors"].([]any)[0].(map[string]any)["row"])
+	}},
+	{name: "import csv", method: http.MethodPost, target: "/vehicles/import", contentType: "text/csv",
+		body: "ID, Brand,MODEL,Plate,Color,Fabrication Year,Capacity,Max-Speed,fuel type,Transmission,Weight,Height,Length,Width\n" +
+			"5,Fiat,Uno,EEE-555,Grey,1995,5,150,Petrol,manual,800,1.4,3.6,1.5\n",
+		status: http.StatusCreated, check: func(t *testing.T, body map[string]any) {
+			require.Equal(t, 1.0, body["created"])
+			require.Empty(t, body["errors"])
+		}},
+	{name: "import csv row errors", method: http.MethodPost, target: "/vehicles/import", contentType: "text/csv",
+		body: "id,brand,model,registration,color,year,passengers,max_speed,fuel_type,transmission,weight,height,length,width\n" +
+			"5,Fiat,Uno,EEE-555,Grey,1995,5,150,Petrol,manual,800,1.4,3.6,1.5\n" +
+			"6,Fiat,Uno,FFF-666,Grey,x,5,150,Petrol,manual,800,1.4,3.6,1.5\n" +
+			"1,Fiat,Uno,GGG-777,Grey,1995,5,150,Petrol,manual,800,1.4,3.6,1.5\n" +
+			"7,Fiat,Uno,HHH-888,Grey,1995,5,150,Petrol,manual,800,1.4,3.6\n",
+		status: http.StatusMultiStatus, check: func(t *testing.T, body map[string]any) {
+			require.Equal(t, 4.0, body["rows"])
+			require.Equal(t, 1.0, body["created"])
+			errs := body["errors"].([]any)
+			require.Len(t, errs, 3)
+			for i, expected := range []struct {
+				row    float64
+				status string
+			}{{3, "invalid"}, {4, "duplicate"}, {5, "invalid"}} {
+				require.Equal(t, expected.row, errs[i].(map[string]any)["row"])
+				require.Equal(t, expected.status, errs[i].(map[string]any)["status"])
+			}
+		}},
+	{name: "import csv unknown column", method: http.MethodPost, target: "/vehicles/import", contentType: "text/csv", body: "id,wheels\n5,4\n", status: http.StatusUnprocessableEntity, code: "validation_failed"},
+	{name: "import line too long", method: http.MethodPost, target: "/vehicles/import", contentType: "text/csv", body: "id,brand\n5," + strings.Repeat("a", 1<<20) + "\n", status: http.StatusBadRequest, code: "invalid_body", check: func(t *testing.T, body map[string]any) {
+		require.Contains(t, body["error"].(map[string]any)["message"], "line 2 is longer than")
+	}},
+	{name: "import upload that can not be read", method: http.MethodPost, target: "/vehicles/import", contentType: "application/x-ndjson",
+		body:   vehicleBody("5", "EEE-555") + "\n" + vehicleBody("1", "FFF-666") + "\n" + strings.Repeat("a", 1<<20+1) + "\n",
+		status: http.StatusBadRequest, code: "invalid_body", check: func(t *testing.T, body map[string]any) {
+			// - the rows read before the upload failed are added and reported with the error
+			require.Contains(t, body["error"].(map[string]any)["message"], "line 3 is longer than")
+			require.Equal(t, 2.0, body["rows"])
+			require.Equal(t, 1.0, body["created"])
+			require.Equal(t, 2.0, body["errors"].([]any)[0].(map[string]any)["row"])
+		}},
+	{name: "import unsupported media type", method: http.MethodPost, target: "/vehicles/import", contentType: "application/json", body: "[]", status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+	// GET /vehicles/export
+	{name: "export invalid format", method: http.MethodGet, target: "/vehicles/export?format=xml", status: http.StatusBadRequest, code: "invalid_param"},
+	// GET /vehicles/events
+	//   the stream itself is tested with a server, as it does not end
+	{name: "events invalid fuel type", method: http.MethodGet, target: "/vehicles/events?fuel_type=steam", status: http.StatusBadRequest, code: "invalid_enum"},
+	// PUT /vehicles/{id}/update_speed
+	{name: "update speed", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"1"`, body: `{"max_speed":150}`, status: http.StatusOK, etag: `"2"`},
+	{name: "update speed not found", method: http.MethodPut, target: "/vehicles/99/update_speed", ifMatch: "*", body: `{"max_speed":150}`, status: http.StatusNotFound, code: "vehicle_not_found"},
+	{name: "update speed invalid", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"1"`, body: `{"max_speed":-1}`, status: http.StatusUnprocessableEntity, code: "validation_failed"},
+	{name: "update speed without if match", method: http.MethodPut, target: "/vehicles/1/update_speed", body: `{"max_speed":150}`, status: http.StatusPreconditionRequired, code: "precondition_required"},
+	{name: "update speed outdated", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"2"`, body: `{"max_speed":150}`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	{name: "update speed weak if match", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `W/"1"`, body: `{"max_speed":150}`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	{name: "update speed invalid if match", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: "1", body: `{"max_speed":150}`, status: http.StatusBadRequest, code: "invalid_param"},
+	{name: "update speed if match list", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"3", "1"`, body: `{"max_speed":150}`, status: http.StatusOK, etag: `"2"`},
+	{name: "update speed if match list with weak tag", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `W/"1","1"`, body: `{"max_speed":150}`, status: http.StatusOK, etag: `"2"`},
+	{name: "update speed if match list outdated", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"2", "3"`, body: `{"max_speed":150}`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	{name: "update speed if match list not found", method: http.MethodPut, target: "/vehicles/99/update_speed", ifMatch: `"1", "2"`, body: `{"max_speed":150}`, status: http.StatusNotFound, code: "vehicle_not_found"},
+	{name: "update speed invalid if match list", method: http.MethodPut, target: "/vehicles/1/update_speed", ifMatch: `"1", 2`, body: `{"max_speed":150}`, status: http.StatusBadRequest, code: "invalid_param"},
+	// PUT /vehicles/{id}/update_fuel
+	{name: "update fuel", method: http.MethodPut, target: "/vehicles/1/update_fuel", ifMatch: `"1"`, body: `{"fuel_type":"diesel"}`, status: http.StatusOK, etag: `"2"`},
+	{name: "update fuel invalid", method: http.MethodPut, target: "/vehicles/1/update_fuel", ifMatch: `"1"`, body: `{"fuel_type":"steam"}`, status: http.StatusUnprocessableEntity, code: "validation_failed"},
+	{name: "update fuel outdated", method: http.MethodPut, target: "/vehicles/1/update_fuel", ifMatch: `"3"`, body: `{"fuel_type":"diesel"}`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	// GET /vehicles/fuel_type/{type}
+	{name: "by fuel type", method: http.MethodGet, target: "/vehicles/fuel_type/diesel", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"2", "4"}, ids(body["data"]))
+	}},
+	{name: "by fuel type not found", method: http.MethodGet, target: "/vehicles/fuel_type/electric", status: http.StatusNotFound, code: "vehicles_not_found"},
+	// GET /vehicles/{id}
+	{name: "by id", method: http.MethodGet, target: "/vehicles/3", status: http.StatusOK, etag: `"1"`, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, "CCC-333", body["data"].(map[string]any)["registration"])
+	}},
+	{name: "by id not found", method: http.MethodGet, target: "/vehicles/99", status: http.StatusNotFound, code: "vehicle_not_found"},
+	{name: "by id invalid", method: http.MethodGet, target: "/vehicles/x", status: http.StatusBadRequest, code: "invalid_param"},
+	// GET /vehicles/registration/{plate}
+	{name: "by registration", method: http.MethodGet, target: "/vehicles/registration/aaa-111", status: http.StatusOK, etag: `"1"`, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 1.0, body["data"].(map[string]any)["id"])
+	}},
+	{name: "by registration not found", method: http.MethodGet, target: "/vehicles/registration/ZZZ-999", status: http.StatusNotFound, code: "vehicle_not_found"},
+	// PUT /vehicles/{id}
+	{name: "update", method: http.MethodPut, target: "/vehicles/2", ifMatch: `"1"`, body: vehicleBody("2", "BBB-222"), status: http.StatusOK, etag: `"2"`, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 2.0, body["data"].(map[string]any)["version"])
+	}},
+	{name: "update id mismatch", method: http.MethodPut, target: "/vehicles/2", ifMatch: `"1"`, body: vehicleBody("3", "BBB-222"), status: http.StatusBadRequest, code: "invalid_body"},
+	{name: "update registration of another vehicle", method: http.MethodPut, target: "/vehicles/2", ifMatch: `"1"`, body: vehicleBody("2", "AAA-111"), status: http.StatusConflict, code: "registration_already_exists"},
+	{name: "update without if match", method: http.MethodPut, target: "/vehicles/2", body: vehicleBody("2", "BBB-222"), status: http.StatusPreconditionRequired, code: "precondition_required"},
+	{name: "update outdated", method: http.MethodPut, target: "/vehicles/2", ifMatch: `"2"`, body: vehicleBody("2", "BBB-222"), status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	// PATCH /vehicles/{id}
+	{name: "patch", method: http.MethodPatch, target: "/vehicles/2", ifMatch: `"1"`, body: `{"color":"Green","year":2016}`, status: http.StatusOK, etag: `"2"`, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, "Green", body["data"].(map[string]any)["color"])
+		require.Equal(t, 2016.0, body["data"].(map[string]any)["year"])
+	}},
+	{name: "patch any version", method: http.MethodPatch, target: "/vehicles/2", ifMatch: "*", body: `{"color":"Green"}`, status: http.StatusOK, etag: `"2"`},
+	{name: "patch read only", method: http.MethodPatch, target: "/vehicles/2", ifMatch: `"1"`, body: `{"id":3}`, status: http.StatusUnprocessableEntity, code: "validation_failed"},
+	{name: "patch not found", method: http.MethodPatch, target: "/vehicles/99", ifMatch: "*", body: `{"color":"Green"}`, status: http.StatusNotFound, code: "vehicle_not_found"},
+	{name: "patch outdated", method: http.MethodPatch, target: "/vehicles/2", ifMatch: `"2"`, body: `{"color":"Green"}`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	// DELETE /vehicles/{id}
+	{name: "delete", method: http.MethodDelete, target: "/vehicles/1", ifMatch: `"1"`, status: http.StatusNoContent},
+	{name: "delete not found", method: http.MethodDelete, target: "/vehicles/99", ifMatch: "*", status: http.StatusNotFound, code: "vehicle_not_found"},
+	{name: "delete without if match", method: http.MethodDelete, target: "/vehicles/1", status: http.StatusPreconditionRequired, code: "precondition_required"},
+	{name: "delete outdated", method: http.MethodDelete, target: "/vehicles/1", ifMatch: `"2"`, status: http.StatusPreconditionFailed, code: "version_mismatch"},
+	// POST /vehicles/{id}/restore
+	{name: "restore not retired", method: http.MethodPost, target: "/vehicles/1/restore", status: http.StatusConflict, code: "vehicle_not_retired"},
+	{name: "restore not found", method: http.MethodPost, target: "/vehicles/99/restore", status: http.StatusNotFound, code: "vehicle_not_found"},
+	// GET /vehicles/{id}/history
+	{name: "history without changes", method: http.MethodGet, target: "/vehicles/1/history", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Empty(t, body["data"])
+	}},
+	{name: "history not found", method: http.MethodGet, target: "/vehicles/99/history", status: http.StatusNotFound, code: "vehicle_not_found"},
+	// GET /vehicles/transmission/{type}
+	{name: "by transmission", method: http.MethodGet, target: "/vehicles/transmission/auto", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"2", "3"}, ids(body["data"]))
+	}},
+	{name: "by transmission not found", method: http.MethodGet, target: "/vehicles/transmission/semi-automatic", status: http.StatusNotFound, code: "vehicles_not_found"},
+	// GET /vehicles/average_capacity/brand/{brand}
+	{name: "average capacity", method: http.MethodGet, target: "/vehicles/average_capacity/brand/toyota", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, 3.5, body["data"].(map[string]any)["average_capacity"])
+	}},
+	{name: "average capacity not found", method: http.MethodGet, target: "/vehicles/average_capacity/brand/Tesla", status: http.StatusNotFound, code: "vehicles_not_found"},
+	// GET /vehicles/stats
+	{name: "stats", method: http.MethodGet, target: "/vehicles/stats?metric=max_speed&group_by=brand", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Len(t, body["data"], 2)
+	}},
+	{name: "stats invalid metric", method: http.MethodGet, target: "/vehicles/stats?metric=brand", status: http.StatusBadRequest, code: "invalid_stats"},
+	// GET /vehicles/enums
+	{name: "enums", method: http.MethodGet, target: "/vehicles/enums", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Contains(t, body["data"].(map[string]any)["fuel_type"], "diesel")
+	}},
+	// GET /vehicles/dimensions
+	{name: "by dimensions", method: http.MethodGet, target: "/vehicles/dimensions?length=4.4-5.3&width=1.8-1.8", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"2", "3"}, ids(body["data"]))
+	}},
+	{name: "by dimensions invalid range", method: http.MethodGet, target: "/vehicles/dimensions?length=x&width=1-2", status: http.StatusBadRequest, code: "invalid_param"},
+	// GET /vehicles/weight
+	{name: "by weight", method: http.MethodGet, target: "/vehicles/weight?min=1100&max=1250", status: http.StatusOK, check: func(t *testing.T, body map[string]any) {
+		require.Equal(t, []string{"1", "3"}, ids(body["data"]))
+	}},
+	{name: "by weight not found", method: http.MethodGet, target: "/vehicles/weight?min=3000&max=4000", status: http.StatusNotFound, code: "vehicles_not_found"},
+	{name: "by weight inverted range", method: http.MethodGet, target: "/vehicles/weight?min=2&max=1", status: http.StatusBadRequest, code: "field_required"},
+}
+
+// TestVehicleDefault_Routes tests every route of the router with a new repository per request
+func TestVehicleDefault_Routes(t *testing.T) {
+	for _, c := range routeCases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			rt := newRouter()
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			if c.contentType != "" {
+				req.Header.Set("Content-Type", c.contentType)
+			} else if c.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+			res := httptest.NewRecorder()
+
+			// act
+			rt.ServeHTTP(res, req)
+
+			// assert
+			require.Equal(t, c.status, res.Code, res.Body.String())
+			if c.etag != "" {
+				require.Equal(t, c.etag, res.Header().Get("ETag"))
+			}
+			if c.status == http.StatusNoContent {
+				require.Empty(t, res.Body.String())
+				require.Empty(t, res.Header().Get("Content-Type"))
+				return
+			}
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body), res.Body.String())
+			if c.code != "" {
+				e := body["error"].(map[string]any)
+				require.Equal(t, c.code, e["code"])
+				require.NotEmpty(t, e["request_id"])
+			}
+			if c.check != nil {
+				c.check(t, body)
+			}
+		})
+	}
+}
+
+// TestVehicleDefault_Context tests that the routes stop when the context of the request is done
+func TestVehicleDefault_Context(t *testing.T) {
+	// expired is a context whose deadline is exceeded
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	// canceled is a context canceled by the client
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		code   string
+	}{
+		{name: "deadline exceeded", ctx: expired, status: http.StatusServiceUnavailable, code: "request_timeout"},
+		{name: "canceled", ctx: canceled, status: 499, code: "request_canceled"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			rt := newRouter()
+			req := httptest.NewRequest(http.MethodGet, "/vehicles/dimensions?length=0-10&width=0-10", nil).WithContext(c.ctx)
+			res := httptest.NewRecorder()
+
+			// act
+			rt.ServeHTTP(res, req)
+
+			// assert
+			require.Equal(t, c.status, res.Code, res.Body.String())
+			var body map[string]map[string]any
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+			require.Equal(t, c.code, body["error"]["code"])
+		})
+	}
+}
+
+// TestVehicleDefault_RoutesCovered tests that routeCases and exportCases have a request for every route of the router
+func TestVehicleDefault_RoutesCovered(t *testing.T) {
+	// arrange
+	rt := newRouter()
+	routes := make(map[string]bool)
+	require.NoError(t, chi.Walk(rt, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+strings.TrimSuffix(route, "/")] = false
+		return nil
+	}))
+
+	// act
+	cover := func(method string, target string) {
+		rctx := chi.NewRouteContext()
+		path, _, _ := strings.Cut(target, "?")
+		require.True(t, rt.Match(rctx, method, path), method+" "+target)
+		routes[method+" "+strings.TrimSuffix(rctx.RoutePattern(), "/")] = true
+	}
+	for _, c := range routeCases {
+		cover(c.method, c.target)
+	}
+	for _, c := range exportCases {
+		cover(http.MethodGet, c.target)
+	}
+
+	// assert
+	for route, covered := range routes {
+		require.True(t, covered, route)
+	}
+}
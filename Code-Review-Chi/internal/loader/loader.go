@@ -0,0 +1,108 @@
+package loader
+
+import (
+	"app/internal"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedFormat is an error that represents a vehicles file format without loader
+	ErrUnsupportedFormat = errors.New("unsupported vehicles file format")
+)
+
+const (
+	// FormatJSON is the format of a JSON array of vehicles
+	FormatJSON = "json"
+	// FormatCSV is the format of a CSV file with a header row
+	FormatCSV = "csv"
+	// FormatYAML is the format of a YAML sequence of vehicles
+	FormatYAML = "yaml"
+)
+
+// Format is a function that returns the format of a vehicles file by the extension of its path
+func Format(path string) (format string, err error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		format = FormatJSON
+	case ".csv":
+		format = FormatCSV
+	case ".yaml", ".yml":
+		format = FormatYAML
+	default:
+		err = fmt.Errorf("%w: extension %q of %s", ErrUnsupportedFormat, ext, path)
+	}
+
+	return
+}
+
+// NewVehicleLoader is a function that returns the loader of the vehicles file in path
+// format is one of FormatJSON, FormatCSV or FormatYAML, or empty to choose it by the extension of path
+// nm is the normalizer the fuel type and transmission are parsed with, nil means one with the default synonyms
+func NewVehicleLoader(path string, format string, nm *internal.TextNormalizer) (ld internal.VehicleLoader, err error) {
+	if format == "" {
+		if format, err = Format(path); err != nil {
+			return
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		ld = NewVehicleJSONFile(path, nm)
+	case FormatCSV:
+		ld = NewVehicleCSVFile(path, nm)
+	case FormatYAML, "yml":
+		ld = NewVehicleYAMLFile(path, nm)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	return
+}
+
+// newVehicleSet is a function that returns a new instance of vehicleSet
+func newVehicleSet(path string) *vehicleSet {
+	return &vehicleSet{
+		v:     make(map[int]internal.Vehicle),
+		lines: make(map[int]int),
+		err:   internal.LoadError{Path: path},
+	}
+}
+
+// vehicleSet is a struct that collects the vehicles of a file by id, reporting the invalid records
+type vehicleSet struct {
+	// v are the vehicles, by id
+	v map[int]internal.Vehicle
+	// lines are the lines where the vehicles start, by id
+	lines map[int]int
+	// err has the invalid records
+	err internal.LoadError
+}
+
+// add is a method that adds the vehicle of the record that starts at line
+// the vehicle is reported instead if its id is not positive or was already added
+func (s *vehicleSet) add(line int, v internal.Vehicle) {
+	if v.Id <= 0 {
+		s.err.Add(line, fmt.Sprintf("id %d must be a positive integer", v.Id))
+		return
+	}
+	if first, ok := s.lines[v.Id]; ok {
+		s.err.Add(line, fmt.Sprintf("duplicate id %d, first defined at line %d", v.Id, first))
+		return
+	}
+
+	s.v[v.Id] = v
+	s.lines[v.Id] = line
+}
+
+// result is a method that returns the vehicles, or the invalid records if there is any
+func (s *vehicleSet) result() (v map[int]internal.Vehicle, err error) {
+	if err = s.err.Err(); err != nil {
+		return
+	}
+
+	v = s.v
+	return
+}
@@ -0,0 +1,125 @@
+package loader_test
+
+import (
+	"app/internal"
+	"app/internal/loader"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile is a function that writes content to a file named name in a temporary directory, returning its path
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// TestNewVehicleLoader tests that every format loads the same vehicles
+func TestNewVehicleLoader(t *testing.T) {
+	expected := map[int]internal.Vehicle{
+		1: {Id: 1, VehicleAttributes: internal.VehicleAttributes{Brand: "Ford", Registration: "A-1", FabricationYear: 2001, MaxSpeed: 120.5, FuelType: "gas"}},
+		2: {Id: 2, VehicleAttributes: internal.VehicleAttributes{Brand: "GMC", Registration: "B-2", FabricationYear: 1999, MaxSpeed: 90, FuelType: "diesel"}},
+	}
+	cases := []struct {
+		name    string
+		file    string
+		format  string
+		content string
+	}{
+		{
+			name: "json", file: "vehicles.json",
+			content: `[{"id":1,"brand":"Ford","registration":"A-1","year":2001,"max_speed":120.5,"fuel_type":"gas"},
+{"id":2,"brand":"GMC","registration":"B-2","year":1999,"max_speed":90,"fuel_type":"diesel"}]`,
+		},
+		{
+			name: "csv", file: "vehicles.csv",
+			content: "ID,Brand,Plate,Fabrication Year,Max Speed,Fuel Type\n1,Ford,A-1,2001,120.5,Gas\n2,GMC,B-2,1999,90, DIESEL\n",
+		},
+		{
+			name: "yaml", file: "vehicles.yml",
+			content: "- id: 1\n  brand: Ford\n  registration: A-1\n  year: 2001\n  max_speed: 120.5\n  fuel_type: gas\n" +
+				"- id: 2\n  brand: GMC\n  registration: B-2\n  year: 1999\n  max_speed: 90\n  fuel_type: Diesel\n",
+		},
+		{
+			name: "explicit format", file: "vehicles.txt", format: "csv",
+			content: "id,brand,registration,year,max_speed,fuel_type\n1,Ford,A-1,2001,120.5,gas\n2,GMC,B-2,1999,90,diesel\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			ld, err := loader.NewVehicleLoader(writeFile(t, c.file, c.content), c.format, nil)
+			require.NoError(t, err)
+
+			// act
+			v, err := ld.Load()
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, expected, v)
+		})
+	}
+
+	t.Run("unknown extension", func(t *testing.T) {
+		// act
+		_, err := loader.NewVehicleLoader("vehicles.txt", "", nil)
+
+		// assert
+		require.ErrorIs(t, err, loader.ErrUnsupportedFormat)
+	})
+}
+
+// TestVehicleLoader_InvalidRecords tests that duplicate ids, malformed records and values of enums that are not allowed
+// are reported with their line
+func TestVehicleLoader_InvalidRecords(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		lines   []int
+	}{
+		{
+			name: "json", file: "vehicles.json",
+			content: "[\n{\"id\":1},\n{\"id\":\"two\"},\n{\"id\":1},\n{\"id\":3,\"speed\":1},\n{\"id\":4,\"fuel_type\":\"LPG\"}\n]",
+			lines:   []int{3, 4, 5, 6},
+		},
+		{
+			name: "csv", file: "vehicles.csv",
+			content: "id,brand,year,transmission\n1,Ford,2001,\n2,GMC,x,\n1,Ford,2001,\n3,Ford\n4,Ford,2001,cvt\n",
+			lines:   []int{3, 4, 5, 6},
+		},
+		{
+			name: "yaml", file: "vehicles.yaml",
+			content: "- id: 1\n- id: two\n- id: 1\n- id: 3\n  speed: 1\n- id: 4\n  fuel_type: LPG\n",
+			lines:   []int{2, 3, 5, 6},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			ld, err := loader.NewVehicleLoader(writeFile(t, c.file, c.content), "", nil)
+			require.NoError(t, err)
+
+			// act
+			v, err := ld.Load()
+
+			// assert
+			require.Nil(t, v)
+			require.ErrorIs(t, err, internal.ErrInvalidRecord)
+			var le *internal.LoadError
+			require.True(t, errors.As(err, &le))
+			lines := make([]int, len(le.Records))
+			for i, r := range le.Records {
+				lines[i] = r.Line
+			}
+			require.Equal(t, c.lines, lines)
+			require.Contains(t, le.Records[len(le.Records)-1].Message, "must be one of")
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"app/internal"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// NewVehicleCSVFile is a function that returns a new instance of VehicleCSVFile
+// nm is the normalizer the fuel type and transmission are parsed with, nil means one with the default synonyms
+func NewVehicleCSVFile(path string, nm *internal.TextNormalizer) *VehicleCSVFile {
+	return &VehicleCSVFile{
+		path: path,
+		nm:   nm,
+	}
+}
+
+// VehicleCSVFile is a struct that implements the LoaderVehicle interface
+type VehicleCSVFile struct {
+	// path is the path to the file that contains the vehicles in CSV format
+	path string
+	// nm is the normalizer the fuel type and transmission are parsed with
+	nm *internal.TextNormalizer
+}
+
+// Load is a method that loads the vehicles
+// the first record is the header, which maps each column to a field, see internal.VehicleFieldByHeader
+// records with a different number of fields than the header or values of the wrong type are reported
+func (l *VehicleCSVFile) Load() (v map[int]internal.Vehicle, err error) {
+	// open file
+	file, err := os.Open(l.path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	// header
+	rd := csv.NewReader(file)
+	rd.TrimLeadingSpace = true
+	header, err := rd.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: header: %v", internal.ErrInvalidRecord, l.path, err)
+	}
+
+	set := newVehicleSet(l.path)
+	fields := make([]string, len(header))
+	for i, h := range header {
+		field, ok := internal.VehicleFieldByHeader(h)
+		if !ok {
+			set.err.Add(1, fmt.Sprintf("column %d: %q is not a vehicle field", i+1, h))
+		}
+		fields[i] = field
+	}
+	if err = set.err.Err(); err != nil {
+		return nil, err
+	}
+
+	// records
+	for {
+		record, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			set.err.Add(parseErr.StartLine, parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := rd.FieldPos(0)
+		var vh internal.Vehicle
+		valid := true
+		for i, value := range record {
+			if err = vh.SetFieldText(fields[i], value, l.nm); err != nil {
+				set.err.Add(line, err.Error())
+				valid = false
+			}
+		}
+		if valid {
+			set.add(line, vh)
+		}
+	}
+
+	return set.result()
+}
@@ -2,14 +2,20 @@ package loader
 
 import (
 	"app/internal"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 )
 
 // NewVehicleJSONFile is a function that returns a new instance of VehicleJSONFile
-func NewVehicleJSONFile(path string) *VehicleJSONFile {
+// nm is the normalizer the fuel type and transmission are parsed with, nil means one with the default synonyms
+func NewVehicleJSONFile(path string, nm *internal.TextNormalizer) *VehicleJSONFile {
 	return &VehicleJSONFile{
 		path: path,
+		nm:   nm,
 	}
 }
 
@@ -17,66 +23,130 @@ func NewVehicleJSONFile(path string) *VehicleJSONFile {
 type VehicleJSONFile struct {
 	// path is the path to the file that contains the vehicles in JSON format
 	path string
+	// nm is the normalizer the fuel type and transmission are parsed with
+	nm *internal.TextNormalizer
 }
 
-// VehicleJSON is a struct that represents a vehicle in JSON format
+// VehicleJSON is a struct that represents a vehicle in JSON format, and YAML with the same keys
 type VehicleJSON struct {
-	Id              int     `json:"id"`
-	Brand           string  `json:"brand"`
-	Model           string  `json:"model"`
-	Registration    string  `json:"registration"`
-	Color           string  `json:"color"`
-	FabricationYear int     `json:"year"`
-	Capacity        int     `json:"passengers"`
-	MaxSpeed        float64 `json:"max_speed"`
-	FuelType        string  `json:"fuel_type"`
-	Transmission    string  `json:"transmission"`
-	Weight          float64 `json:"weight"`
-	Height          float64 `json:"height"`
-	Length          float64 `json:"length"`
-	Width           float64 `json:"width"`
+	Id              int     `json:"id" yaml:"id"`
+	Brand           string  `json:"brand" yaml:"brand"`
+	Model           string  `json:"model" yaml:"model"`
+	Registration    string  `json:"registration" yaml:"registration"`
+	Color           string  `json:"color" yaml:"color"`
+	FabricationYear int     `json:"year" yaml:"year"`
+	Capacity        int     `json:"passengers" yaml:"passengers"`
+	MaxSpeed        float64 `json:"max_speed" yaml:"max_speed"`
+	FuelType        string  `json:"fuel_type" yaml:"fuel_type"`
+	Transmission    string  `json:"transmission" yaml:"transmission"`
+	Weight          float64 `json:"weight" yaml:"weight"`
+	Height          float64 `json:"height" yaml:"height"`
+	Length          float64 `json:"length" yaml:"length"`
+	Width           float64 `json:"width" yaml:"width"`
+	// Version is the version of a vehicle stored by storer.VehicleJSONFile, absent in a new dataset
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+	// RetiredAt is when a vehicle stored by storer.VehicleJSONFile was retired, absent if it is in service
+	RetiredAt *time.Time `json:"retired_at,omitempty" yaml:"retired_at,omitempty"`
+}
+
+// toVehicle is a method that deserializes the JSON representation to a vehicle
+// the fuel type and transmission are parsed to their allowed value with nm, an error is returned for each one that is not allowed
+func (vh VehicleJSON) toVehicle(nm *internal.TextNormalizer) (v internal.Vehicle, errs []error) {
+	v = internal.Vehicle{
+		Id:      vh.Id,
+		Version: vh.Version,
+		VehicleAttributes: internal.VehicleAttributes{
+			Brand:           vh.Brand,
+			Model:           vh.Model,
+			Registration:    vh.Registration,
+			Color:           vh.Color,
+			FabricationYear: vh.FabricationYear,
+			Capacity:        vh.Capacity,
+			MaxSpeed:        vh.MaxSpeed,
+			Weight:          vh.Weight,
+			Dimensions: internal.Dimensions{
+				Height: vh.Height,
+				Length: vh.Length,
+				Width:  vh.Width,
+			},
+		},
+	}
+	if vh.RetiredAt != nil {
+		v.RetiredAt = vh.RetiredAt.UTC()
+	}
+	if err := v.SetFieldText("fuel_type", vh.FuelType, nm); err != nil {
+		errs = append(errs, err)
+	}
+	if err := v.SetFieldText("transmission", vh.Transmission, nm); err != nil {
+		errs = append(errs, err)
+	}
+
+	return
 }
 
 // Load is a method that loads the vehicles
+// the file is a JSON array of vehicles, records with unknown fields or values of the wrong type are reported
 func (l *VehicleJSONFile) Load() (v map[int]internal.Vehicle, err error) {
-	// open file
-	file, err := os.Open(l.path)
+	// read file
+	data, err := os.ReadFile(l.path)
 	if err != nil {
 		return
 	}
-	defer file.Close()
 
-	// decode file
-	var vehiclesJSON []VehicleJSON
-	err = json.NewDecoder(file).Decode(&vehiclesJSON)
-	if err != nil {
-		return
+	// decode the array a record at a time, so each one can be reported with its line
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, fmt.Errorf("%w: %s: line %d: the file must be a JSON array", internal.ErrInvalidRecord, l.path, lineAt(data, dec.InputOffset()))
 	}
 
-	// serialize vehicles
-	v = make(map[int]internal.Vehicle)
-	for _, vh := range vehiclesJSON {
-		v[vh.Id] = internal.Vehicle{
-			Id: vh.Id,
-			VehicleAttributes: internal.VehicleAttributes{
-				Brand:           vh.Brand,
-				Model:           vh.Model,
-				Registration:    vh.Registration,
-				Color:           vh.Color,
-				FabricationYear: vh.FabricationYear,
-				Capacity:        vh.Capacity,
-				MaxSpeed:        vh.MaxSpeed,
-				FuelType:        vh.FuelType,
-				Transmission:    vh.Transmission,
-				Weight:          vh.Weight,
-				Dimensions: internal.Dimensions{
-					Height: vh.Height,
-					Length: vh.Length,
-					Width:  vh.Width,
-				},
-			},
+	set := newVehicleSet(l.path)
+	for dec.More() {
+		line := lineAt(data, dec.InputOffset())
+
+		// - a record that is not valid JSON stops the decoding, the rest of the file can not be read
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line = lineAt(data, syntaxErr.Offset)
+			}
+			set.err.Add(line, err.Error())
+			return set.result()
+		}
+
+		var vh VehicleJSON
+		rd := json.NewDecoder(bytes.NewReader(raw))
+		rd.DisallowUnknownFields()
+		if err = rd.Decode(&vh); err != nil {
+			set.err.Add(line, err.Error())
+			continue
 		}
+		vehicle, errs := vh.toVehicle(l.nm)
+		if len(errs) > 0 {
+			for _, e := range errs {
+				set.err.Add(line, e.Error())
+			}
+			continue
+		}
+		set.add(line, vehicle)
+	}
+	if _, err = dec.Token(); err != nil {
+		set.err.Add(lineAt(data, dec.InputOffset()), "the JSON array is not closed")
 	}
 
-	return
+	return set.result()
+}
+
+// lineAt is a function that returns the line of the first value at or after offset, starting at 1
+// separators between values (spaces and commas) are skipped
+func lineAt(data []byte, offset int64) int {
+	for offset < int64(len(data)) && bytes.IndexByte([]byte(" \t\r\n,"), data[offset]) >= 0 {
+		offset++
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
 }
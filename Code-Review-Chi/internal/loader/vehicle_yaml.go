@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"app/internal"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NewVehicleYAMLFile is a function that returns a new instance of VehicleYAMLFile
+// nm is the normalizer the fuel type and transmission are parsed with, nil means one with the default synonyms
+func NewVehicleYAMLFile(path string, nm *internal.TextNormalizer) *VehicleYAMLFile {
+	return &VehicleYAMLFile{
+		path: path,
+		nm:   nm,
+	}
+}
+
+// VehicleYAMLFile is a struct that implements the LoaderVehicle interface
+type VehicleYAMLFile struct {
+	// path is the path to the file that contains the vehicles in YAML format
+	path string
+	// nm is the normalizer the fuel type and transmission are parsed with
+	nm *internal.TextNormalizer
+}
+
+// Load is a method that loads the vehicles
+// the file is a YAML sequence of vehicles with the keys of VehicleJSON, records with
+// unknown keys or values of the wrong type are reported
+func (l *VehicleYAMLFile) Load() (v map[int]internal.Vehicle, err error) {
+	// read file
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return
+	}
+
+	// parse the document, so each record can be reported with its line
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", internal.ErrInvalidRecord, l.path, err)
+	}
+	set := newVehicleSet(l.path)
+	if len(doc.Content) == 0 {
+		return set.result()
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%w: %s: line %d: the file must be a YAML sequence", internal.ErrInvalidRecord, l.path, root.Line)
+	}
+
+	for _, record := range root.Content {
+		if record.Kind != yaml.MappingNode {
+			set.err.Add(record.Line, "the record must be a mapping")
+			continue
+		}
+
+		valid := true
+		for i := 0; i < len(record.Content); i += 2 {
+			key := record.Content[i]
+			if field, ok := internal.VehicleFieldByHeader(key.Value); !ok || field != key.Value {
+				set.err.Add(key.Line, fmt.Sprintf("unknown field %q", key.Value))
+				valid = false
+			}
+		}
+
+		var vh VehicleJSON
+		if err = record.Decode(&vh); err != nil {
+			set.err.Add(record.Line, err.Error())
+			continue
+		}
+		vehicle, errs := vh.toVehicle(l.nm)
+		for _, e := range errs {
+			set.err.Add(record.Line, e.Error())
+		}
+		if valid && len(errs) == 0 {
+			set.add(record.Line, vehicle)
+		}
+	}
+
+	return set.result()
+}
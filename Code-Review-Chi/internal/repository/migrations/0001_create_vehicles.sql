@@ -0,0 +1,32 @@
+-- vehicles, text fields are stored as given and, for filtering, by the key of the normalizer
+CREATE TABLE vehicles (
+	id               INTEGER PRIMARY KEY,
+	brand            TEXT    NOT NULL,
+	brand_key        TEXT    NOT NULL,
+	model            TEXT    NOT NULL,
+	model_key        TEXT    NOT NULL,
+	registration     TEXT    NOT NULL,
+	registration_key TEXT    NOT NULL,
+	color            TEXT    NOT NULL,
+	color_key        TEXT    NOT NULL,
+	fuel_type        TEXT    NOT NULL,
+	fuel_type_key    TEXT    NOT NULL,
+	transmission     TEXT    NOT NULL,
+	transmission_key TEXT    NOT NULL,
+	year             INTEGER NOT NULL,
+	passengers       INTEGER NOT NULL,
+	max_speed        REAL    NOT NULL,
+	weight           REAL    NOT NULL,
+	height           REAL    NOT NULL,
+	length           REAL    NOT NULL,
+	width            REAL    NOT NULL
+);
+
+-- registrations are not unique: the seed data has repeated plates, the lowest id owns a plate
+CREATE INDEX vehicles_registration_key ON vehicles (registration_key, id);
+CREATE INDEX vehicles_brand_key ON vehicles (brand_key);
+CREATE INDEX vehicles_color_key ON vehicles (color_key, year);
+CREATE INDEX vehicles_fuel_type_key ON vehicles (fuel_type_key);
+CREATE INDEX vehicles_transmission_key ON vehicles (transmission_key);
+CREATE INDEX vehicles_year ON vehicles (year);
+CREATE INDEX vehicles_weight ON vehicles (weight);
@@ -0,0 +1,2 @@
+-- version of each vehicle, incremented by every update; the vehicles that existed start at 1
+ALTER TABLE vehicles ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
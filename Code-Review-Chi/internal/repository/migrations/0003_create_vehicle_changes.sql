@@ -0,0 +1,13 @@
+-- the change log of the vehicles, rows are only inserted
+-- fields and snapshot are JSON, and timestamp is RFC 3339 in UTC
+CREATE TABLE vehicle_changes (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	vehicle_id INTEGER NOT NULL,
+	operation  TEXT    NOT NULL,
+	fields     TEXT    NOT NULL,
+	snapshot   TEXT    NOT NULL,
+	actor      TEXT    NOT NULL,
+	timestamp  TEXT    NOT NULL
+);
+
+CREATE INDEX vehicle_changes_vehicle_id ON vehicle_changes (vehicle_id, id);
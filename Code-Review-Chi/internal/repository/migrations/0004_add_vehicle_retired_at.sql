@@ -0,0 +1,4 @@
+-- retired vehicles are kept, retired_at is when they were retired (RFC 3339 in UTC) or NULL if they are in service
+ALTER TABLE vehicles ADD COLUMN retired_at TEXT;
+
+CREATE INDEX vehicles_retired_at ON vehicles (retired_at);
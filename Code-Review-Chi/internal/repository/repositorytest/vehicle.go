@@ -0,0 +1,1059 @@
+// Package repositorytest implements a conformance suite for implementations of internal.VehicleRepository
+package repositorytest
+
+import (
+	"app/internal"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Factory is a function that returns a new repository holding the vehicles of db
+// the repository must compare text fields with the default synonyms
+type Factory func(t *testing.T, db map[int]internal.Vehicle) internal.VehicleRepository
+
+// Vehicles is a function that returns the vehicles every test of the suite starts with
+//   - 1 and 2 are Fords and 3 and 4 Toyotas
+//   - 1 and 3 are red and from 2010
+//   - 1 and 4 are manual, 2 and 3 automatic
+//   - 1 and 3 run on gasoline, 2 and 4 on diesel
+func Vehicles() map[int]internal.Vehicle {
+	vehicle := func(id int, brand, model, registration, color string, year, passengers int, speed float64, fuel internal.FuelType, transmission internal.Transmission, weight, height, length, width float64) internal.Vehicle {
+		return internal.Vehicle{
+			Id:      id,
+			Version: 1,
+			VehicleAttributes: internal.VehicleAttributes{
+				Brand:           brand,
+				Model:           model,
+				Registration:    registration,
+				Color:           color,
+				FabricationYear: year,
+				Capacity:        passengers,
+				MaxSpeed:        speed,
+				FuelType:        fuel,
+				Transmission:    transmission,
+				Weight:          weight,
+				Dimensions:      internal.Dimensions{Height: height, Length: length, Width: width},
+			},
+		}
+	}
+
+	return map[int]internal.Vehicle{
+		1: vehicle(1, "Ford", "Fiesta", "AAA-111", "Red", 2010, 5, 180, internal.FuelTypeGasoline, internal.TransmissionManual, 1100, 1.5, 4.0, 1.7),
+		2: vehicle(2, "Ford", "Focus", "BBB-222", "Blue", 2015, 5, 200, internal.FuelTypeDiesel, internal.TransmissionAutomatic, 1300, 1.5, 4.4, 1.8),
+		3: vehicle(3, "Toyota", "Corolla", "CCC-333", "Red", 2010, 5, 190, internal.FuelTypeGasoline, internal.TransmissionAutomatic, 1250, 1.4, 4.6, 1.8),
+		4: vehicle(4, "Toyota", "Hilux", "DDD-444", "White", 2020, 2, 170, internal.FuelTypeDiesel, internal.TransmissionManual, 2100, 1.8, 5.3, 1.9),
+	}
+}
+
+// subset is a function that returns the vehicles of Vehicles with the given ids
+func subset(ids ...int) map[int]internal.Vehicle {
+	db := Vehicles()
+	v := make(map[int]internal.Vehicle, len(ids))
+	for _, id := range ids {
+		v[id] = db[id]
+	}
+
+	return v
+}
+
+// newVehicle is a function that returns a vehicle that is not in Vehicles
+func newVehicle() internal.Vehicle {
+	v := Vehicles()[1]
+	v.Id, v.Registration, v.Model = 5, "EEE-555", "Ka"
+	return v
+}
+
+// errObserver is the error of an observer that fails
+var errObserver = errors.New("observer failed")
+
+// observation is a struct that represents a change of a vehicle received by an observer, see observer
+type observation struct {
+	op            internal.ChangeOperation
+	id            int
+	beforeVersion int
+	afterVersion  int
+	retired       bool
+}
+
+// observer is a struct that implements internal.VehicleObserver recording the changes it receives
+// err is the error returned by Observe
+type observer struct {
+	observed []observation
+	err      error
+}
+
+// Observe is a method that records a change
+func (o *observer) Observe(ctx context.Context, op internal.ChangeOperation, before *internal.Vehicle, after internal.Vehicle) error {
+	c := observation{op: op, id: after.Id, afterVersion: after.Version, retired: after.Retired()}
+	if before != nil {
+		c.beforeVersion = before.Version
+	}
+	o.observed = append(o.observed, c)
+	return o.err
+}
+
+// TestVehicleRepository is a function that runs the conformance suite against the repositories returned by factory
+// every test gets a new repository holding Vehicles
+func TestVehicleRepository(t *testing.T, factory Factory) {
+	t.Helper()
+
+	t.Run("FindAll", func(t *testing.T) {
+		// arrange
+		rp := factory(t, Vehicles())
+
+		// act
+		v, err := rp.FindAll(context.Background(), internal.ExcludeRetired)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, Vehicles(), v)
+	})
+
+	t.Run("FindById", func(t *testing.T) {
+		t.Run("found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			v, err := rp.FindById(context.Background(), 3, internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, Vehicles()[3], v)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			_, err := rp.FindById(context.Background(), 99, internal.ExcludeRetired)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+	})
+
+	t.Run("FindByRegistration", func(t *testing.T) {
+		t.Run("found, case insensitive", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			v, err := rp.FindByRegistration(context.Background(), " bbb-222 ", internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, Vehicles()[2], v)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			_, err := rp.FindByRegistration(context.Background(), "ZZZ-999", internal.ExcludeRetired)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+	})
+
+	t.Run("FindByFilter", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			filter   internal.VehicleFilter
+			expected map[int]internal.Vehicle
+		}{
+			{name: "empty filter", filter: nil, expected: Vehicles()},
+			{name: "text", filter: internal.VehicleFilter{internal.TextEq("brand", "ford")}, expected: subset(1, 2)},
+			{name: "synonym", filter: internal.VehicleFilter{internal.TextEq("fuel_type", "Petrol")}, expected: subset(1, 3)},
+			{name: "range", filter: internal.VehicleFilter{internal.NumberGte("weight", 1200), internal.NumberLte("weight", 1300)}, expected: subset(2, 3)},
+			{name: "text and number", filter: internal.VehicleFilter{internal.TextEq("color", "red"), internal.NumberEq("year", 2010)}, expected: subset(1, 3)},
+			{name: "no match", filter: internal.VehicleFilter{internal.TextEq("brand", "Tesla")}, expected: map[int]internal.Vehicle{}},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				// arrange
+				rp := factory(t, Vehicles())
+
+				// act
+				v, err := rp.FindByFilter(context.Background(), c.filter, internal.ExcludeRetired)
+
+				// assert
+				require.NoError(t, err)
+				require.Equal(t, c.expected, v)
+			})
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		// arrange
+		rp := factory(t, Vehicles())
+		q := internal.VehicleQuery{
+			Filter: internal.VehicleFilter{internal.NumberLte("year", 2015)},
+			Sort:   []internal.VehicleSort{{Field: "year", Desc: true}, {Field: "brand"}},
+			Offset: 1,
+			Limit:  1,
+		}
+
+		// act
+		v, total, err := rp.Query(context.Background(), q)
+
+		// assert
+		// - 2 (2015), then 1 (2010, Ford) and 3 (2010, Toyota)
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Equal(t, []internal.Vehicle{Vehicles()[1]}, v)
+	})
+
+	t.Run("Each", func(t *testing.T) {
+		// many is a function that returns n vehicles with ids from 1 to n, of 7 years and the brands of Vehicles,
+		// so there are many ties in the sort keys
+		many := func(n int) map[int]internal.Vehicle {
+			db := make(map[int]internal.Vehicle, n)
+			for id := 1; id <= n; id++ {
+				v := Vehicles()[id%4+1]
+				v.Id, v.Registration, v.FabricationYear = id, fmt.Sprintf("R-%04d", id), 2000+id%7
+				db[id] = v
+			}
+			return db
+		}
+		// each is a function that returns the vehicles fn is called with
+		each := func(rp internal.VehicleRepository, q internal.VehicleQuery) (v []internal.Vehicle, err error) {
+			v = []internal.Vehicle{}
+			err = rp.Each(context.Background(), q, func(vh internal.Vehicle) error {
+				v = append(v, vh)
+				return nil
+			})
+			return
+		}
+
+		cases := []struct {
+			name string
+			db   map[int]internal.Vehicle
+			q    internal.VehicleQuery
+		}{
+			{
+				name: "page of the query",
+				db:   Vehicles(),
+				q: internal.VehicleQuery{
+					Filter: internal.VehicleFilter{internal.NumberLte("year", 2015)},
+					Sort:   []internal.VehicleSort{{Field: "year", Desc: true}, {Field: "brand"}},
+					Offset: 1,
+					Limit:  1,
+				},
+			},
+			{
+				name: "every vehicle",
+				db:   many(1203),
+				q:    internal.VehicleQuery{Sort: []internal.VehicleSort{{Field: "year", Desc: true}, {Field: "brand"}}},
+			},
+			{
+				name: "page across many vehicles",
+				db:   many(1203),
+				q: internal.VehicleQuery{
+					Filter: internal.VehicleFilter{internal.NumberGte("year", 2001)},
+					Sort:   []internal.VehicleSort{{Field: "brand", Desc: true}, {Field: "max_speed"}},
+					Offset: 7,
+					Limit:  900,
+				},
+			},
+			{
+				name: "offset after the last vehicle",
+				db:   Vehicles(),
+				q:    internal.VehicleQuery{Offset: 4},
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				// arrange
+				rp := factory(t, c.db)
+				expected, _, err := rp.Query(context.Background(), c.q)
+				require.NoError(t, err)
+
+				// act
+				v, err := each(rp, c.q)
+
+				// assert
+				// - the vehicles of the page of Query, in its order
+				require.NoError(t, err)
+				require.Equal(t, expected, v)
+			})
+		}
+
+		t.Run("error of fn", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			calls := 0
+
+			// act
+			err := rp.Each(context.Background(), internal.VehicleQuery{}, func(v internal.Vehicle) error {
+				calls++
+				return errObserver
+			})
+
+			// assert
+			require.ErrorIs(t, err, errObserver)
+			require.Equal(t, 1, calls)
+		})
+	})
+
+	t.Run("AddVehicle", func(t *testing.T) {
+		t.Run("added", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.AddVehicle(context.Background(), newVehicle())
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, newVehicle(), v)
+		})
+
+		t.Run("text is cleaned", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := newVehicle()
+			vehicle.Brand = "  Ford   Motor "
+
+			// act
+			err := rp.AddVehicle(context.Background(), vehicle)
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, "Ford Motor", v.Brand)
+		})
+
+		t.Run("duplicated id", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := newVehicle()
+			vehicle.Id = 1
+
+			// act
+			err := rp.AddVehicle(context.Background(), vehicle)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleAlreadyExists)
+			v, _ := rp.FindAll(context.Background(), internal.ExcludeRetired)
+			require.Equal(t, Vehicles(), v)
+		})
+
+		t.Run("duplicated registration", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := newVehicle()
+			vehicle.Registration = "aaa-111"
+
+			// act
+			err := rp.AddVehicle(context.Background(), vehicle)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+			_, err = rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+	})
+
+	t.Run("AddVehicles", func(t *testing.T) {
+		// batch is a function that returns a batch with a new vehicle, a duplicated id,
+		// another new vehicle and a registration already used earlier in the batch
+		batch := func() []internal.Vehicle {
+			a, b := newVehicle(), newVehicle()
+			b.Id, b.Registration = 6, "FFF-666"
+			c := newVehicle()
+			c.Id = 7
+			return []internal.Vehicle{a, Vehicles()[2], b, c}
+		}
+		expected := []error{nil, internal.ErrVehicleAlreadyExists, nil, internal.ErrVehicleRegistrationAlreadyExists}
+
+		t.Run("atomic", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			errs, err := rp.AddVehicles(context.Background(), batch(), true)
+
+			// assert
+			require.NoError(t, err)
+			require.Len(t, errs, 4)
+			for i := range expected {
+				require.ErrorIs(t, errs[i], expected[i], i)
+			}
+			v, _ := rp.FindAll(context.Background(), internal.ExcludeRetired)
+			require.Equal(t, Vehicles(), v)
+		})
+
+		t.Run("best effort", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			errs, err := rp.AddVehicles(context.Background(), batch(), false)
+
+			// assert
+			require.NoError(t, err)
+			require.Len(t, errs, 4)
+			for i := range expected {
+				require.ErrorIs(t, errs[i], expected[i], i)
+			}
+			v, _ := rp.FindAll(context.Background(), internal.ExcludeRetired)
+			require.Len(t, v, 6)
+			require.Equal(t, batch()[0], v[5])
+			require.Equal(t, batch()[2], v[6])
+		})
+
+		t.Run("all added", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			errs, err := rp.AddVehicles(context.Background(), batch()[:1], true)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, []error{nil}, errs)
+			_, err = rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("UpdateVehicle", func(t *testing.T) {
+		t.Run("updated", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := Vehicles()[2]
+			vehicle.Color, vehicle.MaxSpeed = "Green", 210
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), vehicle, 1)
+
+			// assert
+			require.NoError(t, err)
+			vehicle.Version = 2
+			v, err := rp.FindById(context.Background(), 2, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, vehicle, v)
+			found, err := rp.FindByFilter(context.Background(), internal.VehicleFilter{internal.TextEq("color", "green")}, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, map[int]internal.Vehicle{2: vehicle}, found)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), newVehicle(), internal.VersionAny)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("outdated version", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := Vehicles()[2]
+			vehicle.Color = "Green"
+			require.NoError(t, rp.UpdateVehicle(context.Background(), vehicle, 1))
+			vehicle.Color = "Black"
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), vehicle, 1)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleVersionMismatch)
+			v, _ := rp.FindById(context.Background(), 2, internal.ExcludeRetired)
+			require.Equal(t, "Green", v.Color)
+			require.Equal(t, 2, v.Version)
+		})
+
+		t.Run("any version", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := Vehicles()[2]
+			require.NoError(t, rp.UpdateVehicle(context.Background(), vehicle, 1))
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), vehicle, internal.VersionAny)
+
+			// assert
+			require.NoError(t, err)
+			v, _ := rp.FindById(context.Background(), 2, internal.ExcludeRetired)
+			require.Equal(t, 3, v.Version)
+		})
+
+		t.Run("registration of another vehicle", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := Vehicles()[2]
+			vehicle.Registration = "CCC-333"
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), vehicle, 1)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+			v, _ := rp.FindById(context.Background(), 2, internal.ExcludeRetired)
+			require.Equal(t, Vehicles()[2], v)
+		})
+	})
+
+	t.Run("UpdatePartials", func(t *testing.T) {
+		t.Run("updated", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 4, 1, map[string]interface{}{"max_speed": 175.5, "registration": "GGG-777"})
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 4, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, 2, v.Version)
+			require.Equal(t, 175.5, v.MaxSpeed)
+			require.Equal(t, "GGG-777", v.Registration)
+			v, err = rp.FindByRegistration(context.Background(), "GGG-777", internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, 4, v.Id)
+			_, err = rp.FindByRegistration(context.Background(), "DDD-444", internal.ExcludeRetired)
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 99, internal.VersionAny, map[string]interface{}{"max_speed": 175.5})
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("outdated version", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			require.NoError(t, rp.UpdatePartials(context.Background(), 4, 1, map[string]interface{}{"max_speed": 175.5}))
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 4, 1, map[string]interface{}{"max_speed": 160.0})
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleVersionMismatch)
+			v, _ := rp.FindById(context.Background(), 4, internal.ExcludeRetired)
+			require.Equal(t, 175.5, v.MaxSpeed)
+			require.Equal(t, 2, v.Version)
+		})
+
+		t.Run("registration of another vehicle", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 4, 1, map[string]interface{}{"registration": "AAA-111"})
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+			v, _ := rp.FindById(context.Background(), 4, internal.ExcludeRetired)
+			require.Equal(t, Vehicles()[4], v)
+		})
+	})
+
+	t.Run("shared registration not owned", func(t *testing.T) {
+		// arrange
+		// - 5 was loaded with the plate of 1, which owns it
+		shared := func() map[int]internal.Vehicle {
+			db := Vehicles()
+			vehicle := newVehicle()
+			vehicle.Registration = "aaa-111"
+			db[5] = vehicle
+			return db
+		}
+
+		t.Run("UpdateVehicle", func(t *testing.T) {
+			// arrange
+			rp := factory(t, shared())
+			vehicle := shared()[5]
+			vehicle.Color = "Green"
+
+			// act
+			err := rp.UpdateVehicle(context.Background(), vehicle, 1)
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, "Green", v.Color)
+			v, err = rp.FindByRegistration(context.Background(), "AAA-111", internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, 1, v.Id)
+		})
+
+		t.Run("UpdatePartials", func(t *testing.T) {
+			// arrange
+			rp := factory(t, shared())
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 5, 1, map[string]interface{}{"max_speed": 175.5, "registration": "AAA-111"})
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 5, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, 175.5, v.MaxSpeed)
+		})
+
+		t.Run("to the plate of another vehicle", func(t *testing.T) {
+			// arrange
+			rp := factory(t, shared())
+
+			// act
+			err := rp.UpdatePartials(context.Background(), 5, 1, map[string]interface{}{"registration": "BBB-222"})
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+		})
+	})
+
+	t.Run("DeleteVehicle", func(t *testing.T) {
+		t.Run("deleted", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.DeleteVehicle(context.Background(), 1, 1)
+
+			// assert
+			require.NoError(t, err)
+			_, err = rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+			_, err = rp.FindByRegistration(context.Background(), "AAA-111", internal.ExcludeRetired)
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+			v, err := rp.FindByColorAndYear(context.Background(), "Red", 2010, internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, subset(3), v)
+			retired, err := rp.FindById(context.Background(), 1, internal.IncludeRetired)
+			require.NoError(t, err)
+			require.True(t, retired.Retired())
+			require.Equal(t, 2, retired.Version)
+		})
+
+		t.Run("already retired", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			require.NoError(t, rp.DeleteVehicle(context.Background(), 1, internal.VersionAny))
+
+			// act
+			err := rp.DeleteVehicle(context.Background(), 1, internal.VersionAny)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("registration is free again", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := newVehicle()
+			vehicle.Registration = "AAA-111"
+
+			// act
+			require.NoError(t, rp.DeleteVehicle(context.Background(), 1, internal.VersionAny))
+			err := rp.AddVehicle(context.Background(), vehicle)
+
+			// assert
+			require.NoError(t, err)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.DeleteVehicle(context.Background(), 99, internal.VersionAny)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("outdated version", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			require.NoError(t, rp.UpdatePartials(context.Background(), 1, 1, map[string]interface{}{"color": "Green"}))
+
+			// act
+			err := rp.DeleteVehicle(context.Background(), 1, 1)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleVersionMismatch)
+			_, err = rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("RestoreVehicle", func(t *testing.T) {
+		t.Run("restored", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			require.NoError(t, rp.DeleteVehicle(context.Background(), 1, internal.VersionAny))
+
+			// act
+			err := rp.RestoreVehicle(context.Background(), 1)
+
+			// assert
+			require.NoError(t, err)
+			v, err := rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+			require.NoError(t, err)
+			expected := Vehicles()[1]
+			expected.Version = 3
+			require.Equal(t, expected, v)
+			v, err = rp.FindByRegistration(context.Background(), "AAA-111", internal.ExcludeRetired)
+			require.NoError(t, err)
+			require.Equal(t, 1, v.Id)
+		})
+
+		t.Run("not retired", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.RestoreVehicle(context.Background(), 1)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotRetired)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+
+			// act
+			err := rp.RestoreVehicle(context.Background(), 99)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("registration taken", func(t *testing.T) {
+			// arrange
+			rp := factory(t, Vehicles())
+			vehicle := newVehicle()
+			vehicle.Registration = "AAA-111"
+			require.NoError(t, rp.DeleteVehicle(context.Background(), 1, internal.VersionAny))
+			require.NoError(t, rp.AddVehicle(context.Background(), vehicle))
+
+			// act
+			err := rp.RestoreVehicle(context.Background(), 1)
+
+			// assert
+			require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+			_, err = rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+			require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+		})
+	})
+
+	t.Run("retired vehicles", func(t *testing.T) {
+		// arrange
+		rp := factory(t, Vehicles())
+		require.NoError(t, rp.DeleteVehicle(context.Background(), 1, internal.VersionAny))
+
+		t.Run("skipped by default", func(t *testing.T) {
+			// act
+			all, errAll := rp.FindAll(context.Background(), internal.ExcludeRetired)
+			_, total, errQuery := rp.Query(context.Background(), internal.VehicleQuery{Filter: internal.VehicleFilter{internal.TextEq("brand", "Ford")}})
+			average, errAverage := rp.GetAverageSpeedByBrand(context.Background(), "Ford", internal.ExcludeRetired)
+			_, errPlate := rp.FindByRegistration(context.Background(), "AAA-111", internal.ExcludeRetired)
+			_, errId := rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+			byColor, errColor := rp.FindByColorAndYear(context.Background(), "Red", 2010, internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, errAll)
+			require.Equal(t, subset(2, 3, 4), all)
+			require.ErrorIs(t, errId, internal.ErrVehicleNotFound)
+			require.NoError(t, errColor)
+			require.Equal(t, subset(3), byColor)
+			require.NoError(t, errQuery)
+			require.Equal(t, 1, total)
+			require.NoError(t, errAverage)
+			require.Equal(t, 200.0, average)
+			require.ErrorIs(t, errPlate, internal.ErrVehicleNotFound)
+		})
+
+		t.Run("read with IncludeRetired", func(t *testing.T) {
+			// act
+			ctx := context.Background()
+			all, errAll := rp.FindAll(ctx, internal.IncludeRetired)
+			_, total, errQuery := rp.Query(ctx, internal.VehicleQuery{Filter: internal.VehicleFilter{internal.TextEq("brand", "Ford")}, Retired: internal.IncludeRetired})
+			average, errAverage := rp.GetAverageSpeedByBrand(ctx, "Ford", internal.IncludeRetired)
+			byPlate, errPlate := rp.FindByRegistration(ctx, "AAA-111", internal.IncludeRetired)
+			byId, errId := rp.FindById(ctx, 1, internal.IncludeRetired)
+			byColor, errColor := rp.FindByColorAndYear(ctx, "Red", 2010, internal.IncludeRetired)
+
+			// assert
+			require.NoError(t, errId)
+			require.True(t, byId.Retired())
+			require.NoError(t, errColor)
+			require.Len(t, byColor, 2)
+			require.NoError(t, errAll)
+			require.Len(t, all, 4)
+			require.True(t, all[1].Retired())
+			require.NoError(t, errQuery)
+			require.Equal(t, 2, total)
+			require.NoError(t, errAverage)
+			require.Equal(t, 190.0, average)
+			require.NoError(t, errPlate)
+			require.Equal(t, 1, byPlate.Id)
+		})
+
+		t.Run("not changed", func(t *testing.T) {
+			// act
+			errUpdate := rp.UpdateVehicle(context.Background(), Vehicles()[1], internal.VersionAny)
+			errPartials := rp.UpdatePartials(context.Background(), 1, internal.VersionAny, map[string]interface{}{"color": "Green"})
+
+			// assert
+			require.ErrorIs(t, errUpdate, internal.ErrVehicleNotFound)
+			require.ErrorIs(t, errPartials, internal.ErrVehicleNotFound)
+		})
+	})
+
+	// finders are the FindBy methods that return ErrVehiclesNotFound when no vehicle matches
+	finders := []struct {
+		name     string
+		find     func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error)
+		none     func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error)
+		expected map[int]internal.Vehicle
+	}{
+		{
+			name: "FindByColorAndYear",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByColorAndYear(context.Background(), "RED", 2010, internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByColorAndYear(context.Background(), "Red", 2015, internal.ExcludeRetired)
+			},
+			expected: subset(1, 3),
+		},
+		{
+			name: "FindByBrandAndYearRange",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByBrandAndYearRange(context.Background(), "toyota", 2010, 2020, internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByBrandAndYearRange(context.Background(), "Toyota", 2011, 2019, internal.ExcludeRetired)
+			},
+			expected: subset(3, 4),
+		},
+		{
+			name: "FindByFuelType",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByFuelType(context.Background(), "Diesel", internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByFuelType(context.Background(), "electric", internal.ExcludeRetired)
+			},
+			expected: subset(2, 4),
+		},
+		{
+			name: "FindByTransmissionType",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByTransmissionType(context.Background(), "auto", internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByTransmissionType(context.Background(), "semi-automatic", internal.ExcludeRetired)
+			},
+			expected: subset(2, 3),
+		},
+		{
+			name: "FindByDimensions",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByDimensions(context.Background(), 4.4, 5.3, 1.8, 1.8, internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByDimensions(context.Background(), 6, 7, 1, 2, internal.ExcludeRetired)
+			},
+			expected: subset(2, 3),
+		},
+		{
+			name: "FindByWeightRange",
+			find: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByWeightRange(context.Background(), 1100, 1250, internal.ExcludeRetired)
+			},
+			none: func(rp internal.VehicleRepository) (map[int]internal.Vehicle, error) {
+				return rp.FindByWeightRange(context.Background(), 3000, 4000, internal.ExcludeRetired)
+			},
+			expected: subset(1, 3),
+		},
+	}
+	for _, f := range finders {
+		t.Run(f.name, func(t *testing.T) {
+			t.Run("found", func(t *testing.T) {
+				// arrange
+				rp := factory(t, Vehicles())
+
+				// act
+				v, err := f.find(rp)
+
+				// assert
+				require.NoError(t, err)
+				require.Equal(t, f.expected, v)
+			})
+
+			t.Run("not found", func(t *testing.T) {
+				// arrange
+				rp := factory(t, Vehicles())
+
+				// act
+				_, err := f.none(rp)
+
+				// assert
+				require.ErrorIs(t, err, internal.ErrVehiclesNotFound)
+			})
+
+			t.Run("empty repository", func(t *testing.T) {
+				// arrange
+				rp := factory(t, map[int]internal.Vehicle{})
+
+				// act
+				_, err := f.find(rp)
+
+				// assert
+				require.ErrorIs(t, err, internal.ErrVehiclesNotFound)
+			})
+		})
+	}
+
+	// averages are the methods that return an average by brand
+	averages := []struct {
+		name     string
+		average  func(rp internal.VehicleRepository, ctx context.Context, brand string, retired internal.Retired) (float64, error)
+		expected float64
+	}{
+		{name: "GetAverageSpeedByBrand", average: internal.VehicleRepository.GetAverageSpeedByBrand, expected: 180},
+		{name: "GetAveragePassengersByBrand", average: internal.VehicleRepository.GetAveragePassengersByBrand, expected: 3.5},
+	}
+	for _, a := range averages {
+		t.Run(a.name, func(t *testing.T) {
+			t.Run("found", func(t *testing.T) {
+				// arrange
+				rp := factory(t, Vehicles())
+
+				// act
+				v, err := a.average(rp, context.Background(), " TOYOTA", internal.ExcludeRetired)
+
+				// assert
+				require.NoError(t, err)
+				require.InDelta(t, a.expected, v, 1e-9)
+			})
+
+			t.Run("not found", func(t *testing.T) {
+				// arrange
+				rp := factory(t, Vehicles())
+
+				// act
+				_, err := a.average(rp, context.Background(), "Tesla", internal.ExcludeRetired)
+
+				// assert
+				require.ErrorIs(t, err, internal.ErrVehiclesNotFound)
+			})
+		})
+	}
+
+	t.Run("SetObserver", func(t *testing.T) {
+		t.Run("changes in the order they are made", func(t *testing.T) {
+			// arrange
+			ctx := context.Background()
+			rp := factory(t, Vehicles())
+			ob := &observer{}
+			rp.SetObserver(ob)
+			batch := newVehicle()
+			batch.Id, batch.Registration = 6, "FFF-666"
+
+			// act
+			require.NoError(t, rp.AddVehicle(ctx, newVehicle()))
+			require.NoError(t, rp.UpdatePartials(ctx, 1, 1, map[string]interface{}{"max_speed": 175.5}))
+			require.NoError(t, rp.DeleteVehicle(ctx, 2, internal.VersionAny))
+			require.NoError(t, rp.RestoreVehicle(ctx, 2))
+			_, err := rp.AddVehicles(ctx, []internal.Vehicle{batch, Vehicles()[1], batch}, false)
+			require.NoError(t, err)
+			require.NoError(t, rp.UpdateVehicle(ctx, Vehicles()[3], internal.VersionAny))
+			require.Error(t, rp.UpdateVehicle(ctx, Vehicles()[4], 7))
+
+			// assert
+			// - the changes that failed are not observed
+			require.Equal(t, []observation{
+				{op: internal.ChangeCreate, id: 5, afterVersion: 1},
+				{op: internal.ChangeUpdate, id: 1, beforeVersion: 1, afterVersion: 2},
+				{op: internal.ChangeDelete, id: 2, beforeVersion: 1, afterVersion: 2, retired: true},
+				{op: internal.ChangeRestore, id: 2, beforeVersion: 2, afterVersion: 3},
+				{op: internal.ChangeCreate, id: 6, afterVersion: 1},
+				{op: internal.ChangeUpdate, id: 3, beforeVersion: 1, afterVersion: 2},
+			}, ob.observed)
+		})
+
+		t.Run("observer that fails", func(t *testing.T) {
+			// arrange
+			ctx := context.Background()
+			rp := factory(t, Vehicles())
+			failing, ob := &observer{err: errObserver}, &observer{}
+			rp.SetObserver(internal.VehicleObservers{failing, ob})
+			batch := newVehicle()
+			batch.Id, batch.Registration = 6, "FFF-666"
+
+			// act
+			errAdd := rp.AddVehicle(ctx, newVehicle())
+			_, errBatch := rp.AddVehicles(ctx, []internal.Vehicle{batch, Vehicles()[1]}, false)
+			errDelete := rp.DeleteVehicle(ctx, 5, internal.VersionAny)
+
+			// assert
+			// - the changes were made, so they do not fail, and every observer receives every change
+			require.NoError(t, errAdd)
+			require.NoError(t, errBatch)
+			require.NoError(t, errDelete)
+			expected := []observation{
+				{op: internal.ChangeCreate, id: 5, afterVersion: 1},
+				{op: internal.ChangeCreate, id: 6, afterVersion: 1},
+				{op: internal.ChangeDelete, id: 5, beforeVersion: 1, afterVersion: 2, retired: true},
+			}
+			require.Equal(t, expected, failing.observed)
+			require.Equal(t, expected, ob.observed)
+			_, err := rp.FindById(ctx, 6, internal.ExcludeRetired)
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		// arrange
+		rp := factory(t, Vehicles())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// act
+		_, errFindAll := rp.FindAll(ctx, internal.ExcludeRetired)
+		_, errFind := rp.FindByFilter(ctx, internal.VehicleFilter{internal.NumberGte("year", 2000)}, internal.ExcludeRetired)
+		_, _, errQuery := rp.Query(ctx, internal.VehicleQuery{})
+		errAdd := rp.AddVehicle(ctx, newVehicle())
+		errDelete := rp.DeleteVehicle(ctx, 1, internal.VersionAny)
+		v, err := rp.FindAll(context.Background(), internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, errFindAll, context.Canceled)
+		require.ErrorIs(t, errFind, context.Canceled)
+		require.ErrorIs(t, errQuery, context.Canceled)
+		require.ErrorIs(t, errAdd, context.Canceled)
+		require.ErrorIs(t, errDelete, context.Canceled)
+		require.NoError(t, err)
+		require.Equal(t, Vehicles(), v)
+	})
+}
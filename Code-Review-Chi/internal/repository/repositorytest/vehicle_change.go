@@ -0,0 +1,103 @@
+package repositorytest
+
+import (
+	"app/internal"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ChangeLogFactory is a function that returns a new change log without changes
+type ChangeLogFactory func(t *testing.T) internal.VehicleChangeLog
+
+// newChange is a function that returns the change of a vehicle with the given id and operation
+func newChange(id int, op internal.ChangeOperation) internal.VehicleChange {
+	v := Vehicles()[1]
+	v.Id = id
+
+	return internal.VehicleChange{
+		VehicleId: id,
+		Operation: op,
+		Fields:    []internal.FieldChange{{Field: "color", Before: "Red", After: "Blue"}},
+		Snapshot:  v,
+		Actor:     "alice",
+		Timestamp: time.Date(2024, 5, 1, 10, 30, 0, 123, time.UTC),
+	}
+}
+
+// TestVehicleChangeLog is a function that runs the conformance suite of internal.VehicleChangeLog
+// against the change logs returned by factory
+func TestVehicleChangeLog(t *testing.T, factory ChangeLogFactory) {
+	t.Helper()
+
+	t.Run("Append", func(t *testing.T) {
+		// arrange
+		lg := factory(t)
+
+		// act
+		first, err1 := lg.Append(context.Background(), newChange(1, internal.ChangeCreate))
+		second, err2 := lg.Append(context.Background(), newChange(2, internal.ChangeCreate))
+
+		// assert
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.Positive(t, first.Id)
+		require.Greater(t, second.Id, first.Id)
+	})
+
+	t.Run("FindByVehicle", func(t *testing.T) {
+		t.Run("in the order appended", func(t *testing.T) {
+			// arrange
+			lg := factory(t)
+			ops := []internal.ChangeOperation{internal.ChangeCreate, internal.ChangeUpdate, internal.ChangeDelete, internal.ChangeRestore}
+			for _, op := range ops {
+				_, err := lg.Append(context.Background(), newChange(1, op))
+				require.NoError(t, err)
+				_, err = lg.Append(context.Background(), newChange(2, op))
+				require.NoError(t, err)
+			}
+
+			// act
+			c, err := lg.FindByVehicle(context.Background(), 1)
+
+			// assert
+			require.NoError(t, err)
+			require.Len(t, c, len(ops))
+			for i, op := range ops {
+				expected := newChange(1, op)
+				expected.Id = c[i].Id
+				require.Equal(t, expected, c[i])
+			}
+		})
+
+		t.Run("no changes", func(t *testing.T) {
+			// arrange
+			lg := factory(t)
+
+			// act
+			c, err := lg.FindByVehicle(context.Background(), 1)
+
+			// assert
+			require.NoError(t, err)
+			require.Empty(t, c)
+		})
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		// arrange
+		lg := factory(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// act
+		_, err := lg.Append(ctx, newChange(1, internal.ChangeCreate))
+
+		// assert
+		require.ErrorIs(t, err, context.Canceled)
+		c, err := lg.FindByVehicle(context.Background(), 1)
+		require.NoError(t, err)
+		require.Empty(t, c)
+	})
+}
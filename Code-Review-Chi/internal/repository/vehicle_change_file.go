@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"app/internal"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// vehicleChangeRecord is a struct that represents a change of a vehicle as a line of a change log file
+type vehicleChangeRecord struct {
+	Id        int                   `json:"id"`
+	VehicleId int                   `json:"vehicle_id"`
+	Operation string                `json:"operation"`
+	Fields    []fieldChangeRecord   `json:"fields"`
+	Snapshot  vehicleSnapshotRecord `json:"snapshot"`
+	Actor     string                `json:"actor"`
+	Timestamp string                `json:"timestamp"`
+}
+
+// fieldChangeRecord is a struct that represents the change of a field in a change log
+type fieldChangeRecord struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// vehicleSnapshotRecord is a struct that represents the snapshot of a vehicle in a change log
+// the fuel type and transmission are kept as they were, so a value that is no longer allowed can still be read
+type vehicleSnapshotRecord struct {
+	Id              int        `json:"id"`
+	Version         int        `json:"version"`
+	RetiredAt       *time.Time `json:"retired_at,omitempty"`
+	Brand           string     `json:"brand"`
+	Model           string     `json:"model"`
+	Registration    string     `json:"registration"`
+	Color           string     `json:"color"`
+	FabricationYear int        `json:"year"`
+	Capacity        int        `json:"passengers"`
+	MaxSpeed        float64    `json:"max_speed"`
+	FuelType        string     `json:"fuel_type"`
+	Transmission    string     `json:"transmission"`
+	Weight          float64    `json:"weight"`
+	Height          float64    `json:"height"`
+	Length          float64    `json:"length"`
+	Width           float64    `json:"width"`
+}
+
+// newFieldChangeRecords is a function that returns the records of the changes of the fields
+func newFieldChangeRecords(f []internal.FieldChange) (r []fieldChangeRecord) {
+	if f == nil {
+		return nil
+	}
+	r = make([]fieldChangeRecord, len(f))
+	for i, fc := range f {
+		r[i] = fieldChangeRecord{Field: fc.Field, Before: fc.Before, After: fc.After}
+	}
+	return
+}
+
+// toFieldChanges is a function that returns the changes of the fields of their records
+func toFieldChanges(r []fieldChangeRecord) (f []internal.FieldChange) {
+	if r == nil {
+		return nil
+	}
+	f = make([]internal.FieldChange, len(r))
+	for i, fr := range r {
+		f[i] = internal.FieldChange{Field: fr.Field, Before: fr.Before, After: fr.After}
+	}
+	return
+}
+
+// newVehicleSnapshotRecord is a function that returns the record of the snapshot of a vehicle
+func newVehicleSnapshotRecord(v internal.Vehicle) (r vehicleSnapshotRecord) {
+	r = vehicleSnapshotRecord{
+		Id:              v.Id,
+		Version:         v.Version,
+		Brand:           v.Brand,
+		Model:           v.Model,
+		Registration:    v.Registration,
+		Color:           v.Color,
+		FabricationYear: v.FabricationYear,
+		Capacity:        v.Capacity,
+		MaxSpeed:        v.MaxSpeed,
+		FuelType:        string(v.FuelType),
+		Transmission:    string(v.Transmission),
+		Weight:          v.Weight,
+		Height:          v.Height,
+		Length:          v.Length,
+		Width:           v.Width,
+	}
+	if !v.RetiredAt.IsZero() {
+		retiredAt := v.RetiredAt.UTC()
+		r.RetiredAt = &retiredAt
+	}
+	return
+}
+
+// toVehicle is a method that returns the vehicle of the snapshot, with the fuel type and transmission as they were recorded
+func (r vehicleSnapshotRecord) toVehicle() (v internal.Vehicle) {
+	v = internal.Vehicle{
+		Id:      r.Id,
+		Version: r.Version,
+		VehicleAttributes: internal.VehicleAttributes{
+			Brand:           r.Brand,
+			Model:           r.Model,
+			Registration:    r.Registration,
+			Color:           r.Color,
+			FabricationYear: r.FabricationYear,
+			Capacity:        r.Capacity,
+			MaxSpeed:        r.MaxSpeed,
+			FuelType:        internal.FuelType(r.FuelType),
+			Transmission:    internal.Transmission(r.Transmission),
+			Weight:          r.Weight,
+			Dimensions: internal.Dimensions{
+				Height: r.Height,
+				Length: r.Length,
+				Width:  r.Width,
+			},
+		},
+	}
+	if r.RetiredAt != nil {
+		v.RetiredAt = r.RetiredAt.UTC()
+	}
+	return
+}
+
+// NewVehicleChangeFile is a function that returns a new instance of VehicleChangeFile
+// the changes already in the file at path are loaded, and the file is created if it does not exist
+// a last line left incomplete by a crash while it was appended is discarded
+func NewVehicleChangeFile(path string) (lg *VehicleChangeFile, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
+	lg = &VehicleChangeFile{VehicleChangeMap: NewVehicleChangeMap(), file: file}
+
+	// load
+	rd := bufio.NewReader(file)
+	var offset int64
+	for line := 1; ; line++ {
+		b, e := rd.ReadBytes('\n')
+		if errors.Is(e, io.EOF) {
+			// - an incomplete last line is truncated, so the next change starts a new line
+			if len(bytes.TrimSpace(b)) > 0 {
+				if err = file.Truncate(offset); err != nil {
+					return nil, fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+				}
+			}
+			break
+		}
+		if e != nil {
+			return nil, e
+		}
+		offset += int64(len(b))
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+
+		var c internal.VehicleChange
+		if c, err = decodeVehicleChange(b); err != nil {
+			return nil, fmt.Errorf("%w: %s: line %d: %v", internal.ErrUnmarshal, path, line, err)
+		}
+		lg.VehicleChangeMap.add(c)
+	}
+	if lg.size, err = file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+
+	return
+}
+
+// VehicleChangeFile is a struct that represents a change log kept in memory and persisted in a file,
+// one change per line in JSON format (NDJSON); the changes are only appended to the file
+// it is safe for concurrent use by multiple goroutines
+type VehicleChangeFile struct {
+	// VehicleChangeMap is the change log the changes are read from
+	*VehicleChangeMap
+	// mu serializes the appends, so the lines are written in the order of their ids
+	mu sync.Mutex
+	// file is the file the changes are appended to
+	file *os.File
+	// size is the size of the complete lines of the file
+	size int64
+}
+
+// Append is a method that adds a change at the end of the log, returning it with its id
+// the change is written and synced to the file before it is read from the log,
+// and a line that could not be written whole is removed, so the file only has complete lines
+func (r *VehicleChangeFile) Append(ctx context.Context, c internal.VehicleChange) (stored internal.VehicleChange, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.Id = r.VehicleChangeMap.nextId()
+	b, err := encodeVehicleChange(c)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	if _, err = r.file.Write(b); err == nil {
+		err = r.file.Sync()
+	}
+	if err != nil {
+		r.file.Truncate(r.size)
+		r.file.Seek(r.size, io.SeekStart)
+		return stored, fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	r.size += int64(len(b))
+
+	r.VehicleChangeMap.add(c)
+	return c, nil
+}
+
+// Close is a method that closes the file of the log
+func (r *VehicleChangeFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// encodeVehicleChange is a function that returns a change as a line of a change log file, without the line break
+func encodeVehicleChange(c internal.VehicleChange) (b []byte, err error) {
+	b, err = json.Marshal(vehicleChangeRecord{
+		Id:        c.Id,
+		VehicleId: c.VehicleId,
+		Operation: string(c.Operation),
+		Fields:    newFieldChangeRecords(c.Fields),
+		Snapshot:  newVehicleSnapshotRecord(c.Snapshot),
+		Actor:     c.Actor,
+		Timestamp: c.Timestamp.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrMarshal, err)
+	}
+	return
+}
+
+// decodeVehicleChange is a function that returns the change of a line of a change log file
+func decodeVehicleChange(b []byte) (c internal.VehicleChange, err error) {
+	var record vehicleChangeRecord
+	if err = json.Unmarshal(b, &record); err != nil {
+		return
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, record.Timestamp)
+	if err != nil {
+		return
+	}
+
+	return internal.VehicleChange{
+		Id:        record.Id,
+		VehicleId: record.VehicleId,
+		Operation: internal.ChangeOperation(record.Operation),
+		Fields:    toFieldChanges(record.Fields),
+		Snapshot:  record.Snapshot.toVehicle(),
+		Actor:     record.Actor,
+		Timestamp: timestamp,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"app/internal"
+	"context"
+	"sync"
+)
+
+// NewVehicleChangeMap is a function that returns a new instance of VehicleChangeMap, without changes
+func NewVehicleChangeMap() *VehicleChangeMap {
+	return &VehicleChangeMap{byVehicle: make(map[int][]internal.VehicleChange)}
+}
+
+// VehicleChangeMap is a struct that represents a change log kept in memory, lost when the process ends
+// it is safe for concurrent use by multiple goroutines
+type VehicleChangeMap struct {
+	// mu guards lastId and byVehicle
+	mu sync.RWMutex
+	// lastId is the id of the last change appended
+	lastId int
+	// byVehicle are the changes of each vehicle, in the order they were appended
+	byVehicle map[int][]internal.VehicleChange
+}
+
+// Append is a method that adds a change at the end of the log, returning it with its id
+func (r *VehicleChangeMap) Append(ctx context.Context, c internal.VehicleChange) (stored internal.VehicleChange, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.Id = r.lastId + 1
+	r.put(c)
+
+	return c, nil
+}
+
+// nextId is a method that returns the id of the next change appended
+func (r *VehicleChangeMap) nextId() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastId + 1
+}
+
+// add is a method that adds a change at the end of the log keeping its id, e.g. one loaded from a file
+func (r *VehicleChangeMap) add(c internal.VehicleChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.put(c)
+}
+
+// put is a method that adds a change with its id at the end of the log
+// the caller must hold the write lock
+func (r *VehicleChangeMap) put(c internal.VehicleChange) {
+	if c.Id > r.lastId {
+		r.lastId = c.Id
+	}
+	c.Fields = append([]internal.FieldChange(nil), c.Fields...)
+	r.byVehicle[c.VehicleId] = append(r.byVehicle[c.VehicleId], c)
+}
+
+// FindByVehicle is a method that returns the changes of a vehicle in the order they were appended
+func (r *VehicleChangeMap) FindByVehicle(ctx context.Context, id int) (c []internal.VehicleChange, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// copy the changes
+	c = make([]internal.VehicleChange, len(r.byVehicle[id]))
+	copy(c, r.byVehicle[id])
+
+	return
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"app/internal"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// NewVehicleChangeSQLite is a function that returns a new instance of VehicleChangeSQLite
+// the schema must be migrated with MigrateSQLite
+func NewVehicleChangeSQLite(db *sql.DB) *VehicleChangeSQLite {
+	return &VehicleChangeSQLite{db: db}
+}
+
+// VehicleChangeSQLite is a struct that represents a change log stored in the vehicle_changes table of a SQLite database
+type VehicleChangeSQLite struct {
+	// db is the database
+	db *sql.DB
+}
+
+// Append is a method that adds a change at the end of the log, returning it with its id
+func (r *VehicleChangeSQLite) Append(ctx context.Context, c internal.VehicleChange) (stored internal.VehicleChange, err error) {
+	fields, err := json.Marshal(newFieldChangeRecords(c.Fields))
+	if err != nil {
+		return
+	}
+	snapshot, err := json.Marshal(newVehicleSnapshotRecord(c.Snapshot))
+	if err != nil {
+		return
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO vehicle_changes (vehicle_id, operation, fields, snapshot, actor, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.VehicleId, string(c.Operation), string(fields), string(snapshot), c.Actor, c.Timestamp.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	c.Id = int(id)
+	return c, nil
+}
+
+// FindByVehicle is a method that returns the changes of a vehicle in the order they were appended
+func (r *VehicleChangeSQLite) FindByVehicle(ctx context.Context, id int) (c []internal.VehicleChange, err error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, vehicle_id, operation, fields, snapshot, actor, timestamp FROM vehicle_changes WHERE vehicle_id = ? ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	c = []internal.VehicleChange{}
+	for rows.Next() {
+		var change internal.VehicleChange
+		var operation, fields, snapshot, timestamp string
+		var fieldRecords []fieldChangeRecord
+		var snapshotRecord vehicleSnapshotRecord
+		if err = rows.Scan(&change.Id, &change.VehicleId, &operation, &fields, &snapshot, &change.Actor, &timestamp); err != nil {
+			return nil, err
+		}
+		change.Operation = internal.ChangeOperation(operation)
+		if err = json.Unmarshal([]byte(fields), &fieldRecords); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(snapshot), &snapshotRecord); err != nil {
+			return nil, err
+		}
+		change.Fields = toFieldChanges(fieldRecords)
+		change.Snapshot = snapshotRecord.toVehicle()
+		if change.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+			return nil, err
+		}
+		c = append(c, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return
+}
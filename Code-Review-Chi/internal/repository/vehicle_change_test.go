@@ -0,0 +1,187 @@
+package repository_test
+
+import (
+	"app/internal"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"app/internal/service"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleChangeMap_Contract tests that VehicleChangeMap satisfies the conformance suite of internal.VehicleChangeLog
+func TestVehicleChangeMap_Contract(t *testing.T) {
+	repositorytest.TestVehicleChangeLog(t, func(t *testing.T) internal.VehicleChangeLog {
+		return repository.NewVehicleChangeMap()
+	})
+}
+
+// TestVehicleChangeSQLite_Contract tests that VehicleChangeSQLite satisfies the conformance suite of internal.VehicleChangeLog
+func TestVehicleChangeSQLite_Contract(t *testing.T) {
+	repositorytest.TestVehicleChangeLog(t, func(t *testing.T) internal.VehicleChangeLog {
+		sq, err := sql.Open("sqlite", ":memory:")
+		require.NoError(t, err)
+		// every connection to :memory: is a different database
+		sq.SetMaxOpenConns(1)
+		t.Cleanup(func() { sq.Close() })
+		require.NoError(t, repository.MigrateSQLite(context.Background(), sq))
+
+		return repository.NewVehicleChangeSQLite(sq)
+	})
+}
+
+// TestVehicleChangeFile_Contract tests that VehicleChangeFile satisfies the conformance suite of internal.VehicleChangeLog
+func TestVehicleChangeFile_Contract(t *testing.T) {
+	repositorytest.TestVehicleChangeLog(t, func(t *testing.T) internal.VehicleChangeLog {
+		lg, err := repository.NewVehicleChangeFile(filepath.Join(t.TempDir(), "vehicles.changes.ndjson"))
+		require.NoError(t, err)
+		t.Cleanup(func() { lg.Close() })
+
+		return lg
+	})
+}
+
+// TestVehicleChangeFile_Reopen tests that the changes of a log file are loaded when it is opened again
+func TestVehicleChangeFile_Reopen(t *testing.T) {
+	change := internal.VehicleChange{
+		VehicleId: 1,
+		Operation: internal.ChangeUpdate,
+		Fields:    []internal.FieldChange{{Field: "max_speed", Before: "100", After: "120"}},
+		Snapshot:  repositorytest.Vehicles()[1],
+		Actor:     "alice",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+
+	t.Run("changes of a previous run", func(t *testing.T) {
+		// arrange
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "vehicles.changes.ndjson")
+		lg, err := repository.NewVehicleChangeFile(path)
+		require.NoError(t, err)
+		_, err = lg.Append(ctx, change)
+		require.NoError(t, err)
+		require.NoError(t, lg.Close())
+
+		// act
+		lg, err = repository.NewVehicleChangeFile(path)
+		require.NoError(t, err)
+		defer lg.Close()
+		next, err := lg.Append(ctx, change)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 2, next.Id)
+		c, err := lg.FindByVehicle(ctx, 1)
+		require.NoError(t, err)
+		change.Id = 1
+		require.Equal(t, []internal.VehicleChange{change, next}, c)
+	})
+
+	t.Run("incomplete last line", func(t *testing.T) {
+		// arrange
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "vehicles.changes.ndjson")
+		lg, err := repository.NewVehicleChangeFile(path)
+		require.NoError(t, err)
+		_, err = lg.Append(ctx, change)
+		require.NoError(t, err)
+		require.NoError(t, lg.Close())
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"id":2,"vehicle_id":1,"oper`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		// act
+		lg, err = repository.NewVehicleChangeFile(path)
+		require.NoError(t, err)
+		defer lg.Close()
+		next, err := lg.Append(ctx, change)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 2, next.Id)
+		require.NoError(t, lg.Close())
+		lg, err = repository.NewVehicleChangeFile(path)
+		require.NoError(t, err)
+		defer lg.Close()
+		c, err := lg.FindByVehicle(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, c, 2)
+	})
+
+	t.Run("malformed line", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(t.TempDir(), "vehicles.changes.ndjson")
+		require.NoError(t, os.WriteFile(path, []byte("{}\nnot json\n"), 0o644))
+
+		// act
+		_, err := repository.NewVehicleChangeFile(path)
+
+		// assert
+		require.ErrorIs(t, err, internal.ErrUnmarshal)
+	})
+}
+
+// TestVehicleChange_ValuesNotAllowed tests that the delete of a vehicle whose fuel type and transmission are not allowed,
+// e.g. one loaded before they were validated, is read back as it was recorded when the log is opened again
+func TestVehicleChange_ValuesNotAllowed(t *testing.T) {
+	cases := []struct {
+		name string
+		open func(t *testing.T, path string) internal.VehicleChangeLog
+	}{
+		{
+			name: "file",
+			open: func(t *testing.T, path string) internal.VehicleChangeLog {
+				lg, err := repository.NewVehicleChangeFile(path)
+				require.NoError(t, err)
+				t.Cleanup(func() { lg.Close() })
+
+				return lg
+			},
+		},
+		{
+			name: "sqlite",
+			open: func(t *testing.T, path string) internal.VehicleChangeLog {
+				sq, err := sql.Open("sqlite", path)
+				require.NoError(t, err)
+				t.Cleanup(func() { sq.Close() })
+				require.NoError(t, repository.MigrateSQLite(context.Background(), sq))
+
+				return repository.NewVehicleChangeSQLite(sq)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "vehicles.changes")
+			vehicles := repositorytest.Vehicles()
+			vh := vehicles[1]
+			vh.FuelType, vh.Transmission = "LPG", "cvt"
+			vehicles[1] = vh
+			rp := repository.NewVehicleMap(vehicles, nil)
+			rp.SetObserver(service.NewVehicleAudit(nil, c.open(t, path)))
+
+			// act
+			require.NoError(t, rp.DeleteVehicle(ctx, 1, internal.VersionAny))
+			ch, err := c.open(t, path).FindByVehicle(ctx, 1)
+
+			// assert
+			require.NoError(t, err)
+			require.Len(t, ch, 1)
+			require.Equal(t, internal.ChangeDelete, ch[0].Operation)
+			require.Equal(t, internal.FuelType("LPG"), ch[0].Snapshot.FuelType)
+			require.Equal(t, internal.Transmission("cvt"), ch[0].Snapshot.Transmission)
+			require.Contains(t, ch[0].Fields, internal.FieldChange{Field: "fuel_type", Before: "LPG", After: ""})
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"app/internal"
+	"context"
+	"sync"
+)
+
+// NewVehicleFile is a function that returns a new instance of VehicleFile
+func NewVehicleFile(rp *VehicleMap, st internal.VehicleStorer) *VehicleFile {
+	return &VehicleFile{
+		VehicleMap: rp,
+		st:         st,
+	}
+}
+
+// VehicleFile is a struct that represents a write-through vehicle repository
+// reads are served by the wrapped repository, and after every successful
+// mutation the whole content of the repository is stored with the storer
+// a mutation that can not be stored is reverted, so the repository never holds changes the file does not,
+// and the changes are observed once they are stored, see internal.VehicleObserver
+type VehicleFile struct {
+	// VehicleMap is the repository that holds the vehicles, without observer
+	*VehicleMap
+	// vehicleObserver is the observer of the changes, called under the lock of mu
+	vehicleObserver
+	// st is the storer used to persist the vehicles
+	st internal.VehicleStorer
+	// mu serializes mutations so snapshots are stored in the same order they were made
+	mu sync.Mutex
+}
+
+// store is a method that persists the current content of the repository, including the retired vehicles
+// it is not canceled with ctx, so a mutation already made is always persisted
+func (r *VehicleFile) store(ctx context.Context) (err error) {
+	v, err := r.VehicleMap.FindAll(context.WithoutCancel(ctx), internal.IncludeRetired)
+	if err != nil {
+		return
+	}
+
+	err = r.st.Store(v)
+	return
+}
+
+// change is a method that makes a mutation op of the vehicles with the given ids with fn and stores the repository
+// if the repository can not be stored, the vehicles are reverted to how they were before fn,
+// otherwise the change of every vehicle whose version changed is observed, in the order of ids
+// changed is false for a mutation that changed no vehicle, which is not stored
+func (r *VehicleFile) change(ctx context.Context, op internal.ChangeOperation, ids []int, fn func() (changed bool, err error)) (err error) {
+	previous := r.VehicleMap.lookup(ids)
+
+	changed, err := fn()
+	if err != nil || !changed {
+		return
+	}
+
+	if err = r.store(ctx); err != nil {
+		r.VehicleMap.revert(ids, previous)
+		return
+	}
+
+	current := r.VehicleMap.lookup(ids)
+	for _, id := range ids {
+		after, ok := current[id]
+		if !ok {
+			continue
+		}
+		// - an id repeated in a batch is observed once
+		delete(current, id)
+		var before *internal.Vehicle
+		if v, ok := previous[id]; ok {
+			if v.Version == after.Version {
+				continue
+			}
+			before = &v
+		}
+		r.observe(ctx, op, before, after)
+	}
+	return
+}
+
+// AddVehicle is a method that adds a vehicle to the repository
+func (r *VehicleFile) AddVehicle(ctx context.Context, v internal.Vehicle) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.change(ctx, internal.ChangeCreate, []int{v.Id}, func() (bool, error) {
+		return true, r.VehicleMap.AddVehicle(ctx, v)
+	})
+}
+
+// AddVehicles is a method that adds vehicles to the repository
+// the file is only written if some vehicle was added
+func (r *VehicleFile) AddVehicles(ctx context.Context, v []internal.Vehicle, atomic bool) (errs []error, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int, len(v))
+	for i, vehicle := range v {
+		ids[i] = vehicle.Id
+	}
+
+	err = r.change(ctx, internal.ChangeCreate, ids, func() (changed bool, err error) {
+		errs, err = r.VehicleMap.AddVehicles(ctx, v, atomic)
+		if err != nil {
+			return
+		}
+
+		added := 0
+		for _, e := range errs {
+			if e == nil {
+				added++
+			}
+		}
+		changed = added > 0 && (!atomic || added == len(errs))
+		return
+	})
+	if err != nil {
+		errs = nil
+	}
+	return
+}
+
+// DeleteVehicle is a method that retires a vehicle of the repository
+func (r *VehicleFile) DeleteVehicle(ctx context.Context, id int, version int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.change(ctx, internal.ChangeDelete, []int{id}, func() (bool, error) {
+		return true, r.VehicleMap.DeleteVehicle(ctx, id, version)
+	})
+}
+
+// RestoreVehicle is a method that puts a retired vehicle of the repository back in service
+func (r *VehicleFile) RestoreVehicle(ctx context.Context, id int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.change(ctx, internal.ChangeRestore, []int{id}, func() (bool, error) {
+		return true, r.VehicleMap.RestoreVehicle(ctx, id)
+	})
+}
+
+// UpdatePartials is a method that updates some fields of a vehicle
+func (r *VehicleFile) UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.change(ctx, internal.ChangeUpdate, []int{id}, func() (bool, error) {
+		return true, r.VehicleMap.UpdatePartials(ctx, id, version, partials)
+	})
+}
+
+// UpdateVehicle is a method that replaces a vehicle of the repository
+func (r *VehicleFile) UpdateVehicle(ctx context.Context, v internal.Vehicle, version int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.change(ctx, internal.ChangeUpdate, []int{v.Id}, func() (bool, error) {
+		return true, r.VehicleMap.UpdateVehicle(ctx, v, version)
+	})
+}
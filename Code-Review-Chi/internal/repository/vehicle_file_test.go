@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"app/internal"
+	"app/internal/loader"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"app/internal/storer"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleFile_Contract tests that VehicleFile satisfies the conformance suite of internal.VehicleRepository
+func TestVehicleFile_Contract(t *testing.T) {
+	repositorytest.TestVehicleRepository(t, func(t *testing.T, db map[int]internal.Vehicle) internal.VehicleRepository {
+		st := storer.NewVehicleJSONFile(filepath.Join(t.TempDir(), "vehicles.json"))
+		return repository.NewVehicleFile(repository.NewVehicleMap(db, nil), st)
+	})
+}
+
+// TestVehicleFile_Store tests that the vehicles are stored after every change
+func TestVehicleFile_Store(t *testing.T) {
+	// arrange
+	path := filepath.Join(t.TempDir(), "vehicles.json")
+	rp := repository.NewVehicleFile(repository.NewVehicleMap(repositorytest.Vehicles(), nil), storer.NewVehicleJSONFile(path))
+	vehicle := repositorytest.Vehicles()[1]
+	vehicle.Id, vehicle.Registration = 5, "EEE-555"
+
+	// act
+	require.NoError(t, rp.AddVehicle(context.Background(), vehicle))
+	require.NoError(t, rp.UpdatePartials(context.Background(), 2, internal.VersionAny, map[string]interface{}{"color": "Green"}))
+	require.NoError(t, rp.DeleteVehicle(context.Background(), 3, internal.VersionAny))
+
+	// assert
+	stored, err := loader.NewVehicleJSONFile(path, nil).Load()
+	require.NoError(t, err)
+	expected, err := rp.FindAll(context.Background(), internal.IncludeRetired)
+	require.NoError(t, err)
+	require.Equal(t, expected, stored)
+	require.Len(t, stored, 5)
+	require.True(t, stored[3].Retired())
+}
+
+// storerFunc is a function that implements internal.VehicleStorer
+type storerFunc func(v map[int]internal.Vehicle) error
+
+// Store is a method that stores the vehicles with the function
+func (f storerFunc) Store(v map[int]internal.Vehicle) error {
+	return f(v)
+}
+
+// TestVehicleFile_StoreFailed tests that a change that can not be stored is reverted
+func TestVehicleFile_StoreFailed(t *testing.T) {
+	// arrange
+	errStore := errors.New("disk full")
+	rp := repository.NewVehicleFile(repository.NewVehicleMap(repositorytest.Vehicles(), nil), storerFunc(func(map[int]internal.Vehicle) error {
+		return errStore
+	}))
+	added := repositorytest.Vehicles()[1]
+	added.Id, added.Registration = 5, "EEE-555"
+	batch := []internal.Vehicle{added, repositorytest.Vehicles()[2]}
+	batch[1].Id, batch[1].Registration = 6, "FFF-666"
+
+	// act
+	errAdd := rp.AddVehicle(context.Background(), added)
+	_, errBatch := rp.AddVehicles(context.Background(), batch, false)
+	errPartials := rp.UpdatePartials(context.Background(), 2, internal.VersionAny, map[string]interface{}{"color": "Green", "registration": "GGG-777"})
+	errDelete := rp.DeleteVehicle(context.Background(), 3, internal.VersionAny)
+
+	// assert
+	for _, err := range []error{errAdd, errBatch, errPartials, errDelete} {
+		require.ErrorIs(t, err, errStore)
+	}
+	v, err := rp.FindAll(context.Background(), internal.IncludeRetired)
+	require.NoError(t, err)
+	require.Equal(t, repositorytest.Vehicles(), v)
+	owner, err := rp.FindByRegistration(context.Background(), "BBB-222", internal.ExcludeRetired)
+	require.NoError(t, err)
+	require.Equal(t, 2, owner.Id)
+	_, err = rp.FindByRegistration(context.Background(), "GGG-777", internal.ExcludeRetired)
+	require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+	found, err := rp.FindByFilter(context.Background(), internal.VehicleFilter{internal.TextEq("color", "green")}, internal.ExcludeRetired)
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
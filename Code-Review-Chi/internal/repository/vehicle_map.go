@@ -2,59 +2,220 @@ package repository
 
 import (
 	"app/internal"
-	"fmt"
+	"context"
+	"sort"
+	"sync"
+	"time"
 )
 
 // NewVehicleMap is a function that returns a new instance of VehicleMap
-func NewVehicleMap(db map[int]internal.Vehicle) *VehicleMap {
+// the text fields of the vehicles are cleaned, and compared with the normalizer
+// nil nm means a normalizer with the default synonyms
+func NewVehicleMap(db map[int]internal.Vehicle, nm *internal.TextNormalizer) *VehicleMap {
 	// default db
 	defaultDb := make(map[int]internal.Vehicle)
 	if db != nil {
 		defaultDb = db
 	}
-	return &VehicleMap{db: defaultDb}
+	for id, v := range defaultDb {
+		v = internal.CleanVehicle(v)
+		if v.Version == 0 {
+			v.Version = 1
+		}
+		defaultDb[id] = v
+	}
+	// default normalizer
+	if nm == nil {
+		nm = internal.NewTextNormalizer(nil)
+	}
+
+	r := &VehicleMap{db: defaultDb, nm: nm}
+	r.reindex()
+	return r
 }
 
 // VehicleMap is a struct that represents a vehicle repository
+// it is safe for concurrent use by multiple goroutines
 type VehicleMap struct {
+	// vehicleObserver is the observer of the changes, called under the exclusive lock of mu
+	vehicleObserver
+	// mu guards db: readers take a read lock, writers an exclusive lock
+	mu sync.RWMutex
 	// db is a map of vehicles
 	db map[int]internal.Vehicle
+	// nm is the normalizer used to compare text fields
+	nm *internal.TextNormalizer
+	// plates is an index of the ids of the vehicles by registration plate, retired ones included
+	plates map[string]map[int]struct{}
+	// hashes are indexes of the ids of the vehicles by field and value, for text fields
+	hashes map[string]map[string]map[int]struct{}
+	// ranges are indexes of the ids of the vehicles sorted by value, for numeric fields
+	ranges map[string]*rangeIndex
+}
+
+// scanCheckInterval is the number of vehicles a scan visits between checks of the cancellation of its context
+const scanCheckInterval = 256
+
+// canceled is a function that returns the error of ctx every scanCheckInterval vehicles of a scan, at position i
+func canceled(ctx context.Context, i int) error {
+	if i%scanCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
 }
 
 // FindAll is a method that returns a map of all vehicles
-func (r *VehicleMap) FindAll() (v map[int]internal.Vehicle, err error) {
+// the retired vehicles are included only if retired includes them
+func (r *VehicleMap) FindAll(ctx context.Context, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	v = make(map[int]internal.Vehicle)
 
 	// copy db
+	i := 0
 	for key, value := range r.db {
-		v[key] = value
+		if err = canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if retired.Visible(value) {
+			v[key] = value
+		}
+		i++
+	}
+
+	return
+}
+
+// find is a method that returns the vehicles matching the filter in a single pass
+// over the candidates of the most selective index, or over db if no index applies
+// the pass stops with the error of ctx if it is canceled, and skips the retired vehicles unless retired includes them
+// the caller must hold the lock
+func (r *VehicleMap) find(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	v = make(map[int]internal.Vehicle)
+
+	ids, ok := r.candidates(f)
+	if !ok {
+		i := 0
+		for key, value := range r.db {
+			if err = canceled(ctx, i); err != nil {
+				return nil, err
+			}
+			if retired.Visible(value) && f.MatchNormalized(value, r.nm) {
+				v[key] = value
+			}
+			i++
+		}
+		return
+	}
+
+	for i, id := range ids {
+		if err = canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if value := r.db[id]; retired.Visible(value) && f.MatchNormalized(value, r.nm) {
+			v[id] = value
+		}
+	}
+
+	return
+}
+
+// FindByFilter is a method that returns the vehicles matching every condition of the filter
+func (r *VehicleMap) FindByFilter(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.find(ctx, f, retired)
+}
+
+// Query is a method that returns a page of the vehicles matching the query, in the query order
+// total is the number of matching vehicles before pagination
+func (r *VehicleMap) Query(ctx context.Context, q internal.VehicleQuery) (v []internal.Vehicle, total int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// filter
+	found, err := r.find(ctx, q.Filter, q.Retired)
+	if err != nil {
+		return
+	}
+	for _, value := range found {
+		v = append(v, value)
 	}
 
+	// sort
+	sort.Slice(v, func(i, j int) bool {
+		return q.Less(v[i], v[j])
+	})
+
+	// paginate
+	total = len(v)
+	start, end := q.Page(total)
+	v = v[start:end]
+
+	return
+}
+
+// Each is a method that calls fn with every vehicle of the page of the query, in the query order
+// the page is read at once, as Query, so fn is called without the lock and sees the vehicles as they were
+func (r *VehicleMap) Each(ctx context.Context, q internal.VehicleQuery, fn func(v internal.Vehicle) error) (err error) {
+	v, _, err := r.Query(ctx, q)
+	if err != nil {
+		return
+	}
+
+	for i, value := range v {
+		if err = canceled(ctx, i); err != nil {
+			return
+		}
+		if err = fn(value); err != nil {
+			return
+		}
+	}
 	return
 }
 
 // AddVehicle is a method that adds a vehicle to the repository
-func (r *VehicleMap) AddVehicle(v internal.Vehicle) error {
+func (r *VehicleMap) AddVehicle(ctx context.Context, v internal.Vehicle) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v = internal.CleanVehicle(v)
+	v.Version, v.RetiredAt = 1, time.Time{}
+
 	// verify if vehicle already exists in the repository
 	if _, ok := r.db[v.Id]; ok {
 		return internal.ErrVehicleAlreadyExists
 	}
+	if r.registrationTaken(v.Registration, v.Id) {
+		return internal.ErrVehicleRegistrationAlreadyExists
+	}
 
 	// add vehicle to the repository
 	r.db[v.Id] = v
+	r.index(v)
 
+	r.observe(ctx, internal.ChangeCreate, nil, v)
 	return nil
 }
 
 // FindByColorAndYear is a method that returns a map of vehicles by color and year
-func (r *VehicleMap) FindByColorAndYear(color string, year int) (v map[int]internal.Vehicle, err error) {
-	v = make(map[int]internal.Vehicle)
+func (r *VehicleMap) FindByColorAndYear(ctx context.Context, color string, year int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	// search vehicles by color and year
-	for key, value := range r.db {
-		if value.Color == color && value.FabricationYear == year {
-			v[key] = value
-		}
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.TextEq("color", color),
+		internal.NumberEq("year", float64(year)),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -65,15 +226,18 @@ func (r *VehicleMap) FindByColorAndYear(color string, year int) (v map[int]inter
 }
 
 // FindByBrandAndYearRange is a method that returns a map of vehicles by brand and year range
-func (r *VehicleMap) FindByBrandAndYearRange(brand string, startYear int, endYear int) (v map[int]internal.Vehicle, err error) {
-	v = make(map[int]internal.Vehicle)
+func (r *VehicleMap) FindByBrandAndYearRange(ctx context.Context, brand string, startYear int, endYear int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	fmt.Println("len of db:", len(r.db))
 	// search vehicles by brand and year range
-	for key, value := range r.db {
-		if value.Brand == brand && value.FabricationYear >= startYear && value.FabricationYear <= endYear {
-			v[key] = value
-		}
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.TextEq("brand", brand),
+		internal.NumberGte("year", float64(startYear)),
+		internal.NumberLte("year", float64(endYear)),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -84,16 +248,21 @@ func (r *VehicleMap) FindByBrandAndYearRange(brand string, startYear int, endYea
 }
 
 // GetAverageSpeedByBrand is a method that returns the average speed of vehicles by brand
-func (r *VehicleMap) GetAverageSpeedByBrand(brand string) (averageSpeed float64, err error) {
+func (r *VehicleMap) GetAverageSpeedByBrand(ctx context.Context, brand string, retired internal.Retired) (averageSpeed float64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var totalSpeed float64
 	var totalVehicles int
 
 	// search vehicles by brand
-	for _, value := range r.db {
-		if value.Brand == brand {
-			totalSpeed += value.MaxSpeed
-			totalVehicles++
-		}
+	found, err := r.find(ctx, internal.VehicleFilter{internal.TextEq("brand", brand)}, retired)
+	if err != nil {
+		return
+	}
+	for _, value := range found {
+		totalSpeed += value.MaxSpeed
+		totalVehicles++
 	}
 
 	if totalVehicles == 0 {
@@ -106,25 +275,76 @@ func (r *VehicleMap) GetAverageSpeedByBrand(brand string) (averageSpeed float64,
 }
 
 // AddVehicles is a method that adds vehicles to the repository
-func (r *VehicleMap) AddVehicles(v []internal.Vehicle) error {
-	for _, vehicle := range v {
-		err := r.AddVehicle(vehicle)
-		if err != nil {
-			return err
+// a vehicle is a duplicate if its id or registration already exists, or
+// appears earlier in the batch; if atomic is true and any vehicle is a
+// duplicate, no vehicle is added
+func (r *VehicleMap) AddVehicles(ctx context.Context, v []internal.Vehicle, atomic bool) (errs []error, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// verify every vehicle before adding any of them
+	v = append([]internal.Vehicle(nil), v...)
+	errs = make([]error, len(v))
+	failed := false
+	ids := make(map[int]struct{}, len(v))
+	registrations := make(map[string]struct{}, len(v))
+	for i, vehicle := range v {
+		vehicle = internal.CleanVehicle(vehicle)
+		vehicle.Version, vehicle.RetiredAt = 1, time.Time{}
+		v[i] = vehicle
+
+		_, exists := r.db[vehicle.Id]
+		_, repeated := ids[vehicle.Id]
+		plate := r.plate(vehicle.Registration)
+		_, repeatedPlate := registrations[plate]
+		switch {
+		case exists || repeated:
+			errs[i] = internal.ErrVehicleAlreadyExists
+		case repeatedPlate || r.registrationTaken(vehicle.Registration, vehicle.Id):
+			errs[i] = internal.ErrVehicleRegistrationAlreadyExists
+		default:
+			ids[vehicle.Id] = struct{}{}
+			registrations[plate] = struct{}{}
+			continue
 		}
+		failed = true
+	}
+	if atomic && failed {
+		return
 	}
 
-	return nil
+	// add vehicles to the repository
+	for i, vehicle := range v {
+		if errs[i] != nil {
+			continue
+		}
+		r.db[vehicle.Id] = vehicle
+		r.index(vehicle)
+	}
+	for i, vehicle := range v {
+		if errs[i] != nil {
+			continue
+		}
+		r.observe(ctx, internal.ChangeCreate, nil, vehicle)
+	}
+
+	return
 }
 
-func (r *VehicleMap) FindByFuelType(fuelType string) (v map[int]internal.Vehicle, err error) {
-	v = make(map[int]internal.Vehicle)
+func (r *VehicleMap) FindByFuelType(ctx context.Context, fuelType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	// search vehicles by fuel type
-	for key, value := range r.db {
-		if value.FuelType == fuelType {
-			v[key] = value
-		}
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.TextEq("fuel_type", fuelType),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -134,26 +354,76 @@ func (r *VehicleMap) FindByFuelType(fuelType string) (v map[int]internal.Vehicle
 	return
 }
 
-func (r *VehicleMap) DeleteVehicle(id int) (err error) {
+// DeleteVehicle is a method that retires a vehicle of the repository
+func (r *VehicleMap) DeleteVehicle(ctx context.Context, id int, version int) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// verify if vehicle exists in the repository
-	if _, ok := r.db[id]; !ok {
+	previous, ok := r.db[id]
+	if !ok || previous.Retired() {
 		return internal.ErrVehicleNotFound
 	}
+	if !internal.VersionMatches(previous.Version, version) {
+		return internal.ErrVehicleVersionMismatch
+	}
 
-	delete(r.db, id)
+	vehicle := previous
+	vehicle.Version, vehicle.RetiredAt = previous.Version+1, time.Now().UTC()
+	r.db[id] = vehicle
+	r.unindex(previous)
+	r.index(vehicle)
 
+	r.observe(ctx, internal.ChangeDelete, &previous, vehicle)
 	return nil
 }
 
-func (r *VehicleMap) FindByTransmissionType(transmissionType string) (v map[int]internal.Vehicle, err error) {
+// RestoreVehicle is a method that puts a retired vehicle of the repository back in service
+// a retired vehicle owns no plate, so its plate is checked as the one of a new vehicle: another vehicle may have taken it
+func (r *VehicleMap) RestoreVehicle(ctx context.Context, id int) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
-	v = make(map[int]internal.Vehicle)
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	// search vehicles by fuel type
-	for key, value := range r.db {
-		if value.Transmission == transmissionType {
-			v[key] = value
-		}
+	// verify if vehicle is retired
+	previous, ok := r.db[id]
+	if !ok {
+		return internal.ErrVehicleNotFound
+	}
+	if !previous.Retired() {
+		return internal.ErrVehicleNotRetired
+	}
+	if r.registrationTaken(previous.Registration, id) {
+		return internal.ErrVehicleRegistrationAlreadyExists
+	}
+
+	vehicle := previous
+	vehicle.Version, vehicle.RetiredAt = previous.Version+1, time.Time{}
+	r.db[id] = vehicle
+	r.unindex(previous)
+	r.index(vehicle)
+
+	r.observe(ctx, internal.ChangeRestore, &previous, vehicle)
+	return nil
+}
+
+func (r *VehicleMap) FindByTransmissionType(ctx context.Context, transmissionType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// search vehicles by transmission type
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.TextEq("transmission", transmissionType),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -163,49 +433,116 @@ func (r *VehicleMap) FindByTransmissionType(transmissionType string) (v map[int]
 	return
 }
 
-func (r *VehicleMap) UpdatePartials(id int, partials map[string]interface{}) (err error) {
+func (r *VehicleMap) UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// verify if vehicle exists in the repository
 	vehicle, ok := r.db[id]
-	if !ok {
+	if !ok || vehicle.Retired() {
 		return internal.ErrVehicleNotFound
 	}
+	if !internal.VersionMatches(vehicle.Version, version) {
+		return internal.ErrVehicleVersionMismatch
+	}
 
-	for key, value := range partials {
-		switch key {
-		case "brand":
-			vehicle.Brand = value.(string)
-		case "color":
-			vehicle.Color = value.(string)
-		case "fabrication_year":
-			vehicle.FabricationYear = value.(int)
-		case "fuel_type":
-			vehicle.FuelType = value.(string)
-		case "max_speed":
-			vehicle.MaxSpeed = value.(float64)
-		case "transmission":
-			vehicle.Transmission = value.(string)
-		}
+	previous := vehicle
+	if err = vehicle.ApplyPartials(partials, r.nm); err != nil {
+		return
+	}
+	vehicle = internal.CleanVehicle(vehicle)
+	vehicle.Version = previous.Version + 1
+	if r.registrationChanged(previous, vehicle) && r.registrationTaken(vehicle.Registration, id) {
+		return internal.ErrVehicleRegistrationAlreadyExists
 	}
 
 	r.db[id] = vehicle
+	r.unindex(previous)
+	r.index(vehicle)
+
+	r.observe(ctx, internal.ChangeUpdate, &previous, vehicle)
+	return nil
+}
+
+// FindById is a method that returns the vehicle with the given id
+func (r *VehicleMap) FindById(ctx context.Context, id int, retired internal.Retired) (v internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v, ok := r.db[id]
+	if !ok || !retired.Visible(v) {
+		return internal.Vehicle{}, internal.ErrVehicleNotFound
+	}
+
+	return
+}
+
+// FindByRegistration is a method that returns the vehicle with the given registration plate
+// the plate is owned by a vehicle in service, or else by the retired vehicle with the lowest id if retired includes them
+func (r *VehicleMap) FindByRegistration(ctx context.Context, registration string, retired internal.Retired) (v internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.owner(r.plate(registration), bool(retired))
+	if !ok {
+		return internal.Vehicle{}, internal.ErrVehicleNotFound
+	}
 
+	return r.db[id], nil
+}
+
+// UpdateVehicle is a method that replaces a vehicle of the repository
+func (r *VehicleMap) UpdateVehicle(ctx context.Context, v internal.Vehicle, version int) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v = internal.CleanVehicle(v)
+
+	// verify if vehicle exists in the repository
+	previous, ok := r.db[v.Id]
+	if !ok || previous.Retired() {
+		return internal.ErrVehicleNotFound
+	}
+	if !internal.VersionMatches(previous.Version, version) {
+		return internal.ErrVehicleVersionMismatch
+	}
+	v.Version, v.RetiredAt = previous.Version+1, time.Time{}
+	if r.registrationChanged(previous, v) && r.registrationTaken(v.Registration, v.Id) {
+		return internal.ErrVehicleRegistrationAlreadyExists
+	}
+
+	r.db[v.Id] = v
+	r.unindex(previous)
+	r.index(v)
+
+	r.observe(ctx, internal.ChangeUpdate, &previous, v)
 	return nil
 }
 
-func (r *VehicleMap) GetAveragePassengersByBrand(brand string) (averagePassengers float64, err error) {
+func (r *VehicleMap) GetAveragePassengersByBrand(ctx context.Context, brand string, retired internal.Retired) (averagePassengers float64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var totalPassengers int
 	var totalVehicles int
 
 	// search vehicles by brand
-	for _, value := range r.db {
-		if value.Brand == brand {
-			totalPassengers += value.Capacity
-			totalVehicles++
-		}
+	found, err := r.find(ctx, internal.VehicleFilter{internal.TextEq("brand", brand)}, retired)
+	if err != nil {
+		return
+	}
+	for _, value := range found {
+		totalPassengers += value.Capacity
+		totalVehicles++
 	}
-
-	fmt.Println("TotalPassengers:", totalPassengers)
-	fmt.Println("TotalVehicles:", totalVehicles)
 
 	if totalVehicles == 0 {
 		return 0, internal.ErrVehiclesNotFound
@@ -213,19 +550,22 @@ func (r *VehicleMap) GetAveragePassengersByBrand(brand string) (averagePassenger
 
 	averagePassengers = float64(totalPassengers) / float64(totalVehicles)
 
-	fmt.Println("Average:", averagePassengers)
-
 	return
 }
 
-func (r *VehicleMap) FindByDimensions(minLength float64, maxLength float64, minWidth float64, maxWidth float64) (v map[int]internal.Vehicle, err error) {
-	v = make(map[int]internal.Vehicle)
+func (r *VehicleMap) FindByDimensions(ctx context.Context, minLength float64, maxLength float64, minWidth float64, maxWidth float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	// search vehicles by dimensions
-	for key, value := range r.db {
-		if value.Length >= minLength && value.Length <= maxLength && value.Width >= minWidth && value.Width <= maxWidth {
-			v[key] = value
-		}
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.NumberGte("length", minLength),
+		internal.NumberLte("length", maxLength),
+		internal.NumberGte("width", minWidth),
+		internal.NumberLte("width", maxWidth),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -235,14 +575,17 @@ func (r *VehicleMap) FindByDimensions(minLength float64, maxLength float64, minW
 	return
 }
 
-func (r *VehicleMap) FindByWeightRange(minWeight float64, maxWeight float64) (v map[int]internal.Vehicle, err error) {
-	v = make(map[int]internal.Vehicle)
+func (r *VehicleMap) FindByWeightRange(ctx context.Context, minWeight float64, maxWeight float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	// search vehicles by weight range
-	for key, value := range r.db {
-		if value.Weight >= minWeight && value.Weight <= maxWeight {
-			v[key] = value
-		}
+	v, err = r.find(ctx, internal.VehicleFilter{
+		internal.NumberGte("weight", minWeight),
+		internal.NumberLte("weight", maxWeight),
+	}, retired)
+	if err != nil {
+		return
 	}
 
 	if len(v) == 0 {
@@ -251,3 +594,35 @@ func (r *VehicleMap) FindByWeightRange(minWeight float64, maxWeight float64) (v
 
 	return
 }
+
+// lookup is a method that returns the vehicles with the given ids that are in the repository, retired included
+func (r *VehicleMap) lookup(ids []int) (v map[int]internal.Vehicle) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v = make(map[int]internal.Vehicle, len(ids))
+	for _, id := range ids {
+		if value, ok := r.db[id]; ok {
+			v[id] = value
+		}
+	}
+	return
+}
+
+// revert is a method that puts the vehicles with the given ids back as they are in previous, see lookup
+// the ones not in previous are removed; it undoes a mutation that could not be persisted
+func (r *VehicleMap) revert(ids []int, previous map[int]internal.Vehicle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if current, ok := r.db[id]; ok {
+			r.unindex(current)
+			delete(r.db, id)
+		}
+		if value, ok := previous[id]; ok {
+			r.db[id] = value
+			r.index(value)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"app/internal"
+	"math"
+	"sort"
+)
+
+// hashIndexFields are the text fields of a vehicle indexed by value, by JSON name
+var hashIndexFields = []string{"brand", "color", "fuel_type", "transmission"}
+
+// rangeIndexFields are the numeric fields of a vehicle indexed in order, by JSON name
+var rangeIndexFields = []string{"year", "weight", "height", "length", "width"}
+
+// rangeEntry is a struct that represents the value of a numeric field of a vehicle
+type rangeEntry struct {
+	// value is the value of the field
+	value float64
+	// id is the id of the vehicle
+	id int
+}
+
+// rangeBlockSize is the maximum number of entries of a block of a rangeIndex, which is split in halves beyond it
+const rangeBlockSize = 512
+
+// less is a method that returns true if the entry goes before b: by value, and then by id
+func (a rangeEntry) less(b rangeEntry) bool {
+	return a.value < b.value || (a.value == b.value && a.id < b.id)
+}
+
+// rangeIndex is a struct that represents a list of entries sorted by value and then by id
+// the entries are kept in sorted blocks of up to rangeBlockSize entries, as a two-level B-tree,
+// so an entry is inserted or removed by moving the entries of a single block instead of the whole list
+type rangeIndex struct {
+	// blocks are the non empty blocks of entries, in order
+	blocks [][]rangeEntry
+}
+
+// newRangeIndex is a function that returns an index of the entries, which must be sorted
+// the blocks are left half full, so the next entries are inserted without splitting them
+func newRangeIndex(entries []rangeEntry) *rangeIndex {
+	x := &rangeIndex{}
+	for start := 0; start < len(entries); start += rangeBlockSize / 2 {
+		end := start + rangeBlockSize/2
+		if end > len(entries) {
+			end = len(entries)
+		}
+		x.blocks = append(x.blocks, append(make([]rangeEntry, 0, rangeBlockSize), entries[start:end]...))
+	}
+	return x
+}
+
+// seek is a method that returns the position of the first entry not before e: the block and the entry in it
+// the position is (len(blocks), 0) if every entry goes before e
+func (x *rangeIndex) seek(e rangeEntry) (block int, i int) {
+	block = sort.Search(len(x.blocks), func(i int) bool {
+		b := x.blocks[i]
+		return !b[len(b)-1].less(e)
+	})
+	if block == len(x.blocks) {
+		return
+	}
+	b := x.blocks[block]
+	i = sort.Search(len(b), func(i int) bool {
+		return !b[i].less(e)
+	})
+	return
+}
+
+// insert is a method that adds the entry in order
+func (x *rangeIndex) insert(value float64, id int) {
+	e := rangeEntry{value: value, id: id}
+	if len(x.blocks) == 0 {
+		x.blocks = [][]rangeEntry{append(make([]rangeEntry, 0, rangeBlockSize), e)}
+		return
+	}
+
+	// an entry after every other one goes at the end of the last block
+	block, i := x.seek(e)
+	if block == len(x.blocks) {
+		block = len(x.blocks) - 1
+		i = len(x.blocks[block])
+	}
+	b := append(x.blocks[block], rangeEntry{})
+	copy(b[i+1:], b[i:])
+	b[i] = e
+	x.blocks[block] = b
+
+	// a full block is split in halves
+	if len(b) > rangeBlockSize {
+		half := len(b) / 2
+		right := append(make([]rangeEntry, 0, rangeBlockSize), b[half:]...)
+		x.blocks = append(x.blocks, nil)
+		copy(x.blocks[block+2:], x.blocks[block+1:])
+		x.blocks[block], x.blocks[block+1] = b[:half], right
+	}
+}
+
+// remove is a method that removes the entry, if it is in the index
+func (x *rangeIndex) remove(value float64, id int) {
+	e := rangeEntry{value: value, id: id}
+	block, i := x.seek(e)
+	if block == len(x.blocks) || x.blocks[block][i] != e {
+		return
+	}
+
+	b := append(x.blocks[block][:i], x.blocks[block][i+1:]...)
+	if len(b) == 0 {
+		x.blocks = append(x.blocks[:block], x.blocks[block+1:]...)
+		return
+	}
+	x.blocks[block] = b
+}
+
+// between is a method that calls fn with the entries with a value in the inclusive range [min, max], in order
+// and returns their count; fn may be nil to only count them
+func (x *rangeIndex) between(min float64, max float64, fn func(e rangeEntry)) (n int) {
+	startBlock, start := x.seek(rangeEntry{value: min, id: math.MinInt})
+	endBlock, end := x.seek(rangeEntry{value: max, id: math.MaxInt})
+
+	for block := startBlock; block <= endBlock && block < len(x.blocks); block++ {
+		b := x.blocks[block]
+		from, to := 0, len(b)
+		if block == startBlock {
+			from = start
+		}
+		if block == endBlock {
+			to = end
+		}
+		if from >= to {
+			continue
+		}
+		n += to - from
+		if fn != nil {
+			for _, e := range b[from:to] {
+				fn(e)
+			}
+		}
+	}
+	return
+}
+
+// index is a method that adds a vehicle to the secondary indexes
+// the caller must hold the write lock
+func (r *VehicleMap) index(v internal.Vehicle) {
+	plate := r.plate(v.Registration)
+	ids, ok := r.plates[plate]
+	if !ok {
+		ids = make(map[int]struct{}, 1)
+		r.plates[plate] = ids
+	}
+	ids[v.Id] = struct{}{}
+
+	for _, field := range hashIndexFields {
+		key := r.key(v, field)
+		ids, ok := r.hashes[field][key]
+		if !ok {
+			ids = make(map[int]struct{})
+			r.hashes[field][key] = ids
+		}
+		ids[v.Id] = struct{}{}
+	}
+
+	for _, field := range rangeIndexFields {
+		n, _ := v.FieldNumber(field)
+		r.ranges[field].insert(n, v.Id)
+	}
+}
+
+// unindex is a method that removes a vehicle from the secondary indexes
+// the caller must hold the write lock
+func (r *VehicleMap) unindex(v internal.Vehicle) {
+	plate := r.plate(v.Registration)
+	delete(r.plates[plate], v.Id)
+	if len(r.plates[plate]) == 0 {
+		delete(r.plates, plate)
+	}
+
+	for _, field := range hashIndexFields {
+		key := r.key(v, field)
+		delete(r.hashes[field][key], v.Id)
+		if len(r.hashes[field][key]) == 0 {
+			delete(r.hashes[field], key)
+		}
+	}
+
+	for _, field := range rangeIndexFields {
+		n, _ := v.FieldNumber(field)
+		r.ranges[field].remove(n, v.Id)
+	}
+}
+
+// reindex is a method that rebuilds the secondary indexes from db
+// the caller must hold the write lock
+func (r *VehicleMap) reindex() {
+	ids := make([]int, 0, len(r.db))
+	for id := range r.db {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	r.plates = make(map[string]map[int]struct{}, len(r.db))
+	r.hashes = make(map[string]map[string]map[int]struct{}, len(hashIndexFields))
+	for _, field := range hashIndexFields {
+		r.hashes[field] = make(map[string]map[int]struct{})
+	}
+	r.ranges = make(map[string]*rangeIndex, len(rangeIndexFields))
+
+	for _, id := range ids {
+		v := r.db[id]
+
+		plate := r.plate(v.Registration)
+		if r.plates[plate] == nil {
+			r.plates[plate] = make(map[int]struct{}, 1)
+		}
+		r.plates[plate][id] = struct{}{}
+
+		for _, field := range hashIndexFields {
+			key := r.key(v, field)
+			if r.hashes[field][key] == nil {
+				r.hashes[field][key] = make(map[int]struct{})
+			}
+			r.hashes[field][key][id] = struct{}{}
+		}
+	}
+
+	// range indexes are sorted once instead of inserting every entry in order
+	for _, field := range rangeIndexFields {
+		x := make([]rangeEntry, 0, len(ids))
+		for _, id := range ids {
+			n, _ := r.db[id].FieldNumber(field)
+			x = append(x, rangeEntry{value: n, id: id})
+		}
+		sort.Slice(x, func(i, j int) bool {
+			return x[i].less(x[j])
+		})
+		r.ranges[field] = newRangeIndex(x)
+	}
+}
+
+// candidates is a method that returns the ids of the vehicles that may match the filter
+// using the most selective index; ok is false when no index applies and db must be scanned
+// the caller must hold the lock
+func (r *VehicleMap) candidates(f internal.VehicleFilter) (ids []int, ok bool) {
+	size := -1
+
+	// equality over hashed fields
+	for _, c := range f {
+		if c.Operator != internal.FilterEq {
+			continue
+		}
+		h, indexed := r.hashes[c.Field]
+		if !indexed {
+			continue
+		}
+		set := h[r.nm.Key(c.Field, c.Text)]
+		if size == -1 || len(set) < size {
+			ids = ids[:0]
+			for id := range set {
+				ids = append(ids, id)
+			}
+			size, ok = len(set), true
+		}
+	}
+
+	// ranges over ordered fields, combining every condition over the same field
+	for _, field := range rangeIndexFields {
+		min, max, bounded := math.Inf(-1), math.Inf(1), false
+		for _, c := range f {
+			if c.Field != field {
+				continue
+			}
+			switch c.Operator {
+			case internal.FilterEq:
+				min, max = math.Max(min, c.Number), math.Min(max, c.Number)
+			case internal.FilterGte:
+				min = math.Max(min, c.Number)
+			case internal.FilterLte:
+				max = math.Min(max, c.Number)
+			}
+			bounded = true
+		}
+		if !bounded {
+			continue
+		}
+
+		// the entries are counted first, and only collected for the most selective range
+		x := r.ranges[field]
+		if n := x.between(min, max, nil); size == -1 || n < size {
+			ids = ids[:0]
+			x.between(min, max, func(e rangeEntry) {
+				ids = append(ids, e.id)
+			})
+			size, ok = n, true
+		}
+	}
+
+	return
+}
+
+// owner is a method that returns the vehicle that owns the plate: the one in service with the lowest id
+// vehicles loaded with a duplicated plate share it, and the plate is handed over to the next one when its owner is retired
+// if retired is true and no vehicle in service has the plate, it returns the retired vehicle with the lowest id
+// the caller must hold the lock
+func (r *VehicleMap) owner(plate string, retired bool) (id int, ok bool) {
+	retiredId, retiredOk := 0, false
+	for key := range r.plates[plate] {
+		if r.db[key].Retired() {
+			if !retiredOk || key < retiredId {
+				retiredId, retiredOk = key, true
+			}
+			continue
+		}
+		if !ok || key < id {
+			id, ok = key, true
+		}
+	}
+	if !ok && retired {
+		return retiredId, retiredOk
+	}
+	return
+}
+
+// registrationTaken is a method that returns true if the plate belongs to a vehicle other than id
+// the caller must hold the lock
+func (r *VehicleMap) registrationTaken(registration string, id int) bool {
+	owner, ok := r.owner(r.plate(registration), false)
+	return ok && owner != id
+}
+
+// registrationChanged is a method that returns true if the change of a vehicle from previous to v changes its plate
+// the uniqueness of a plate is only checked when it changes, so the vehicles loaded with a duplicated plate
+// that do not own it can still be updated
+func (r *VehicleMap) registrationChanged(previous internal.Vehicle, v internal.Vehicle) bool {
+	return r.plate(previous.Registration) != r.plate(v.Registration)
+}
+
+// plate is a method that returns the key a registration is indexed by
+func (r *VehicleMap) plate(registration string) string {
+	return r.nm.Key("registration", registration)
+}
+
+// key is a method that returns the key a text field of a vehicle is indexed by
+func (r *VehicleMap) key(v internal.Vehicle, field string) string {
+	text, _ := v.FieldText(field)
+	return r.nm.Key(field, text)
+}
@@ -0,0 +1,320 @@
+package repository_test
+
+import (
+	"app/internal"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newVehicles is a function that returns n pseudo random vehicles with ids from 1 to n
+func newVehicles(n int) map[int]internal.Vehicle {
+	brands := []string{"Chevrolet", "GMC", "Ford", "Toyota", "Acura", "Dodge", "Buick", "Suzuki", "Lexus", "Mercury"}
+	colors := []string{"Red", "Blue", "Teal", "Pink", "Khaki", "Mauv", "Crimson", "Purple"}
+	fuels := []internal.FuelType{"gas", "gasoline", "diesel", "biodiesel"}
+	transmissions := []internal.Transmission{"automatic", "manual", "semi-automatic"}
+
+	rd := rand.New(rand.NewSource(1))
+	db := make(map[int]internal.Vehicle, n)
+	for id := 1; id <= n; id++ {
+		db[id] = internal.Vehicle{
+			Id:      id,
+			Version: 1,
+			VehicleAttributes: internal.VehicleAttributes{
+				Brand:           brands[rd.Intn(len(brands))],
+				Model:           "Model",
+				Registration:    fmt.Sprintf("R-%d", id),
+				Color:           colors[rd.Intn(len(colors))],
+				FabricationYear: 1960 + rd.Intn(60),
+				Capacity:        1 + rd.Intn(6),
+				MaxSpeed:        80 + float64(rd.Intn(170)),
+				FuelType:        fuels[rd.Intn(len(fuels))],
+				Transmission:    transmissions[rd.Intn(len(transmissions))],
+				Weight:          float64(rd.Intn(300000)) / 100,
+				Dimensions: internal.Dimensions{
+					Height: float64(rd.Intn(30000)) / 100,
+					Length: float64(rd.Intn(30000)) / 100,
+					Width:  float64(rd.Intn(30000)) / 100,
+				},
+			},
+		}
+	}
+
+	return db
+}
+
+// scan is a function that returns the vehicles of db matching the filter without indexes
+func scan(db map[int]internal.Vehicle, f internal.VehicleFilter) map[int]internal.Vehicle {
+	v := make(map[int]internal.Vehicle)
+	for key, value := range db {
+		if f.Match(value) {
+			v[key] = value
+		}
+	}
+	return v
+}
+
+// TestVehicleMap_Contract tests that VehicleMap satisfies the conformance suite of internal.VehicleRepository
+func TestVehicleMap_Contract(t *testing.T) {
+	repositorytest.TestVehicleRepository(t, func(t *testing.T, db map[int]internal.Vehicle) internal.VehicleRepository {
+		return repository.NewVehicleMap(db, nil)
+	})
+}
+
+// TestVehicleMap_FindByFilter tests that the indexed search returns the same vehicles as a full scan
+func TestVehicleMap_FindByFilter(t *testing.T) {
+	filters := map[string]internal.VehicleFilter{
+		"hash":          {internal.TextEq("brand", "Ford")},
+		"hash and hash": {internal.TextEq("brand", "Ford"), internal.TextEq("color", "Red")},
+		"range":         {internal.NumberGte("weight", 1000), internal.NumberLte("weight", 1500)},
+		"equal range":   {internal.NumberEq("year", 1990)},
+		"open range":    {internal.NumberGte("year", 2015)},
+		"hash and range": {
+			internal.TextEq("fuel_type", "diesel"),
+			internal.NumberGte("year", 1990),
+			internal.NumberLte("year", 2000),
+		},
+		"empty range": {internal.NumberGte("width", 200), internal.NumberLte("width", 100)},
+		"no index":    {internal.TextEq("model", "Model")},
+		"no match":    {internal.TextEq("brand", "Tesla")},
+	}
+
+	t.Run("after load", func(t *testing.T) {
+		// arrange
+		db := newVehicles(2000)
+		rp := repository.NewVehicleMap(newVehicles(2000), nil)
+
+		for name, f := range filters {
+			// act
+			v, err := rp.FindByFilter(context.Background(), f, internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, err, name)
+			require.Equal(t, scan(db, f), v, name)
+		}
+	})
+
+	t.Run("after add, update and delete", func(t *testing.T) {
+		// arrange
+		db := newVehicles(2000)
+		rp := repository.NewVehicleMap(newVehicles(1000), nil)
+		for id := 1001; id <= 1500; id++ {
+			require.NoError(t, rp.AddVehicle(context.Background(), db[id]))
+		}
+		batch := make([]internal.Vehicle, 0, 500)
+		for id := 1501; id <= 2000; id++ {
+			batch = append(batch, db[id])
+		}
+		errs, err := rp.AddVehicles(context.Background(), batch, true)
+		require.NoError(t, err)
+		require.Equal(t, make([]error, len(batch)), errs)
+		partials := map[string]any{"brand": "Ford", "year": 1990, "weight": 1200.5}
+		for id := 1; id <= 2000; id += 7 {
+			require.NoError(t, rp.UpdatePartials(context.Background(), id, internal.VersionAny, partials))
+			v := db[id]
+			require.NoError(t, v.ApplyPartials(partials, nil))
+			v.Version++
+			db[id] = v
+		}
+		for id := 3; id <= 2000; id += 11 {
+			v := db[id]
+			v.Color, v.Width = "Red", 150
+			require.NoError(t, rp.UpdateVehicle(context.Background(), v, internal.VersionAny))
+			v.Version++
+			db[id] = v
+		}
+		for id := 5; id <= 2000; id += 13 {
+			require.NoError(t, rp.DeleteVehicle(context.Background(), id, internal.VersionAny))
+			delete(db, id)
+		}
+
+		for name, f := range filters {
+			// act
+			v, err := rp.FindByFilter(context.Background(), f, internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, err, name)
+			require.Equal(t, scan(db, f), v, name)
+		}
+	})
+}
+
+// BenchmarkVehicleMap_FindByFilter compares the indexed search with a full scan over a large fleet
+func BenchmarkVehicleMap_FindByFilter(b *testing.B) {
+	db := newVehicles(500000)
+	rp := repository.NewVehicleMap(newVehicles(500000), nil)
+
+	filters := map[string]internal.VehicleFilter{
+		"brand and color": {internal.TextEq("brand", "Ford"), internal.TextEq("color", "Red")},
+		"year":            {internal.NumberEq("year", 1990)},
+		"weight range":    {internal.NumberGte("weight", 1000), internal.NumberLte("weight", 1010)},
+		"dimensions": {
+			internal.NumberGte("length", 100), internal.NumberLte("length", 101),
+			internal.NumberGte("width", 100), internal.NumberLte("width", 200),
+		},
+	}
+
+	for name, f := range filters {
+		b.Run(name+"/index", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = rp.FindByFilter(context.Background(), f, internal.ExcludeRetired)
+			}
+		})
+		b.Run(name+"/scan", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = scan(db, f)
+			}
+		})
+	}
+}
+
+// BenchmarkVehicleMap_Mutations measures the changes of a large fleet, which keep its indexes up to date
+func BenchmarkVehicleMap_Mutations(b *testing.B) {
+	const size = 500000
+	rp := repository.NewVehicleMap(newVehicles(size), nil)
+	extra := newVehicles(size + 100000)
+
+	b.Run("update partials", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			partials := map[string]any{"year": 1960 + i%60, "weight": float64(i % 3000), "color": "Teal"}
+			_ = rp.UpdatePartials(context.Background(), 1+i%size, internal.VersionAny, partials)
+		}
+	})
+	b.Run("update registration", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			partials := map[string]any{"registration": fmt.Sprintf("B-%d", i)}
+			_ = rp.UpdatePartials(context.Background(), 1+i%size, internal.VersionAny, partials)
+		}
+	})
+	b.Run("delete and restore", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = rp.DeleteVehicle(context.Background(), 1+i%size, internal.VersionAny)
+			_ = rp.RestoreVehicle(context.Background(), 1+i%size)
+		}
+	})
+	b.Run("add batch of 100", func(b *testing.B) {
+		next := size + 1
+		for i := 0; i < b.N && next+100 <= len(extra); i++ {
+			batch := make([]internal.Vehicle, 0, 100)
+			for id := next; id < next+100; id++ {
+				batch = append(batch, extra[id])
+			}
+			_, _ = rp.AddVehicles(context.Background(), batch, false)
+			next += 100
+		}
+	})
+}
+
+// TestVehicleMap_Normalization tests that text fields are matched regardless of case, spaces and synonyms
+func TestVehicleMap_Normalization(t *testing.T) {
+	// arrange
+	db := map[int]internal.Vehicle{
+		1: {Id: 1, VehicleAttributes: internal.VehicleAttributes{Brand: " Škoda ", Registration: "AB 123", FuelType: "Gasoline", Transmission: "Semi  Automatic"}},
+		2: {Id: 2, VehicleAttributes: internal.VehicleAttributes{Brand: "Ford", Registration: "CD-456", FuelType: "diesel", Transmission: "manual"}},
+	}
+	rp := repository.NewVehicleMap(db, nil)
+
+	t.Run("stored values are cleaned", func(t *testing.T) {
+		// act
+		v, err := rp.FindById(context.Background(), 1, internal.ExcludeRetired)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "Škoda", v.Brand)
+		require.Equal(t, internal.Transmission("Semi Automatic"), v.Transmission)
+	})
+
+	t.Run("case and spaces are ignored", func(t *testing.T) {
+		// act
+		v, err := rp.FindByFuelType(context.Background(), "  GASOLINE", internal.ExcludeRetired)
+
+		// assert
+		require.NoError(t, err)
+		require.Len(t, v, 1)
+		require.Contains(t, v, 1)
+	})
+
+	t.Run("unicode forms are equivalent", func(t *testing.T) {
+		// act: "s" followed by a combining caron instead of the precomposed "Š"
+		v, err := rp.FindByFilter(context.Background(), internal.VehicleFilter{internal.TextEq("brand", "s\u030Ckoda")}, internal.ExcludeRetired)
+
+		// assert
+		require.NoError(t, err)
+		require.Len(t, v, 1)
+	})
+
+	t.Run("synonyms are resolved", func(t *testing.T) {
+		// act
+		fuel, err := rp.FindByFuelType(context.Background(), "petrol", internal.ExcludeRetired)
+		require.NoError(t, err)
+		transmission, err := rp.FindByTransmissionType(context.Background(), "semi-automatic", internal.ExcludeRetired)
+		require.NoError(t, err)
+
+		// assert
+		require.Contains(t, fuel, 1)
+		require.Contains(t, transmission, 1)
+	})
+
+	t.Run("registrations are unique by key", func(t *testing.T) {
+		// act
+		err := rp.AddVehicle(context.Background(), internal.Vehicle{Id: 3, VehicleAttributes: internal.VehicleAttributes{Registration: "cd-456 "}})
+		v, errFind := rp.FindByRegistration(context.Background(), "ab 123", internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, err, internal.ErrVehicleRegistrationAlreadyExists)
+		require.NoError(t, errFind)
+		require.Equal(t, 1, v.Id)
+	})
+}
+
+// TestVehicleMap_AddVehicles tests the result of each vehicle of a batch, in atomic and best effort mode
+func TestVehicleMap_AddVehicles(t *testing.T) {
+	// arrange
+	db := newVehicles(10)
+	batch := []internal.Vehicle{
+		{Id: 11, VehicleAttributes: internal.VehicleAttributes{Registration: "N-11"}},
+		{Id: 1, VehicleAttributes: internal.VehicleAttributes{Registration: "N-1"}},
+		{Id: 12, VehicleAttributes: internal.VehicleAttributes{Registration: "r-2"}},
+		{Id: 11, VehicleAttributes: internal.VehicleAttributes{Registration: "N-13"}},
+		{Id: 14, VehicleAttributes: internal.VehicleAttributes{Registration: "n-11"}},
+	}
+	expected := []error{
+		nil,
+		internal.ErrVehicleAlreadyExists,
+		internal.ErrVehicleRegistrationAlreadyExists,
+		internal.ErrVehicleAlreadyExists,
+		internal.ErrVehicleRegistrationAlreadyExists,
+	}
+
+	t.Run("atomic adds nothing if any vehicle is a duplicate", func(t *testing.T) {
+		rp := repository.NewVehicleMap(db, nil)
+
+		// act
+		errs, err := rp.AddVehicles(context.Background(), batch, true)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, expected, errs)
+		_, err = rp.FindById(context.Background(), 11, internal.ExcludeRetired)
+		require.ErrorIs(t, err, internal.ErrVehicleNotFound)
+	})
+
+	t.Run("best effort adds every vehicle that is not a duplicate", func(t *testing.T) {
+		rp := repository.NewVehicleMap(db, nil)
+
+		// act
+		errs, err := rp.AddVehicles(context.Background(), batch, false)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, expected, errs)
+		v, err := rp.FindByRegistration(context.Background(), "n-11", internal.ExcludeRetired)
+		require.NoError(t, err)
+		require.Equal(t, 11, v.Id)
+	})
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"app/internal"
+	"context"
+	"log"
+)
+
+// vehicleObserver is a struct that holds the observer of the changes of a repository, see internal.VehicleObserver
+// the repositories embed it, and call observe with every change they make while they hold the lock of the change
+type vehicleObserver struct {
+	// ob is the observer of the changes, nil for none
+	ob internal.VehicleObserver
+}
+
+// SetObserver is a method that sets the observer of the changes made to the vehicles, nil for none
+func (o *vehicleObserver) SetObserver(ob internal.VehicleObserver) {
+	o.ob = ob
+}
+
+// observe is a method that sends a change of a vehicle to the observer, if any
+// the change was already made, so it is observed even if ctx is canceled,
+// and an error of the observer is logged instead of failing the change
+func (o *vehicleObserver) observe(ctx context.Context, op internal.ChangeOperation, before *internal.Vehicle, after internal.Vehicle) {
+	if o.ob == nil {
+		return
+	}
+
+	if err := o.ob.Observe(context.WithoutCancel(ctx), op, before, after); err != nil {
+		log.Printf("the %s of the vehicle of id %d was made but not observed: %v", op, after.Id, err)
+	}
+}
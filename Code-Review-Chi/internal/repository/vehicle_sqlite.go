@@ -0,0 +1,865 @@
+package repository
+
+import (
+	"app/internal"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrateSQLite is a function that applies the migrations of the vehicles schema that are not applied yet
+// migrations are the files of the migrations directory, applied in order of name and recorded in schema_migrations
+func MigrateSQLite(ctx context.Context, db *sql.DB) (err error) {
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version TEXT PRIMARY KEY, applied_at TEXT NOT NULL)`)
+	if err != nil {
+		return
+	}
+
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		version := entry.Name()
+
+		var applied int
+		if err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return
+		}
+		if applied > 0 {
+			continue
+		}
+
+		script, err := migrations.ReadFile("migrations/" + version)
+		if err != nil {
+			return err
+		}
+		err = inTx(ctx, db, func(tx *sql.Tx) (err error) {
+			if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+				return
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().UTC().Format(time.RFC3339))
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+	}
+
+	return
+}
+
+// inTx is a function that runs fn in a transaction, committed if fn succeeds and rolled back otherwise
+// the transaction is rolled back if ctx is canceled before it is committed
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit()
+}
+
+// sqlQuerier is an interface implemented by *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// errRejected is returned by a transaction that must be rolled back without being a failure
+var errRejected = fmt.Errorf("rejected")
+
+const (
+	// vehicleColumns are the columns of a vehicle, in the order read by scanVehicle
+	vehicleColumns = "id, brand, model, registration, color, fuel_type, transmission, year, passengers, max_speed, weight, height, length, width, version, retired_at"
+	// vehicleKeyColumns are the columns of the keys of the text fields, in the order of textKeyFields
+	vehicleKeyColumns = "brand_key, model_key, registration_key, color_key, fuel_type_key, transmission_key"
+)
+
+// textKeyFields are the text fields stored with the key of the normalizer, by JSON name
+var textKeyFields = []string{"brand", "model", "registration", "color", "fuel_type", "transmission"}
+
+// isTextField is a function that returns true if the field is compared as text
+func isTextField(field string) bool {
+	for _, f := range textKeyFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanVehicle is a function that reads a vehicle selected with vehicleColumns
+func scanVehicle(row interface{ Scan(dest ...any) error }) (v internal.Vehicle, err error) {
+	var retiredAt sql.NullString
+	err = row.Scan(
+		&v.Id, &v.Brand, &v.Model, &v.Registration, &v.Color, &v.FuelType, &v.Transmission,
+		&v.FabricationYear, &v.Capacity, &v.MaxSpeed, &v.Weight, &v.Height, &v.Length, &v.Width, &v.Version, &retiredAt,
+	)
+	if err != nil || !retiredAt.Valid {
+		return
+	}
+
+	v.RetiredAt, err = time.Parse(time.RFC3339Nano, retiredAt.String)
+	return
+}
+
+// NewVehicleSQLite is a function that returns a new instance of VehicleSQLite
+// the schema must be migrated with MigrateSQLite
+// nil nm means a normalizer with the default synonyms
+func NewVehicleSQLite(db *sql.DB, nm *internal.TextNormalizer) *VehicleSQLite {
+	// default normalizer
+	if nm == nil {
+		nm = internal.NewTextNormalizer(nil)
+	}
+
+	return &VehicleSQLite{db: db, nm: nm}
+}
+
+// VehicleSQLite is a struct that represents a vehicle repository stored in a SQLite database
+// text fields are stored with their key, so they are compared in SQL as VehicleMap compares them
+type VehicleSQLite struct {
+	// db is the database
+	db *sql.DB
+	// nm is the normalizer used to compare text fields
+	nm *internal.TextNormalizer
+	// mu serializes the changes, so they are observed in the order they are committed
+	mu sync.Mutex
+	// vehicleObserver is the observer of the changes, called under the lock of mu once they are committed
+	vehicleObserver
+}
+
+// values is a method that returns the values of vehicleColumns followed by vehicleKeyColumns
+func (r *VehicleSQLite) values(v internal.Vehicle) []any {
+	values := []any{
+		v.Id, v.Brand, v.Model, v.Registration, v.Color, string(v.FuelType), string(v.Transmission),
+		v.FabricationYear, v.Capacity, v.MaxSpeed, v.Weight, v.Height, v.Length, v.Width, v.Version, nil,
+	}
+	if v.Retired() {
+		values[len(values)-1] = v.RetiredAt.UTC().Format(time.RFC3339Nano)
+	}
+	for _, field := range textKeyFields {
+		text, _ := v.FieldText(field)
+		values = append(values, r.nm.Key(field, text))
+	}
+
+	return values
+}
+
+// insert is a method that inserts a vehicle
+func (r *VehicleSQLite) insert(ctx context.Context, q sqlQuerier, v internal.Vehicle) (err error) {
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO vehicles (`+vehicleColumns+`, `+vehicleKeyColumns+`) VALUES (`+strings.Repeat("?, ", 21)+`?)`,
+		r.values(v)...,
+	)
+	return
+}
+
+// update is a method that replaces the columns of a vehicle
+func (r *VehicleSQLite) update(ctx context.Context, q sqlQuerier, v internal.Vehicle) (err error) {
+	columns := strings.Split(vehicleColumns+", "+vehicleKeyColumns, ", ")
+	values := r.values(v)
+	set := make([]string, 0, len(columns)-1)
+	for _, column := range columns[1:] {
+		set = append(set, column+" = ?")
+	}
+
+	_, err = q.ExecContext(ctx, `UPDATE vehicles SET `+strings.Join(set, ", ")+` WHERE id = ?`, append(values[1:], v.Id)...)
+	return
+}
+
+// findById is a method that returns the vehicle with the given id
+func (r *VehicleSQLite) findById(ctx context.Context, q sqlQuerier, id int) (v internal.Vehicle, err error) {
+	v, err = scanVehicle(q.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM vehicles WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		err = internal.ErrVehicleNotFound
+	}
+
+	return
+}
+
+// registrationOwner is a method that returns the id of the vehicle that owns a registration plate
+// as in VehicleMap, repeated plates of the seed data are owned by the lowest id, and retired vehicles own no plate
+func (r *VehicleSQLite) registrationOwner(ctx context.Context, q sqlQuerier, registration string) (id int, ok bool, err error) {
+	var owner sql.NullInt64
+	err = q.QueryRowContext(ctx, `SELECT MIN(id) FROM vehicles WHERE registration_key = ? AND retired_at IS NULL`, r.nm.Key("registration", registration)).Scan(&owner)
+	return int(owner.Int64), owner.Valid, err
+}
+
+// registrationTaken is a method that returns true if the registration plate is owned by a vehicle other than id
+func (r *VehicleSQLite) registrationTaken(ctx context.Context, q sqlQuerier, registration string, id int) (taken bool, err error) {
+	owner, ok, err := r.registrationOwner(ctx, q, registration)
+	return ok && owner != id, err
+}
+
+// registrationChanged is a method that returns true if the change of a vehicle from previous to v changes its plate
+// the uniqueness of a plate is only checked when it changes, so the vehicles loaded with a duplicated plate
+// that do not own it can still be updated
+func (r *VehicleSQLite) registrationChanged(previous internal.Vehicle, v internal.Vehicle) bool {
+	return r.nm.Key("registration", previous.Registration) != r.nm.Key("registration", v.Registration)
+}
+
+// where is a method that returns the SQL condition and arguments of the filter
+// the retired vehicles are excluded unless retired includes them
+func (r *VehicleSQLite) where(f internal.VehicleFilter, retired internal.Retired) (clause string, args []any, err error) {
+	conditions := []string{"1 = 1"}
+	if !retired {
+		conditions = append(conditions, "retired_at IS NULL")
+	}
+	for _, c := range f {
+		switch {
+		case isTextField(c.Field):
+			if c.Operator != internal.FilterEq {
+				// text fields only support equality, as in VehicleCondition.Match
+				conditions = append(conditions, "0 = 1")
+				continue
+			}
+			conditions = append(conditions, c.Field+"_key = ?")
+			args = append(args, r.nm.Key(c.Field, c.Text))
+		case internal.IsNumberField(c.Field):
+			op, ok := map[internal.FilterOperator]string{internal.FilterEq: "=", internal.FilterGte: ">=", internal.FilterLte: "<="}[c.Operator]
+			if !ok {
+				return "", nil, fmt.Errorf("%w: unknown operator %s", internal.ErrInvalidFilter, c.Operator)
+			}
+			conditions = append(conditions, c.Field+" "+op+" ?")
+			args = append(args, c.Number)
+		default:
+			return "", nil, fmt.Errorf("%w: unknown field %s", internal.ErrInvalidFilter, c.Field)
+		}
+	}
+
+	clause = strings.Join(conditions, " AND ")
+	return
+}
+
+// find is a method that returns the vehicles matching the filter
+// the scan stops with the error of ctx if it is canceled
+func (r *VehicleSQLite) find(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	clause, args, err := r.where(f, retired)
+	if err != nil {
+		return
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+vehicleColumns+` FROM vehicles WHERE `+clause, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	v = make(map[int]internal.Vehicle)
+	for rows.Next() {
+		vehicle, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		v[vehicle.Id] = vehicle
+	}
+
+	err = rows.Err()
+	return
+}
+
+// findSome is a method that returns the vehicles matching the filter, or ErrVehiclesNotFound if there is none
+func (r *VehicleSQLite) findSome(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	v, err = r.find(ctx, f, retired)
+	if err == nil && len(v) == 0 {
+		return nil, internal.ErrVehiclesNotFound
+	}
+
+	return
+}
+
+// average is a method that returns the average of a numeric column of the vehicles of a brand
+func (r *VehicleSQLite) average(ctx context.Context, column string, brand string, retired internal.Retired) (average float64, err error) {
+	clause, args, err := r.where(internal.VehicleFilter{internal.TextEq("brand", brand)}, retired)
+	if err != nil {
+		return
+	}
+
+	var count int
+	var avg sql.NullFloat64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*), AVG(`+column+`) FROM vehicles WHERE `+clause, args...).Scan(&count, &avg)
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		return 0, internal.ErrVehiclesNotFound
+	}
+
+	average = avg.Float64
+	return
+}
+
+// Seed is a method that adds the vehicles if the repository is empty, returning how many were added
+func (r *VehicleSQLite) Seed(ctx context.Context, v map[int]internal.Vehicle) (n int, err error) {
+	var count int
+	if err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vehicles`).Scan(&count); err != nil || count > 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(v))
+	for id := range v {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		for _, id := range ids {
+			vehicle := internal.CleanVehicle(v[id])
+			if vehicle.Version == 0 {
+				vehicle.Version = 1
+			}
+			if err = r.insert(ctx, tx, vehicle); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	n = len(ids)
+	return
+}
+
+// Rekey is a method that recomputes the keys of the text fields of every vehicle
+// it must be called when the synonyms of the normalizer change
+func (r *VehicleSQLite) Rekey(ctx context.Context) (err error) {
+	v, err := r.find(ctx, nil, internal.IncludeRetired)
+	if err != nil {
+		return
+	}
+
+	return inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		for _, vehicle := range v {
+			if err = r.update(ctx, tx, vehicle); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// FindAll is a method that returns a map of all vehicles
+// the retired vehicles are included only if retired includes them
+func (r *VehicleSQLite) FindAll(ctx context.Context, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.find(ctx, nil, retired)
+}
+
+// FindByFilter is a method that returns the vehicles matching every condition of the filter
+func (r *VehicleSQLite) FindByFilter(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.find(ctx, f, retired)
+}
+
+// Query is a method that returns a page of the vehicles matching the query, in the query order
+// total is the number of matching vehicles before pagination
+func (r *VehicleSQLite) Query(ctx context.Context, q internal.VehicleQuery) (v []internal.Vehicle, total int, err error) {
+	clause, args, err := r.where(q.Filter, q.Retired)
+	if err != nil {
+		return
+	}
+	order, err := orderBy(q.Sort)
+	if err != nil {
+		return
+	}
+
+	// total
+	if err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vehicles WHERE `+clause, args...).Scan(&total); err != nil {
+		return
+	}
+
+	// page, a negative limit is no limit in SQLite
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	v, err = r.query(ctx, `SELECT `+vehicleColumns+` FROM vehicles WHERE `+clause+` ORDER BY `+order+` LIMIT ? OFFSET ?`,
+		append(args, limit, q.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return
+}
+
+// eachPageSize is the number of vehicles Each reads at once
+const eachPageSize = 500
+
+// Each is a method that calls fn with every vehicle of the page of the query, in the query order
+// the vehicles are read eachPageSize at a time, each page from the last vehicle of the previous one in the query
+// order (keyset pagination) instead of an offset that is scanned again for every page; fn is called between the reads
+// without holding the connection, so vehicles changed while the pass runs may be missing or repeated
+func (r *VehicleSQLite) Each(ctx context.Context, q internal.VehicleQuery, fn func(v internal.Vehicle) error) (err error) {
+	clause, args, err := r.where(q.Filter, q.Retired)
+	if err != nil {
+		return
+	}
+	order, err := orderBy(q.Sort)
+	if err != nil {
+		return
+	}
+
+	offset, remaining := q.Offset, q.Limit
+	var last *internal.Vehicle
+	for {
+		size := eachPageSize
+		if q.Limit > 0 && remaining < size {
+			size = remaining
+		}
+		if size == 0 {
+			return
+		}
+
+		// - the offset only applies to the first page, the next ones start after the last vehicle
+		where, whereArgs := clause, append([]any(nil), args...)
+		if last != nil {
+			after, afterArgs := keysetAfter(q.Sort, *last)
+			where, whereArgs = where+" AND "+after, append(whereArgs, afterArgs...)
+		}
+		page, err := r.query(ctx, `SELECT `+vehicleColumns+` FROM vehicles WHERE `+where+` ORDER BY `+order+` LIMIT ? OFFSET ?`,
+			append(whereArgs, size, offset)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, vehicle := range page {
+			if err = fn(vehicle); err != nil {
+				return err
+			}
+		}
+		if len(page) < size {
+			return nil
+		}
+		offset, remaining, last = 0, remaining-len(page), &page[len(page)-1]
+	}
+}
+
+// query is a method that returns the vehicles of a query of the vehicleColumns, in the order of its rows
+func (r *VehicleSQLite) query(ctx context.Context, query string, args ...any) (v []internal.Vehicle, err error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	v = make([]internal.Vehicle, 0)
+	for rows.Next() {
+		vehicle, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, vehicle)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// orderBy is a function that returns the SQL order of the sort keys
+// ties are broken by id as in VehicleQuery.Less
+func orderBy(sort []internal.VehicleSort) (order string, err error) {
+	columns := make([]string, 0, len(sort)+1)
+	for _, key := range sort {
+		if !isTextField(key.Field) && !internal.IsNumberField(key.Field) {
+			return "", fmt.Errorf("%w: unknown sort field %s", internal.ErrInvalidQuery, key.Field)
+		}
+		if key.Desc {
+			columns = append(columns, key.Field+" DESC")
+		} else {
+			columns = append(columns, key.Field)
+		}
+	}
+	columns = append(columns, "id")
+
+	return strings.Join(columns, ", "), nil
+}
+
+// keysetAfter is a function that returns the SQL condition and arguments of the vehicles that go after v
+// in the order of the sort keys, then id: the first key that differs from v decides
+func keysetAfter(sort []internal.VehicleSort, v internal.Vehicle) (clause string, args []any) {
+	value := func(field string) any {
+		if n, ok := v.FieldNumber(field); ok {
+			return n
+		}
+		text, _ := v.FieldText(field)
+		return text
+	}
+
+	terms := make([]string, 0, len(sort)+1)
+	equal, equalArgs := "", []any(nil)
+	for _, key := range sort {
+		op := " > ?"
+		if key.Desc {
+			op = " < ?"
+		}
+		terms = append(terms, "("+equal+key.Field+op+")")
+		args = append(append(args, equalArgs...), value(key.Field))
+		equal, equalArgs = equal+key.Field+" = ? AND ", append(equalArgs, value(key.Field))
+	}
+	terms = append(terms, "("+equal+"id > ?)")
+	args = append(append(args, equalArgs...), v.Id)
+
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// FindById is a method that returns the vehicle with the given id
+func (r *VehicleSQLite) FindById(ctx context.Context, id int, retired internal.Retired) (v internal.Vehicle, err error) {
+	v, err = r.findById(ctx, r.db, id)
+	if err == nil && !retired.Visible(v) {
+		return internal.Vehicle{}, internal.ErrVehicleNotFound
+	}
+
+	return
+}
+
+// FindByRegistration is a method that returns the vehicle with the given registration plate
+// the plate is owned by a vehicle in service, or else by the retired vehicle with the lowest id if retired includes them
+func (r *VehicleSQLite) FindByRegistration(ctx context.Context, registration string, retired internal.Retired) (v internal.Vehicle, err error) {
+	id, ok, err := r.registrationOwner(ctx, r.db, registration)
+	if err != nil {
+		return
+	}
+	if !ok && bool(retired) {
+		var retiredId sql.NullInt64
+		err = r.db.QueryRowContext(ctx, `SELECT MIN(id) FROM vehicles WHERE registration_key = ? AND retired_at IS NOT NULL`, r.nm.Key("registration", registration)).Scan(&retiredId)
+		if err != nil {
+			return
+		}
+		id, ok = int(retiredId.Int64), retiredId.Valid
+	}
+	if !ok {
+		err = internal.ErrVehicleNotFound
+		return
+	}
+
+	return r.findById(ctx, r.db, id)
+}
+
+// AddVehicle is a method that adds a vehicle to the repository
+func (r *VehicleSQLite) AddVehicle(ctx context.Context, v internal.Vehicle) (err error) {
+	v = internal.CleanVehicle(v)
+	v.Version, v.RetiredAt = 1, time.Time{}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		// verify if vehicle already exists in the repository
+		if _, err = r.findById(ctx, tx, v.Id); err != internal.ErrVehicleNotFound {
+			if err == nil {
+				err = internal.ErrVehicleAlreadyExists
+			}
+			return
+		}
+		taken, err := r.registrationTaken(ctx, tx, v.Registration, v.Id)
+		if err != nil {
+			return
+		}
+		if taken {
+			return internal.ErrVehicleRegistrationAlreadyExists
+		}
+
+		return r.insert(ctx, tx, v)
+	})
+	if err != nil {
+		return
+	}
+
+	r.observe(ctx, internal.ChangeCreate, nil, v)
+	return nil
+}
+
+// UpdateVehicle is a method that replaces a vehicle of the repository
+func (r *VehicleSQLite) UpdateVehicle(ctx context.Context, v internal.Vehicle, version int) (err error) {
+	v = internal.CleanVehicle(v)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var previous internal.Vehicle
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		// verify if vehicle exists in the repository, at the version the change is made from
+		previous, err = r.findById(ctx, tx, v.Id)
+		if err != nil {
+			return
+		}
+		if previous.Retired() {
+			return internal.ErrVehicleNotFound
+		}
+		if !internal.VersionMatches(previous.Version, version) {
+			return internal.ErrVehicleVersionMismatch
+		}
+		v.Version, v.RetiredAt = previous.Version+1, time.Time{}
+		if r.registrationChanged(previous, v) {
+			taken, err := r.registrationTaken(ctx, tx, v.Registration, v.Id)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return internal.ErrVehicleRegistrationAlreadyExists
+			}
+		}
+
+		return r.update(ctx, tx, v)
+	})
+	if err != nil {
+		return
+	}
+
+	r.observe(ctx, internal.ChangeUpdate, &previous, v)
+	return nil
+}
+
+// FindByColorAndYear is a method that returns a map of vehicles by color and year
+func (r *VehicleSQLite) FindByColorAndYear(ctx context.Context, color string, year int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.TextEq("color", color),
+		internal.NumberEq("year", float64(year)),
+	}, retired)
+}
+
+// FindByBrandAndYearRange is a method that returns a map of vehicles by brand and year range
+func (r *VehicleSQLite) FindByBrandAndYearRange(ctx context.Context, brand string, startYear int, endYear int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.TextEq("brand", brand),
+		internal.NumberGte("year", float64(startYear)),
+		internal.NumberLte("year", float64(endYear)),
+	}, retired)
+}
+
+// GetAverageSpeedByBrand is a method that returns the average speed of vehicles by brand
+func (r *VehicleSQLite) GetAverageSpeedByBrand(ctx context.Context, brand string, retired internal.Retired) (averageSpeed float64, err error) {
+	return r.average(ctx, "max_speed", brand, retired)
+}
+
+// AddVehicles is a method that adds vehicles to the repository
+// a vehicle is a duplicate if its id or registration already exists, or
+// appears earlier in the batch; if atomic is true and any vehicle is a
+// duplicate, no vehicle is added
+func (r *VehicleSQLite) AddVehicles(ctx context.Context, v []internal.Vehicle, atomic bool) (errs []error, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs = make([]error, len(v))
+	var added []internal.Vehicle
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		failed := false
+		for i, vehicle := range v {
+			vehicle = internal.CleanVehicle(vehicle)
+			vehicle.Version, vehicle.RetiredAt = 1, time.Time{}
+
+			// vehicles are inserted as they are checked, so later ones are checked against them
+			_, err = r.findById(ctx, tx, vehicle.Id)
+			switch {
+			case err == nil:
+				errs[i] = internal.ErrVehicleAlreadyExists
+			case err != internal.ErrVehicleNotFound:
+				return
+			}
+			if errs[i] == nil {
+				taken, err := r.registrationTaken(ctx, tx, vehicle.Registration, vehicle.Id)
+				if err != nil {
+					return err
+				}
+				if taken {
+					errs[i] = internal.ErrVehicleRegistrationAlreadyExists
+				}
+			}
+			if errs[i] != nil {
+				failed = true
+				continue
+			}
+
+			if err = r.insert(ctx, tx, vehicle); err != nil {
+				return
+			}
+			added = append(added, vehicle)
+		}
+		if atomic && failed {
+			return errRejected
+		}
+
+		return nil
+	})
+	if err == errRejected {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	for _, vehicle := range added {
+		r.observe(ctx, internal.ChangeCreate, nil, vehicle)
+	}
+	return
+}
+
+// FindByFuelType is a method that returns a map of vehicles by fuel type
+func (r *VehicleSQLite) FindByFuelType(ctx context.Context, fuelType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.TextEq("fuel_type", fuelType),
+	}, retired)
+}
+
+// DeleteVehicle is a method that retires a vehicle of the repository
+func (r *VehicleSQLite) DeleteVehicle(ctx context.Context, id int, version int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var previous, vehicle internal.Vehicle
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		// verify if vehicle exists in the repository, at the version the change is made from
+		previous, err = r.findById(ctx, tx, id)
+		if err != nil {
+			return
+		}
+		if previous.Retired() {
+			return internal.ErrVehicleNotFound
+		}
+		if !internal.VersionMatches(previous.Version, version) {
+			return internal.ErrVehicleVersionMismatch
+		}
+
+		vehicle = previous
+		vehicle.Version++
+		vehicle.RetiredAt = time.Now().UTC()
+		return r.update(ctx, tx, vehicle)
+	})
+	if err != nil {
+		return
+	}
+
+	r.observe(ctx, internal.ChangeDelete, &previous, vehicle)
+	return nil
+}
+
+// RestoreVehicle is a method that puts a retired vehicle of the repository back in service
+// a retired vehicle owns no plate, so its plate is checked as the one of a new vehicle: another vehicle may have taken it
+func (r *VehicleSQLite) RestoreVehicle(ctx context.Context, id int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var previous, vehicle internal.Vehicle
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		// verify if vehicle is retired
+		previous, err = r.findById(ctx, tx, id)
+		if err != nil {
+			return
+		}
+		if !previous.Retired() {
+			return internal.ErrVehicleNotRetired
+		}
+		taken, err := r.registrationTaken(ctx, tx, previous.Registration, id)
+		if err != nil {
+			return
+		}
+		if taken {
+			return internal.ErrVehicleRegistrationAlreadyExists
+		}
+
+		vehicle = previous
+		vehicle.Version++
+		vehicle.RetiredAt = time.Time{}
+		return r.update(ctx, tx, vehicle)
+	})
+	if err != nil {
+		return
+	}
+
+	r.observe(ctx, internal.ChangeRestore, &previous, vehicle)
+	return nil
+}
+
+// FindByTransmissionType is a method that returns a map of vehicles by transmission type
+func (r *VehicleSQLite) FindByTransmissionType(ctx context.Context, transmissionType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.TextEq("transmission", transmissionType),
+	}, retired)
+}
+
+// UpdatePartials is a method that updates some fields of a vehicle, keyed by the JSON name of the field
+func (r *VehicleSQLite) UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var previous, vehicle internal.Vehicle
+	err = inTx(ctx, r.db, func(tx *sql.Tx) (err error) {
+		// verify if vehicle exists in the repository, at the version the change is made from
+		previous, err = r.findById(ctx, tx, id)
+		if err != nil {
+			return
+		}
+		if previous.Retired() {
+			return internal.ErrVehicleNotFound
+		}
+		if !internal.VersionMatches(previous.Version, version) {
+			return internal.ErrVehicleVersionMismatch
+		}
+
+		vehicle = previous
+		if err = vehicle.ApplyPartials(partials, r.nm); err != nil {
+			return
+		}
+		vehicle = internal.CleanVehicle(vehicle)
+		vehicle.Version++
+		if r.registrationChanged(previous, vehicle) {
+			taken, err := r.registrationTaken(ctx, tx, vehicle.Registration, id)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return internal.ErrVehicleRegistrationAlreadyExists
+			}
+		}
+
+		return r.update(ctx, tx, vehicle)
+	})
+	if err != nil {
+		return
+	}
+
+	r.observe(ctx, internal.ChangeUpdate, &previous, vehicle)
+	return nil
+}
+
+// GetAveragePassengersByBrand is a method that returns the average passengers of vehicles by brand
+func (r *VehicleSQLite) GetAveragePassengersByBrand(ctx context.Context, brand string, retired internal.Retired) (averagePassengers float64, err error) {
+	return r.average(ctx, "passengers", brand, retired)
+}
+
+// FindByDimensions is a method that returns a map of vehicles by length and width ranges
+func (r *VehicleSQLite) FindByDimensions(ctx context.Context, minLength float64, maxLength float64, minWidth float64, maxWidth float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.NumberGte("length", minLength),
+		internal.NumberLte("length", maxLength),
+		internal.NumberGte("width", minWidth),
+		internal.NumberLte("width", maxWidth),
+	}, retired)
+}
+
+// FindByWeightRange is a method that returns a map of vehicles by weight range
+func (r *VehicleSQLite) FindByWeightRange(ctx context.Context, minWeight float64, maxWeight float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	return r.findSome(ctx, internal.VehicleFilter{
+		internal.NumberGte("weight", minWeight),
+		internal.NumberLte("weight", maxWeight),
+	}, retired)
+}
@@ -0,0 +1,160 @@
+package repository_test
+
+import (
+	"app/internal"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// newSQLite is a function that returns a migrated in-memory SQLite repository seeded with db
+func newSQLite(t *testing.T, db map[int]internal.Vehicle) *repository.VehicleSQLite {
+	sq, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	// every connection to :memory: is a different database
+	sq.SetMaxOpenConns(1)
+	t.Cleanup(func() { sq.Close() })
+
+	require.NoError(t, repository.MigrateSQLite(context.Background(), sq))
+	rp := repository.NewVehicleSQLite(sq, nil)
+	n, err := rp.Seed(context.Background(), db)
+	require.NoError(t, err)
+	require.Equal(t, len(db), n)
+
+	return rp
+}
+
+// TestVehicleSQLite_Contract tests that VehicleSQLite satisfies the conformance suite of internal.VehicleRepository
+func TestVehicleSQLite_Contract(t *testing.T) {
+	repositorytest.TestVehicleRepository(t, func(t *testing.T, db map[int]internal.Vehicle) internal.VehicleRepository {
+		return newSQLite(t, db)
+	})
+}
+
+// TestVehicleSQLite tests that the SQLite repository returns the same vehicles as the map repository
+func TestVehicleSQLite(t *testing.T) {
+	t.Run("find by filter", func(t *testing.T) {
+		// arrange
+		db := newVehicles(500)
+		rp := newSQLite(t, db)
+		filters := map[string]internal.VehicleFilter{
+			"text":         {internal.TextEq("brand", "Ford")},
+			"normalized":   {internal.TextEq("brand", " FORD ")},
+			"synonym":      {internal.TextEq("fuel_type", "gas")},
+			"range":        {internal.NumberGte("weight", 1000), internal.NumberLte("weight", 1500)},
+			"text and eq":  {internal.TextEq("color", "Red"), internal.NumberEq("year", 1990)},
+			"no match":     {internal.TextEq("brand", "Tesla")},
+			"empty filter": nil,
+		}
+		mp := repository.NewVehicleMap(newVehicles(500), nil)
+
+		for name, f := range filters {
+			// act
+			v, err := rp.FindByFilter(context.Background(), f, internal.ExcludeRetired)
+
+			// assert
+			require.NoError(t, err, name)
+			expected, err := mp.FindByFilter(context.Background(), f, internal.ExcludeRetired)
+			require.NoError(t, err, name)
+			require.Equal(t, expected, v, name)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		// arrange
+		rp := newSQLite(t, newVehicles(500))
+		mp := repository.NewVehicleMap(newVehicles(500), nil)
+		q := internal.VehicleQuery{
+			Filter: internal.VehicleFilter{internal.NumberGte("year", 1980)},
+			Sort:   []internal.VehicleSort{{Field: "brand"}, {Field: "max_speed", Desc: true}},
+			Offset: 20,
+			Limit:  50,
+		}
+
+		// act
+		v, total, err := rp.Query(context.Background(), q)
+
+		// assert
+		require.NoError(t, err)
+		expected, expectedTotal, err := mp.Query(context.Background(), q)
+		require.NoError(t, err)
+		require.Equal(t, expectedTotal, total)
+		require.Equal(t, expected, v)
+	})
+
+	t.Run("averages", func(t *testing.T) {
+		// arrange
+		rp := newSQLite(t, newVehicles(500))
+		mp := repository.NewVehicleMap(newVehicles(500), nil)
+
+		// act
+		speed, err := rp.GetAverageSpeedByBrand(context.Background(), "ford", internal.ExcludeRetired)
+		require.NoError(t, err)
+		passengers, err := rp.GetAveragePassengersByBrand(context.Background(), "ford", internal.ExcludeRetired)
+		require.NoError(t, err)
+		_, errNotFound := rp.GetAverageSpeedByBrand(context.Background(), "Tesla", internal.ExcludeRetired)
+
+		// assert
+		expectedSpeed, _ := mp.GetAverageSpeedByBrand(context.Background(), "ford", internal.ExcludeRetired)
+		expectedPassengers, _ := mp.GetAveragePassengersByBrand(context.Background(), "ford", internal.ExcludeRetired)
+		require.InDelta(t, expectedSpeed, speed, 1e-9)
+		require.InDelta(t, expectedPassengers, passengers, 1e-9)
+		require.ErrorIs(t, errNotFound, internal.ErrVehiclesNotFound)
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		// arrange
+		db := newVehicles(10)
+		rp := newSQLite(t, db)
+		vehicle := db[1]
+		vehicle.Id = 11
+
+		// act
+		errDuplicate := rp.AddVehicle(context.Background(), db[1])
+		errRegistration := rp.AddVehicle(context.Background(), vehicle)
+		errPartials := rp.UpdatePartials(context.Background(), 2, internal.VersionAny, map[string]any{"registration": db[3].Registration})
+		errUpdate := rp.UpdatePartials(context.Background(), 2, internal.VersionAny, map[string]any{"max_speed": 123.5})
+		updated, _ := rp.FindById(context.Background(), 2, internal.ExcludeRetired)
+		errDelete := rp.DeleteVehicle(context.Background(), 3, internal.VersionAny)
+		errDeleteAgain := rp.DeleteVehicle(context.Background(), 3, internal.VersionAny)
+		owner, errOwner := rp.FindByRegistration(context.Background(), db[3].Registration, internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, errDuplicate, internal.ErrVehicleAlreadyExists)
+		require.ErrorIs(t, errRegistration, internal.ErrVehicleRegistrationAlreadyExists)
+		require.ErrorIs(t, errPartials, internal.ErrVehicleRegistrationAlreadyExists)
+		require.NoError(t, errUpdate)
+		require.Equal(t, 123.5, updated.MaxSpeed)
+		require.NoError(t, errDelete)
+		require.ErrorIs(t, errDeleteAgain, internal.ErrVehicleNotFound)
+		require.ErrorIs(t, errOwner, internal.ErrVehicleNotFound)
+		require.Equal(t, internal.Vehicle{}, owner)
+	})
+
+	t.Run("add vehicles", func(t *testing.T) {
+		// arrange
+		db := newVehicles(12)
+		rp := newSQLite(t, map[int]internal.Vehicle{1: db[1]})
+		batch := []internal.Vehicle{db[2], db[1], db[3], db[2]}
+
+		// act
+		errsAtomic, err := rp.AddVehicles(context.Background(), batch, true)
+		require.NoError(t, err)
+		afterAtomic, _ := rp.FindAll(context.Background(), internal.ExcludeRetired)
+		errsBestEffort, err := rp.AddVehicles(context.Background(), batch, false)
+		require.NoError(t, err)
+		afterBestEffort, _ := rp.FindAll(context.Background(), internal.ExcludeRetired)
+
+		// assert
+		expected := []error{nil, internal.ErrVehicleAlreadyExists, nil, internal.ErrVehicleAlreadyExists}
+		require.Equal(t, expected, errsAtomic)
+		require.Len(t, afterAtomic, 1)
+		require.Equal(t, expected, errsBestEffort)
+		require.Len(t, afterBestEffort, 3)
+	})
+}
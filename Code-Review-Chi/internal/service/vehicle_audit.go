@@ -0,0 +1,93 @@
+package service
+
+import (
+	"app/internal"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewVehicleAudit is a function that returns a new instance of VehicleAudit
+func NewVehicleAudit(sv internal.VehicleService, log internal.VehicleChangeLog) *VehicleAudit {
+	return &VehicleAudit{VehicleService: sv, log: log}
+}
+
+// VehicleAudit is a struct that represents a vehicle service that records in a change log every change made through sv
+// it is the observer of the changes of the repository of sv (see internal.VehicleObserver), so a change is recorded
+// once the repository has made it, in the order they are made, and the actor is the one of the context of the change
+// the log is a history of the changes, not an audit trail: the actor is not authenticated, see internal.ActorFrom
+type VehicleAudit struct {
+	// VehicleService is the service that makes the changes
+	internal.VehicleService
+	// log is the change log where the changes are recorded
+	log internal.VehicleChangeLog
+}
+
+// Observe is a method that appends a change of a vehicle to the log
+// the change was already made, so the error of an append is returned for the repository to log it, see internal.VehicleObserver
+// a delete is recorded with the vehicle as it was, and a create or restore with every field of the vehicle
+func (s *VehicleAudit) Observe(ctx context.Context, op internal.ChangeOperation, before *internal.Vehicle, after internal.Vehicle) (err error) {
+	c := internal.VehicleChange{
+		VehicleId: after.Id,
+		Operation: op,
+		Actor:     internal.ActorFrom(ctx),
+		Timestamp: time.Now().UTC(),
+	}
+	switch op {
+	case internal.ChangeDelete:
+		c.Fields, c.Snapshot = internal.DiffVehicles(before, nil), *before
+	case internal.ChangeUpdate:
+		c.Fields, c.Snapshot = internal.DiffVehicles(before, &after), after
+	default:
+		c.Fields, c.Snapshot = internal.DiffVehicles(nil, &after), after
+	}
+
+	if _, err = s.log.Append(ctx, c); err != nil {
+		err = fmt.Errorf("change log: %w", err)
+	}
+	return
+}
+
+// RestoreVehicle is a method that puts a retired vehicle back in service
+// a vehicle removed by a delete made before the deletes retired the vehicles is added back
+// from the snapshot of its delete, at version 1, so it is recorded as a create
+func (s *VehicleAudit) RestoreVehicle(ctx context.Context, id int) (err error) {
+	err = s.VehicleService.RestoreVehicle(ctx, id)
+	if errors.Is(err, internal.ErrVehicleNotFound) {
+		err = s.restoreSnapshot(ctx, id, err)
+	}
+	return
+}
+
+// restoreSnapshot is a method that adds back a vehicle that is not in the repository from the snapshot of its delete
+// it returns notFound if the last change of the vehicle is not a delete
+// the vehicle is validated as any other vehicle that is added, e.g. its registration may have been taken since
+func (s *VehicleAudit) restoreSnapshot(ctx context.Context, id int, notFound error) (err error) {
+	c, err := s.log.FindByVehicle(ctx, id)
+	if err != nil {
+		return unknown(err)
+	}
+	if len(c) == 0 || c[len(c)-1].Operation != internal.ChangeDelete {
+		return notFound
+	}
+
+	return s.VehicleService.AddVehicle(ctx, c[len(c)-1].Snapshot)
+}
+
+// History is a method that returns the changes of a vehicle, oldest first
+// a vehicle without changes, e.g. one loaded from a file, has an empty history if it exists, even if it is retired
+func (s *VehicleAudit) History(ctx context.Context, id int) (c []internal.VehicleChange, err error) {
+	c, err = s.log.FindByVehicle(ctx, id)
+	if err != nil {
+		return nil, unknown(err)
+	}
+	if len(c) > 0 {
+		return
+	}
+
+	if _, err = s.VehicleService.FindById(ctx, id, internal.IncludeRetired); err != nil {
+		return nil, err
+	}
+	return
+}
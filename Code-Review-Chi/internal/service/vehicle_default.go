@@ -2,104 +2,117 @@ package service
 
 import (
 	"app/internal"
+	"context"
+	"errors"
 	"fmt"
 )
 
 // NewVehicleDefault is a function that returns a new instance of VehicleDefault
-func NewVehicleDefault(rp internal.VehicleRepository) *VehicleDefault {
-	return &VehicleDefault{rp: rp}
+// nm is the normalizer the fuel type and transmission of the changes are parsed with, nil means one with the default synonyms
+func NewVehicleDefault(rp internal.VehicleRepository, nm *internal.TextNormalizer) *VehicleDefault {
+	// default values
+	if nm == nil {
+		nm = internal.NewTextNormalizer(nil)
+	}
+
+	return &VehicleDefault{rp: rp, nm: nm}
 }
 
 // VehicleDefault is a struct that represents the default service for vehicles
 type VehicleDefault struct {
 	// rp is the repository that will be used by the service
 	rp internal.VehicleRepository
+	// nm is the normalizer the fuel type and transmission of the changes are parsed with,
+	// the same the repository compares the text fields with
+	nm *internal.TextNormalizer
 }
 
-func validateVehicle(v *internal.Vehicle) (err error) {
+// validateVehicle is a function that validates a vehicle, reporting every violation in a *internal.ValidationError
+// the fuel type and transmission are replaced by their canonical enum value, parsed with nm
+func validateVehicle(v *internal.Vehicle, nm *internal.TextNormalizer) (err error) {
+	var ve internal.ValidationError
+
 	// Validar el ID del vehículo
 	if v.Id <= 0 {
-		return fmt.Errorf("%w: Id must be a positive integer", internal.ErrFieldRequired)
-	}
-
-	// Validar las dimensiones del vehículo
-	if v.Height < 0 {
-		return fmt.Errorf("%w: Height must be positive values", internal.ErrFieldRequired)
-	}
-
-	if v.Length < 0 {
-		return fmt.Errorf("%w: Length must be positive values", internal.ErrFieldRequired)
-	}
-
-	if v.Width < 0 {
-		return fmt.Errorf("%w: Width must be positive values", internal.ErrFieldRequired)
+		ve.Add("id", internal.RulePositive, "id must be a positive integer")
 	}
 
-	// Validar atributos adicionales del vehículo
-	if err := validateVehicleAttributes(&v.VehicleAttributes); err != nil {
-		return err
-	}
+	// Validar los atributos y las dimensiones del vehículo
+	validateVehicleAttributes(&v.VehicleAttributes, &ve, nm)
 
-	return nil
+	return ve.Err()
 }
 
-// validateVehicleAttributes is a function that validates the attributes of a vehicle
-func validateVehicleAttributes(va *internal.VehicleAttributes) (err error) {
+// validateVehicleAttributes is a function that adds the violations of the attributes of a vehicle to ve
+// the fuel type and transmission are replaced by their canonical enum value, parsed with nm
+func validateVehicleAttributes(va *internal.VehicleAttributes, ve *internal.ValidationError, nm *internal.TextNormalizer) {
+	required := map[string]string{
+		"brand":        va.Brand,
+		"model":        va.Model,
+		"registration": va.Registration,
+		"color":        va.Color,
+	}
+	for _, field := range []string{"brand", "model", "registration", "color"} {
+		if required[field] == "" {
+			ve.Add(field, internal.RuleRequired, field+" is required")
+		}
+	}
 
 	if va.FabricationYear < 1900 {
-		return fmt.Errorf("%w: FabricationYear must be 1900 or later", internal.ErrFieldRequired)
+		ve.Add("year", internal.RuleMin, "year must be 1900 or later")
 	}
 
 	if va.Capacity <= 0 {
-		return fmt.Errorf("%w: Capacity must be a positive integer", internal.ErrFieldRequired)
+		ve.Add("passengers", internal.RulePositive, "passengers must be a positive integer")
 	}
 
 	if va.MaxSpeed <= 0 {
-		return fmt.Errorf("%w: MaxSpeed must be a positive value", internal.ErrFieldRequired)
+		ve.Add("max_speed", internal.RulePositive, "max_speed must be a positive value")
 	}
 
 	if va.Weight <= 0 {
-		return fmt.Errorf("%w: Weight must be a positive value", internal.ErrFieldRequired)
-	}
-
-	if va.Brand == "" {
-		return fmt.Errorf("%w: Brand is required", internal.ErrFieldRequired)
+		ve.Add("weight", internal.RulePositive, "weight must be a positive value")
 	}
 
-	if va.Model == "" {
-		return fmt.Errorf("%w: Model is required", internal.ErrFieldRequired)
-	}
-
-	if va.Registration == "" {
-		return fmt.Errorf("%w: Registration is required", internal.ErrFieldRequired)
-	}
-
-	if va.Color == "" {
-		return fmt.Errorf("%w: Color is required", internal.ErrFieldRequired)
+	dimensions := map[string]float64{"height": va.Height, "length": va.Length, "width": va.Width}
+	for _, field := range []string{"height", "length", "width"} {
+		if dimensions[field] < 0 {
+			ve.Add(field, internal.RuleMin, field+" must not be negative")
+		}
 	}
 
+	var fe *internal.FieldError
 	if va.FuelType == "" {
-		return fmt.Errorf("%w: FuelType is required", internal.ErrFieldRequired)
+		ve.Add("fuel_type", internal.RuleRequired, "fuel_type is required")
+	} else if fuelType, err := nm.ParseFuelType(string(va.FuelType)); errors.As(err, &fe) {
+		ve.Add("fuel_type", internal.RuleEnum, fe.Message)
+	} else {
+		va.FuelType = fuelType
 	}
 
 	if va.Transmission == "" {
-		return fmt.Errorf("%w: Transmission is required", internal.ErrFieldRequired)
+		ve.Add("transmission", internal.RuleRequired, "transmission is required")
+	} else if transmission, err := nm.ParseTransmission(string(va.Transmission)); errors.As(err, &fe) {
+		ve.Add("transmission", internal.RuleEnum, fe.Message)
+	} else {
+		va.Transmission = transmission
 	}
-
-	return nil
 }
 
-func validateYear(year int) (err error) {
-	if year < 1900 || year > 2024 {
-		return fmt.Errorf("%w: year must be between 1900 and 2024", internal.ErrFieldRequired)
+// unknown is a function that returns the error of a repository that is not known by the service
+// the cause is kept in the message to be logged, but not wrapped, so it is not mapped to a known error
+// the cancellation of the context is kept, so it is not reported as an internal error
+func unknown(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
 	}
 
-	return nil
+	return fmt.Errorf("%w: %v", internal.ErrUnknown, err)
 }
 
-func validateMaxSpeed(maxSpeed float64) (err error) {
-	if maxSpeed <= 0 {
-		return fmt.Errorf("%w: MaxSpeed must be a positive value", internal.ErrFieldRequired)
+func validateYear(year int) (err error) {
+	if year < 1900 || year > 2024 {
+		return internal.NewFieldError(internal.ErrFieldRequired, "year", "year must be between 1900 and 2024")
 	}
 
 	return nil
@@ -107,15 +120,15 @@ func validateMaxSpeed(maxSpeed float64) (err error) {
 
 func validateWeightRanges(minRange float64, maxRange float64) (err error) {
 	if minRange < 0 {
-		return fmt.Errorf("%w: MinRange must be a positive value", internal.ErrFieldRequired)
+		return internal.NewFieldError(internal.ErrFieldRequired, "min", "min must be a positive value")
 	}
 
 	if maxRange < 0 {
-		return fmt.Errorf("%w: MaxRange must be a positive value", internal.ErrFieldRequired)
+		return internal.NewFieldError(internal.ErrFieldRequired, "max", "max must be a positive value")
 	}
 
 	if minRange > maxRange {
-		return fmt.Errorf("%w: MinRange must be less than MaxRange", internal.ErrFieldRequired)
+		return internal.NewFieldError(internal.ErrFieldRequired, "min", "min must be less than max")
 	}
 
 	return nil
@@ -123,25 +136,113 @@ func validateWeightRanges(minRange float64, maxRange float64) (err error) {
 }
 
 // FindAll is a method that returns a map of all vehicles
-func (s *VehicleDefault) FindAll() (v map[int]internal.Vehicle, err error) {
-	v, err = s.rp.FindAll()
+func (s *VehicleDefault) FindAll(ctx context.Context, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	v, err = s.rp.FindAll(ctx, retired)
+	return
+}
+
+// FindByFilter is a method that returns the vehicles matching every condition of the filter
+func (s *VehicleDefault) FindByFilter(ctx context.Context, f internal.VehicleFilter, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+	v, err = s.rp.FindByFilter(ctx, f, retired)
+	if err != nil {
+		err = unknown(err)
+	}
+
+	return
+}
+
+// Query is a method that returns a page of the vehicles matching the query, in the query order
+func (s *VehicleDefault) Query(ctx context.Context, q internal.VehicleQuery) (v []internal.Vehicle, total int, err error) {
+	if err = validatePage(q); err != nil {
+		return nil, 0, err
+	}
+
+	v, total, err = s.rp.Query(ctx, q)
+	if err != nil {
+		err = unknown(err)
+	}
+
+	return
+}
+
+// Each is a method that calls fn with every vehicle of the page of the query, in the query order, in a single pass
+// the error of fn is returned as it is, the ones of the repository as in Query
+func (s *VehicleDefault) Each(ctx context.Context, q internal.VehicleQuery, fn func(v internal.Vehicle) error) (err error) {
+	if err = validatePage(q); err != nil {
+		return
+	}
+
+	var errFn error
+	err = s.rp.Each(ctx, q, func(v internal.Vehicle) error {
+		errFn = fn(v)
+		return errFn
+	})
+	if err != nil && err != errFn {
+		err = unknown(err)
+	}
+
+	return
+}
+
+// validatePage is a function that returns an error if the page of a query can not be applied
+func validatePage(q internal.VehicleQuery) (err error) {
+	if q.Offset < 0 {
+		return internal.NewFieldError(internal.ErrInvalidQuery, "offset", "offset must be a positive integer")
+	}
+	if q.Limit < 0 {
+		return internal.NewFieldError(internal.ErrInvalidQuery, "limit", "limit must be a positive integer")
+	}
+
+	return
+}
+
+// FindById is a method that returns the vehicle with the given id
+func (s *VehicleDefault) FindById(ctx context.Context, id int, retired internal.Retired) (v internal.Vehicle, err error) {
+	v, err = s.rp.FindById(ctx, id, retired)
+	if err != nil {
+		switch err {
+		case internal.ErrVehicleNotFound:
+			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, id)
+		default:
+			err = unknown(err)
+		}
+	}
+
+	return
+}
+
+// FindByRegistration is a method that returns the vehicle with the given registration plate
+func (s *VehicleDefault) FindByRegistration(ctx context.Context, registration string, retired internal.Retired) (v internal.Vehicle, err error) {
+	v, err = s.rp.FindByRegistration(ctx, registration, retired)
+	if err != nil {
+		switch err {
+		case internal.ErrVehicleNotFound:
+			err = fmt.Errorf("%w: registration %s", internal.ErrVehicleNotFound, registration)
+		default:
+			err = unknown(err)
+		}
+	}
+
 	return
 }
 
 // AddVehicle is a method that adds a vehicle to the repository
-func (s *VehicleDefault) AddVehicle(v internal.Vehicle) (err error) {
+func (s *VehicleDefault) AddVehicle(ctx context.Context, v internal.Vehicle) (err error) {
 
-	if err = validateVehicle(&v); err != nil {
+	v = internal.CleanVehicle(v)
+	if err = validateVehicle(&v, s.nm); err != nil {
 		return err
 	}
 
-	err = s.rp.AddVehicle(v)
+	err = s.rp.AddVehicle(ctx, v)
 	if err != nil {
 		switch err {
 		case internal.ErrVehicleAlreadyExists:
 			err = fmt.Errorf("%w: id", internal.ErrVehicleAlreadyExists)
+		case internal.ErrVehicleRegistrationAlreadyExists:
+			err = fmt.Errorf("%w: registration", internal.ErrVehicleRegistrationAlreadyExists)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 
 	}
@@ -150,174 +251,283 @@ func (s *VehicleDefault) AddVehicle(v internal.Vehicle) (err error) {
 
 }
 
-func (s *VehicleDefault) FindByColorAndYear(color string, year int) (v map[int]internal.Vehicle, err error) {
+// UpdateVehicle is a method that replaces all the attributes of an existing vehicle
+func (s *VehicleDefault) UpdateVehicle(ctx context.Context, v internal.Vehicle, version int) (err error) {
+
+	v = internal.CleanVehicle(v)
+	if err = validateVehicle(&v, s.nm); err != nil {
+		return err
+	}
+
+	err = s.rp.UpdateVehicle(ctx, v, version)
+	if err != nil {
+		switch err {
+		case internal.ErrVehicleNotFound:
+			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, v.Id)
+		case internal.ErrVehicleVersionMismatch:
+			err = fmt.Errorf("%w: id %d is not at version %d", internal.ErrVehicleVersionMismatch, v.Id, version)
+		case internal.ErrVehicleRegistrationAlreadyExists:
+			err = fmt.Errorf("%w: registration %s", internal.ErrVehicleRegistrationAlreadyExists, v.Registration)
+		default:
+			err = unknown(err)
+		}
+	}
+
+	return
+}
+
+func (s *VehicleDefault) FindByColorAndYear(ctx context.Context, color string, year int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
 
 	if err = validateYear(year); err != nil {
 		return nil, err
 	}
 
-	v, err = s.rp.FindByColorAndYear(color, year)
+	v, err = s.rp.FindByColorAndYear(ctx, color, year, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: color %s and year %d", internal.ErrVehiclesNotFound, color, year)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) FindByBrandAndYearRange(brand string, startYear int, endYear int) (v map[int]internal.Vehicle, err error) {
+func (s *VehicleDefault) FindByBrandAndYearRange(ctx context.Context, brand string, startYear int, endYear int, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
 
 	if err = validateYear(startYear); err != nil {
-		return nil, fmt.Errorf("%w: startYear must be greater than 1900", internal.ErrFieldRequired)
+		return nil, internal.NewFieldError(internal.ErrFieldRequired, "start_year", "start_year must be greater than 1900")
 	}
 
 	if err = validateYear(endYear); err != nil {
-		return nil, fmt.Errorf("%w: endYear must be less than 2024", internal.ErrFieldRequired)
+		return nil, internal.NewFieldError(internal.ErrFieldRequired, "end_year", "end_year must be less than 2024")
 	}
 
-	v, err = s.rp.FindByBrandAndYearRange(brand, startYear, endYear)
+	v, err = s.rp.FindByBrandAndYearRange(ctx, brand, startYear, endYear, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: brand %s and year range %d - %d", internal.ErrVehiclesNotFound, brand, startYear, endYear)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) GetAverageSpeedByBrand(brand string) (averageSpeed float64, err error) {
-	averageSpeed, err = s.rp.GetAverageSpeedByBrand(brand)
+func (s *VehicleDefault) GetAverageSpeedByBrand(ctx context.Context, brand string, retired internal.Retired) (averageSpeed float64, err error) {
+	averageSpeed, err = s.rp.GetAverageSpeedByBrand(ctx, brand, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: brand %s", internal.ErrVehiclesNotFound, brand)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) AddVehicles(v []internal.Vehicle) (err error) {
-	err = s.rp.AddVehicles(v)
+// AddVehicles is a method that adds a batch of vehicles to the repository
+// every vehicle is validated, and the result of each one is returned in the order of the batch
+// - in BatchAtomic mode no vehicle is added if any is invalid or duplicate, the others are skipped
+// - in BatchBestEffort mode every valid vehicle that is not a duplicate is added
+func (s *VehicleDefault) AddVehicles(ctx context.Context, v []internal.Vehicle, mode internal.BatchMode) (r []internal.VehicleBatchResult, err error) {
+	r = make([]internal.VehicleBatchResult, len(v))
+
+	// validate
+	valid := make([]internal.Vehicle, 0, len(v))
+	indexes := make([]int, 0, len(v))
+	for i := range v {
+		vehicle := internal.CleanVehicle(v[i])
+		r[i] = internal.VehicleBatchResult{Index: i, Id: vehicle.Id, Status: internal.BatchSkipped}
+
+		var ve *internal.ValidationError
+		if errors.As(validateVehicle(&vehicle, s.nm), &ve) {
+			r[i].Status, r[i].Err, r[i].Violations = internal.BatchInvalid, ve, ve.Violations
+			continue
+		}
+		valid = append(valid, vehicle)
+		indexes = append(indexes, i)
+	}
+	if mode == internal.BatchAtomic && len(valid) < len(v) {
+		return
+	}
+
+	// add
+	errs, err := s.rp.AddVehicles(ctx, valid, mode == internal.BatchAtomic)
 	if err != nil {
-		switch err {
-		case internal.ErrVehicleAlreadyExists:
-			err = fmt.Errorf("%w: id", internal.ErrVehicleAlreadyExists)
-		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+		return nil, unknown(err)
+	}
+
+	failed := false
+	for i, e := range errs {
+		if e != nil {
+			failed = true
+			r[indexes[i]].Status, r[indexes[i]].Err = internal.BatchDuplicate, e
+		}
+	}
+	for i, e := range errs {
+		if e == nil && !(mode == internal.BatchAtomic && failed) {
+			r[indexes[i]].Status = internal.BatchCreated
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) FindByFuelType(fuelType string) (v map[int]internal.Vehicle, err error) {
+func (s *VehicleDefault) FindByFuelType(ctx context.Context, fuelType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
 
-	v, err = s.rp.FindByFuelType(fuelType)
+	v, err = s.rp.FindByFuelType(ctx, fuelType, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: fuelType %s", internal.ErrVehiclesNotFound, fuelType)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) DeleteVehicle(id int) (err error) {
-	err = s.rp.DeleteVehicle(id)
+// DeleteVehicle is a method that retires a vehicle
+func (s *VehicleDefault) DeleteVehicle(ctx context.Context, id int, version int) (err error) {
+	err = s.rp.DeleteVehicle(ctx, id, version)
 	if err != nil {
 		switch err {
 		case internal.ErrVehicleNotFound:
 			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, id)
+		case internal.ErrVehicleVersionMismatch:
+			err = fmt.Errorf("%w: id %d is not at version %d", internal.ErrVehicleVersionMismatch, id, version)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) FindByTransmissionType(transmissionType string) (v map[int]internal.Vehicle, err error) {
+// RestoreVehicle is a method that puts a retired vehicle back in service
+func (s *VehicleDefault) RestoreVehicle(ctx context.Context, id int) (err error) {
+	err = s.rp.RestoreVehicle(ctx, id)
+	if err != nil {
+		switch err {
+		case internal.ErrVehicleNotFound:
+			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, id)
+		case internal.ErrVehicleNotRetired:
+			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotRetired, id)
+		case internal.ErrVehicleRegistrationAlreadyExists:
+			err = fmt.Errorf("%w: the registration of id %d is used by another vehicle", internal.ErrVehicleRegistrationAlreadyExists, id)
+		default:
+			err = unknown(err)
+		}
+	}
 
-	v, err = s.rp.FindByTransmissionType(transmissionType)
+	return
+}
+
+func (s *VehicleDefault) FindByTransmissionType(ctx context.Context, transmissionType string, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
+
+	v, err = s.rp.FindByTransmissionType(ctx, transmissionType, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: transmissionType %s", internal.ErrVehiclesNotFound, transmissionType)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (r *VehicleDefault) UpdatePartials(id int, partials map[string]interface{}) (err error) {
+// UpdatePartials is a method that updates some fields of a vehicle
+// the fields are type checked and the resulting vehicle is validated before the update
+func (r *VehicleDefault) UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error) {
+
+	// report the invalid fields together with the violations of the updated vehicle
+	var ve internal.ValidationError
+	partials, err = internal.NormalizeVehiclePartials(partials, r.nm)
+	if err != nil {
+		ve.Merge("", err.(*internal.ValidationError))
+	}
 
-	for key, value := range partials {
-		switch key {
-		case "max_speed":
-			if err = validateMaxSpeed(value.(float64)); err != nil {
-				return err
-			}
+	// validate the vehicle as it would be after the update
+	v, err := r.rp.FindById(ctx, id, internal.ExcludeRetired)
+	if err != nil {
+		switch err {
+		case internal.ErrVehicleNotFound:
+			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, id)
+		default:
+			err = unknown(err)
 		}
+		return
+	}
+	if !internal.VersionMatches(v.Version, version) {
+		return fmt.Errorf("%w: id %d is not at version %d", internal.ErrVehicleVersionMismatch, id, version)
+	}
+	if err = v.ApplyPartials(partials, r.nm); err != nil {
+		return err
+	}
+	if err = validateVehicle(&v, r.nm); err != nil {
+		ve.Merge("", err.(*internal.ValidationError))
+	}
+	if err = ve.Err(); err != nil {
+		return
 	}
 
-	err = r.rp.UpdatePartials(id, partials)
+	err = r.rp.UpdatePartials(ctx, id, version, partials)
 	if err != nil {
 		switch err {
 		case internal.ErrVehicleNotFound:
 			err = fmt.Errorf("%w: id %d", internal.ErrVehicleNotFound, id)
+		case internal.ErrVehicleVersionMismatch:
+			err = fmt.Errorf("%w: id %d is not at version %d", internal.ErrVehicleVersionMismatch, id, version)
+		case internal.ErrVehicleRegistrationAlreadyExists:
+			err = fmt.Errorf("%w: registration %s", internal.ErrVehicleRegistrationAlreadyExists, v.Registration)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (s *VehicleDefault) GetAveragePassengersByBrand(brand string) (averagePassengers float64, err error) {
-	averagePassengers, err = s.rp.GetAveragePassengersByBrand(brand)
+func (s *VehicleDefault) GetAveragePassengersByBrand(ctx context.Context, brand string, retired internal.Retired) (averagePassengers float64, err error) {
+	averagePassengers, err = s.rp.GetAveragePassengersByBrand(ctx, brand, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: brand %s", internal.ErrVehiclesNotFound, brand)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
 	return
 }
 
-func (r *VehicleDefault) FindByDimensions(minLength float64, maxLength float64, minWidth float64, maxWidth float64) (v map[int]internal.Vehicle, err error) {
+func (r *VehicleDefault) FindByDimensions(ctx context.Context, minLength float64, maxLength float64, minWidth float64, maxWidth float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
 
-	v, err = r.rp.FindByDimensions(minLength, maxLength, minWidth, maxWidth)
+	v, err = r.rp.FindByDimensions(ctx, minLength, maxLength, minWidth, maxWidth, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: dimensions %f - %f, %f - %f", internal.ErrVehiclesNotFound, minLength, maxLength, minWidth, maxWidth)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
@@ -325,20 +535,20 @@ func (r *VehicleDefault) FindByDimensions(minLength float64, maxLength float64,
 
 }
 
-func (r *VehicleDefault) FindByWeightRange(minWeight float64, maxWeight float64) (v map[int]internal.Vehicle, err error) {
+func (r *VehicleDefault) FindByWeightRange(ctx context.Context, minWeight float64, maxWeight float64, retired internal.Retired) (v map[int]internal.Vehicle, err error) {
 
 	if err = validateWeightRanges(minWeight, maxWeight); err != nil {
 		return nil, err
 	}
 
-	v, err = r.rp.FindByWeightRange(minWeight, maxWeight)
+	v, err = r.rp.FindByWeightRange(ctx, minWeight, maxWeight, retired)
 
 	if err != nil {
 		switch err {
 		case internal.ErrVehiclesNotFound:
 			err = fmt.Errorf("%w: weight %f - %f", internal.ErrVehiclesNotFound, minWeight, maxWeight)
 		default:
-			err = fmt.Errorf("%w", internal.ErrUnknown)
+			err = unknown(err)
 		}
 	}
 
@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"app/internal"
+	"app/internal/service"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepository is a repository whose reads of a vehicle by id fail with err
+type failingRepository struct {
+	internal.VehicleRepository
+	err error
+}
+
+// FindById returns the error of the repository
+func (r *failingRepository) FindById(ctx context.Context, id int, retired internal.Retired) (v internal.Vehicle, err error) {
+	return v, r.err
+}
+
+// TestVehicleDefault_Unknown tests that an error of the repository the service does not know is an internal error
+// that keeps its cause in the message
+func TestVehicleDefault_Unknown(t *testing.T) {
+	t.Run("cause in the message", func(t *testing.T) {
+		// arrange
+		sv := service.NewVehicleDefault(&failingRepository{err: errors.New("disk I/O error")}, nil)
+
+		// act
+		_, err := sv.FindById(context.Background(), 1, internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, err, internal.ErrUnknown)
+		require.EqualError(t, err, "unknown error: disk I/O error")
+	})
+
+	t.Run("known cause not wrapped", func(t *testing.T) {
+		// arrange
+		sv := service.NewVehicleDefault(&failingRepository{err: errors.Join(errors.New("read"), internal.ErrVehicleNotFound)}, nil)
+
+		// act
+		_, err := sv.FindById(context.Background(), 1, internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, err, internal.ErrUnknown)
+		require.NotErrorIs(t, err, internal.ErrVehicleNotFound)
+	})
+
+	t.Run("cancellation kept", func(t *testing.T) {
+		// arrange
+		sv := service.NewVehicleDefault(&failingRepository{err: context.Canceled}, nil)
+
+		// act
+		_, err := sv.FindById(context.Background(), 1, internal.ExcludeRetired)
+
+		// assert
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, internal.ErrUnknown)
+	})
+}
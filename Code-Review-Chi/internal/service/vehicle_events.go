@@ -0,0 +1,33 @@
+package service
+
+import (
+	"app/internal"
+	"context"
+	"time"
+)
+
+// NewVehicleNotifier is a function that returns a new instance of VehicleNotifier
+func NewVehicleNotifier(bus internal.VehicleEventBus) *VehicleNotifier {
+	return &VehicleNotifier{bus: bus}
+}
+
+// VehicleNotifier is a struct that represents an observer of the changes of a repository that publishes an event
+// of every change on a bus (see internal.VehicleObserver), so the events are published in the order the changes are made
+type VehicleNotifier struct {
+	// bus is the bus where the events are published
+	bus internal.VehicleEventBus
+}
+
+// vehicleEventTypes are the types of the events of the changes, by operation
+var vehicleEventTypes = map[internal.ChangeOperation]internal.VehicleEventType{
+	internal.ChangeCreate:  internal.EventCreated,
+	internal.ChangeUpdate:  internal.EventUpdated,
+	internal.ChangeDelete:  internal.EventDeleted,
+	internal.ChangeRestore: internal.EventRestored,
+}
+
+// Observe is a method that publishes an event of a change of a vehicle, with the vehicle after the change
+func (s *VehicleNotifier) Observe(ctx context.Context, op internal.ChangeOperation, before *internal.Vehicle, after internal.Vehicle) (err error) {
+	s.bus.Publish(internal.VehicleEvent{Type: vehicleEventTypes[op], Vehicle: after, Timestamp: time.Now().UTC()})
+	return
+}
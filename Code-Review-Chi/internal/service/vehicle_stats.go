@@ -0,0 +1,134 @@
+package service
+
+import (
+	"app/internal"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GetStats is a method that returns the statistics of the vehicles matching the query
+// the vehicles are grouped by the key of the group field (see internal.TextNormalizer), and every aggregation
+// is computed for every metric within each group; groups are labeled by a spelling of their value and sorted by it
+func (s *VehicleDefault) GetStats(ctx context.Context, q internal.VehicleStatsQuery) (g []internal.VehicleStatsGroup, err error) {
+	if err = q.Validate(); err != nil {
+		return
+	}
+
+	v, err := s.rp.FindByFilter(ctx, q.Filter, q.Retired)
+	if err != nil {
+		return nil, unknown(err)
+	}
+	if len(v) == 0 {
+		return nil, fmt.Errorf("%w: statistics", internal.ErrVehiclesNotFound)
+	}
+
+	// group vehicles
+	// - by the key of the group field, so "Ford" and "FORD", or "petrol" and "gasoline", are the same group
+	groups := make(map[string][]internal.Vehicle)
+	spellings := make(map[string]map[string]int)
+	for _, vh := range v {
+		text, _ := vh.FieldText(q.GroupBy)
+		key := s.nm.Key(q.GroupBy, text)
+		groups[key] = append(groups[key], vh)
+		if spellings[key] == nil {
+			spellings[key] = make(map[string]int)
+		}
+		spellings[key][s.label(q.GroupBy, text)]++
+	}
+
+	// - each group is labeled with its most common spelling, the first one in order on a tie
+	labels := make(map[string]string, len(groups))
+	keys := make([]string, 0, len(groups))
+	for key, counts := range spellings {
+		for spelling, n := range counts {
+			label := labels[key]
+			if best := counts[label]; n > best || (n == best && spelling < label) {
+				labels[key] = spelling
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return labels[keys[i]] < labels[keys[j]] })
+
+	// aggregate every metric of every group
+	g = make([]internal.VehicleStatsGroup, 0, len(keys))
+	for _, key := range keys {
+		group := internal.VehicleStatsGroup{
+			Key:     labels[key],
+			Count:   len(groups[key]),
+			Metrics: make(map[string]map[string]float64, len(q.Metrics)),
+		}
+
+		for _, metric := range q.Metrics {
+			values := make([]float64, 0, len(groups[key]))
+			for _, vh := range groups[key] {
+				n, _ := vh.FieldNumber(metric)
+				values = append(values, n)
+			}
+			group.Metrics[metric] = aggregate(values, q.Aggregations)
+		}
+
+		g = append(g, group)
+	}
+
+	return
+}
+
+// label is a method that returns the spelling of the value of a field a group is labeled with
+// the fuel type and transmission are labeled with their allowed value, e.g. "gasoline" for "petrol"
+func (s *VehicleDefault) label(field string, text string) string {
+	var vh internal.Vehicle
+	if (field != "fuel_type" && field != "transmission") || vh.SetFieldText(field, text, s.nm) != nil {
+		return text
+	}
+
+	text, _ = vh.FieldText(field)
+	return text
+}
+
+// aggregate is a function that computes the aggregations over a non empty list of values
+func aggregate(values []float64, aggregations []string) (a map[string]float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, n := range sorted {
+		sum += n
+	}
+
+	a = make(map[string]float64, len(aggregations))
+	for _, agg := range aggregations {
+		switch agg {
+		case "avg":
+			a[agg] = sum / float64(len(sorted))
+		case "min":
+			a[agg] = sorted[0]
+		case "max":
+			a[agg] = sorted[len(sorted)-1]
+		case "sum":
+			a[agg] = sum
+		case "p50":
+			a[agg] = percentile(sorted, 50)
+		case "p95":
+			a[agg] = percentile(sorted, 95)
+		case "count":
+			a[agg] = float64(len(sorted))
+		}
+	}
+
+	return
+}
+
+// percentile is a function that returns the p-th percentile of a sorted non empty list of values
+// it interpolates linearly between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower, upper := math.Floor(rank), math.Ceil(rank)
+	if lower == upper {
+		return sorted[int(rank)]
+	}
+
+	return sorted[int(lower)] + (rank-lower)*(sorted[int(upper)]-sorted[int(lower)])
+}
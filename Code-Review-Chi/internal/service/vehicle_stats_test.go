@@ -0,0 +1,155 @@
+package service_test
+
+import (
+	"app/internal"
+	"app/internal/repository"
+	"app/internal/repository/repositorytest"
+	"app/internal/service"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleDefault_GetStats tests the grouping and aggregations of the statistics of the vehicles of repositorytest.Vehicles
+// - max_speed: Ford 180, 200; Toyota 190, 170
+// - weight: Fiesta 1100, Focus 1300, Corolla 1250, Hilux 2100
+// - passengers: 5, 5, 5, 2
+// - year: 2010, 2015, 2010, 2020
+func TestVehicleDefault_GetStats(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    internal.VehicleStatsQuery
+		expected []internal.VehicleStatsGroup
+	}{
+		{
+			name:  "every aggregation of a single group",
+			query: internal.VehicleStatsQuery{Metrics: []string{"max_speed"}, Aggregations: internal.StatsAggregations},
+			expected: []internal.VehicleStatsGroup{
+				// p50: rank 0.5*3 = 1.5 between 180 and 190; p95: rank 0.95*3 = 2.85 between 190 and 200
+				{Key: "", Count: 4, Metrics: map[string]map[string]float64{
+					"max_speed": {"avg": 185, "min": 170, "max": 200, "sum": 740, "p50": 185, "p95": 198.5, "count": 4},
+				}},
+			},
+		},
+		{
+			name:  "percentiles interpolated within each group",
+			query: internal.VehicleStatsQuery{Metrics: []string{"max_speed"}, GroupBy: "brand", Aggregations: []string{"p50", "p95"}},
+			expected: []internal.VehicleStatsGroup{
+				{Key: "Ford", Count: 2, Metrics: map[string]map[string]float64{"max_speed": {"p50": 190, "p95": 199}}},
+				{Key: "Toyota", Count: 2, Metrics: map[string]map[string]float64{"max_speed": {"p50": 180, "p95": 189}}},
+			},
+		},
+		{
+			name:  "single element groups",
+			query: internal.VehicleStatsQuery{Metrics: []string{"weight"}, GroupBy: "model", Aggregations: []string{"min", "max", "p50", "p95", "count"}},
+			expected: []internal.VehicleStatsGroup{
+				{Key: "Corolla", Count: 1, Metrics: map[string]map[string]float64{"weight": {"min": 1250, "max": 1250, "p50": 1250, "p95": 1250, "count": 1}}},
+				{Key: "Fiesta", Count: 1, Metrics: map[string]map[string]float64{"weight": {"min": 1100, "max": 1100, "p50": 1100, "p95": 1100, "count": 1}}},
+				{Key: "Focus", Count: 1, Metrics: map[string]map[string]float64{"weight": {"min": 1300, "max": 1300, "p50": 1300, "p95": 1300, "count": 1}}},
+				{Key: "Hilux", Count: 1, Metrics: map[string]map[string]float64{"weight": {"min": 2100, "max": 2100, "p50": 2100, "p95": 2100, "count": 1}}},
+			},
+		},
+		{
+			name:  "multiple metrics",
+			query: internal.VehicleStatsQuery{Metrics: []string{"passengers", "weight"}, Aggregations: []string{"min", "max", "avg", "count"}},
+			expected: []internal.VehicleStatsGroup{
+				{Key: "", Count: 4, Metrics: map[string]map[string]float64{
+					"passengers": {"min": 2, "max": 5, "avg": 4.25, "count": 4},
+					"weight":     {"min": 1100, "max": 2100, "avg": 1437.5, "count": 4},
+				}},
+			},
+		},
+		{
+			name:  "grouped by year",
+			query: internal.VehicleStatsQuery{Metrics: []string{"max_speed"}, GroupBy: "year", Aggregations: []string{"count", "avg", "min", "max"}},
+			expected: []internal.VehicleStatsGroup{
+				{Key: "2010", Count: 2, Metrics: map[string]map[string]float64{"max_speed": {"count": 2, "avg": 185, "min": 180, "max": 190}}},
+				{Key: "2015", Count: 1, Metrics: map[string]map[string]float64{"max_speed": {"count": 1, "avg": 200, "min": 200, "max": 200}}},
+				{Key: "2020", Count: 1, Metrics: map[string]map[string]float64{"max_speed": {"count": 1, "avg": 170, "min": 170, "max": 170}}},
+			},
+		},
+		{
+			name: "filtered",
+			query: internal.VehicleStatsQuery{
+				Filter:       internal.VehicleFilter{{Field: "brand", Operator: internal.FilterEq, Text: "Toyota"}},
+				Metrics:      []string{"weight"},
+				Aggregations: []string{"sum", "p50"},
+			},
+			expected: []internal.VehicleStatsGroup{
+				{Key: "", Count: 2, Metrics: map[string]map[string]float64{"weight": {"sum": 3350, "p50": 1675}}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			sv := service.NewVehicleDefault(repository.NewVehicleMap(repositorytest.Vehicles(), nil), nil)
+
+			// act
+			g, err := sv.GetStats(context.Background(), c.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Len(t, g, len(c.expected))
+			for i, expected := range c.expected {
+				require.Equal(t, expected.Key, g[i].Key)
+				require.Equal(t, expected.Count, g[i].Count)
+				require.Len(t, g[i].Metrics, len(expected.Metrics))
+				for metric, aggregations := range expected.Metrics {
+					require.InDeltaMapValues(t, aggregations, g[i].Metrics[metric], 1e-9, "%s of %s", metric, expected.Key)
+				}
+			}
+		})
+	}
+
+	t.Run("grouped by the key of the group field", func(t *testing.T) {
+		// arrange
+		// - Ford (1, 5) and FORD (2) are a brand, and gasoline (1, 5) and petrol (3) a fuel type
+		v := repositorytest.Vehicles()
+		v[5] = v[1]
+		vh := v[5]
+		vh.Id, vh.Registration = 5, "EEE-555"
+		v[5] = vh
+		vh = v[2]
+		vh.Brand = "FORD"
+		v[2] = vh
+		vh = v[3]
+		vh.FuelType = "petrol"
+		v[3] = vh
+		sv := service.NewVehicleDefault(repository.NewVehicleMap(v, nil), nil)
+
+		// act
+		byBrand, errBrand := sv.GetStats(context.Background(), internal.VehicleStatsQuery{Metrics: []string{"weight"}, GroupBy: "brand", Aggregations: []string{"count"}})
+		byFuel, errFuel := sv.GetStats(context.Background(), internal.VehicleStatsQuery{Metrics: []string{"weight"}, GroupBy: "fuel_type", Aggregations: []string{"count"}})
+
+		// assert
+		// - a group is labeled with its most common spelling, and the allowed value of a fuel type
+		require.NoError(t, errBrand)
+		require.NoError(t, errFuel)
+		keys := func(g []internal.VehicleStatsGroup) (k map[string]int) {
+			k = make(map[string]int, len(g))
+			for _, group := range g {
+				k[group.Key] = group.Count
+			}
+			return
+		}
+		require.Equal(t, map[string]int{"Ford": 3, "Toyota": 2}, keys(byBrand))
+		require.Equal(t, "Ford", byBrand[0].Key)
+		require.Equal(t, map[string]int{"diesel": 2, "gasoline": 3}, keys(byFuel))
+		require.Equal(t, "diesel", byFuel[0].Key)
+	})
+
+	t.Run("no vehicles", func(t *testing.T) {
+		// arrange
+		sv := service.NewVehicleDefault(repository.NewVehicleMap(nil, nil), nil)
+
+		// act
+		g, err := sv.GetStats(context.Background(), internal.VehicleStatsQuery{Metrics: []string{"weight"}, Aggregations: []string{"avg"}})
+
+		// assert
+		require.Nil(t, g)
+		require.ErrorIs(t, err, internal.ErrVehiclesNotFound)
+	})
+}
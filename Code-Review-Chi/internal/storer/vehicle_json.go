@@ -0,0 +1,115 @@
+package storer
+
+import (
+	"app/internal"
+	"app/internal/loader"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// NewVehicleJSONFile is a function that returns a new instance of VehicleJSONFile
+func NewVehicleJSONFile(path string) *VehicleJSONFile {
+	return &VehicleJSONFile{
+		path: path,
+	}
+}
+
+// VehicleJSONFile is a struct that implements the VehicleStorer interface
+// it writes the vehicles with the same schema read by loader.VehicleJSONFile
+type VehicleJSONFile struct {
+	// path is the path to the file that contains the vehicles in JSON format
+	path string
+}
+
+// Store is a method that stores the vehicles
+// the file is written to a temporary file in the same directory and then renamed
+// over the original, so a crash never leaves a partially written dataset
+func (s *VehicleJSONFile) Store(v map[int]internal.Vehicle) (err error) {
+	// sort ids so the file content is deterministic
+	ids := make([]int, 0, len(v))
+	for id := range v {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	// serialize vehicles: one vehicle per line, as in the original dataset
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, id := range ids {
+		vh := v[id]
+		record := loader.VehicleJSON{
+			Id:              vh.Id,
+			Brand:           vh.Brand,
+			Model:           vh.Model,
+			Registration:    vh.Registration,
+			Color:           vh.Color,
+			FabricationYear: vh.FabricationYear,
+			Capacity:        vh.Capacity,
+			MaxSpeed:        vh.MaxSpeed,
+			FuelType:        string(vh.FuelType),
+			Transmission:    string(vh.Transmission),
+			Weight:          vh.Weight,
+			Height:          vh.Height,
+			Length:          vh.Length,
+			Width:           vh.Width,
+			Version:         vh.Version,
+		}
+		if vh.Retired() {
+			retiredAt := vh.RetiredAt.UTC()
+			record.RetiredAt = &retiredAt
+		}
+		b, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("%w: %v", internal.ErrMarshal, err)
+		}
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		buf.Write(b)
+	}
+	buf.WriteString("]")
+
+	// write temporary file
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	tmp := file.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	// - the temporary file is created only readable by its owner, so it gets the mode of the original file
+	mode := os.FileMode(0o644)
+	if info, e := os.Stat(s.path); e == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = file.Chmod(mode); err != nil {
+		file.Close()
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+
+	// replace original file
+	if err = os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrWriteFile, err)
+	}
+
+	return
+}
@@ -0,0 +1,44 @@
+package storer_test
+
+import (
+	"app/internal/repository/repositorytest"
+	"app/internal/storer"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVehicleJSONFile_Store tests that the file is replaced keeping its mode
+func TestVehicleJSONFile_Store(t *testing.T) {
+	t.Run("mode of the original file", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(t.TempDir(), "vehicles.json")
+		require.NoError(t, os.WriteFile(path, []byte("[]"), 0o640))
+		require.NoError(t, os.Chmod(path, 0o640))
+
+		// act
+		err := storer.NewVehicleJSONFile(path).Store(repositorytest.Vehicles())
+
+		// assert
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(t.TempDir(), "vehicles.json")
+
+		// act
+		err := storer.NewVehicleJSONFile(path).Store(repositorytest.Vehicles())
+
+		// assert
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+	})
+}
@@ -1,5 +1,7 @@
 package internal
 
+import "time"
+
 // Dimensions is a struct that represents a dimension in 3d
 type Dimensions struct {
 	// Height is the height of the dimension
@@ -27,9 +29,9 @@ type VehicleAttributes struct {
 	// MaxSpeed is the maximum speed of the vehicle
 	MaxSpeed float64
 	// FuelType is the fuel type of the vehicle
-	FuelType string
+	FuelType FuelType
 	// Transmission is the transmission of the vehicle
-	Transmission string
+	Transmission Transmission
 	// Weight is the weight of the vehicle
 	Weight float64
 	// Dimensions is the dimensions of the vehicle
@@ -40,6 +42,12 @@ type VehicleAttributes struct {
 type Vehicle struct {
 	// Id is the unique identifier of the vehicle
 	Id int
+	// Version is the number of the revision of the vehicle, 1 when it is added and incremented by every update
+	// it is set by the repository, which ignores the version of the vehicles it is given
+	Version int
+	// RetiredAt is when the vehicle was retired (deleted), zero if it is in service
+	// it is set by the repository, which ignores the retirement of the vehicles it is given
+	RetiredAt time.Time
 
 	// VehicleAttribue is the attributes of a vehicle
 	VehicleAttributes
@@ -0,0 +1,44 @@
+package internal
+
+// BatchMode is the way a batch of vehicles is added
+type BatchMode string
+
+const (
+	// BatchAtomic adds every vehicle of the batch or none of them
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort adds every vehicle of the batch that can be added
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// IsValid is a method that returns true if the mode is one of the allowed values
+func (m BatchMode) IsValid() bool {
+	return m == BatchAtomic || m == BatchBestEffort
+}
+
+// BatchStatus is the outcome of a vehicle of a batch
+type BatchStatus string
+
+const (
+	// BatchCreated is the status of a vehicle that was added
+	BatchCreated BatchStatus = "created"
+	// BatchDuplicate is the status of a vehicle whose id or registration already exists
+	BatchDuplicate BatchStatus = "duplicate"
+	// BatchInvalid is the status of a vehicle that failed validation
+	BatchInvalid BatchStatus = "invalid"
+	// BatchSkipped is the status of a valid vehicle that was not added because the atomic batch failed
+	BatchSkipped BatchStatus = "skipped"
+)
+
+// VehicleBatchResult is a struct that represents the outcome of a vehicle of a batch
+type VehicleBatchResult struct {
+	// Index is the position of the vehicle in the batch
+	Index int
+	// Id is the id of the vehicle
+	Id int
+	// Status is the outcome of the vehicle
+	Status BatchStatus
+	// Err is the reason the vehicle was not added, nil if it was created or skipped
+	Err error
+	// Violations are the validation violations, for BatchInvalid
+	Violations []Violation
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// ChangeOperation is the operation of a change of a vehicle
+type ChangeOperation string
+
+const (
+	// ChangeCreate is the change of a vehicle that is added
+	ChangeCreate ChangeOperation = "create"
+	// ChangeUpdate is the change of a vehicle that is replaced or partially updated
+	ChangeUpdate ChangeOperation = "update"
+	// ChangeDelete is the change of a vehicle that is deleted
+	ChangeDelete ChangeOperation = "delete"
+	// ChangeRestore is the change of a deleted vehicle that is put back in service
+	ChangeRestore ChangeOperation = "restore"
+)
+
+// AnonymousActor is the actor of the changes made without an actor in their context
+const AnonymousActor = "anonymous"
+
+// vehicleChangeFields are the JSON names of the fields compared by DiffVehicles, in the order they are reported
+var vehicleChangeFields = []string{
+	"brand", "model", "registration", "color", "year", "passengers",
+	"max_speed", "fuel_type", "transmission", "weight", "height", "length", "width",
+}
+
+// FieldChange is a struct that represents the change of a field of a vehicle
+type FieldChange struct {
+	// Field is the JSON name of the field
+	Field string
+	// Before is the value of the field before the change formatted as text, empty if the vehicle did not exist
+	Before string
+	// After is the value of the field after the change formatted as text, empty if the vehicle was deleted
+	After string
+}
+
+// VehicleChange is a struct that represents an entry of the change log of the vehicles
+type VehicleChange struct {
+	// Id is the unique identifier of the change, set by the change log in the order the changes are appended
+	Id int
+	// VehicleId is the id of the vehicle changed
+	VehicleId int
+	// Operation is the operation of the change
+	Operation ChangeOperation
+	// Fields are the fields whose value changed, in the order of vehicleChangeFields
+	Fields []FieldChange
+	// Snapshot is the vehicle after the change, or before it for a delete
+	Snapshot Vehicle
+	// Actor is who the change is attributed to, as given by the client: it is not authenticated
+	Actor string
+	// Timestamp is when the change was made
+	Timestamp time.Time
+}
+
+// DiffVehicles is a function that returns the fields whose value is different in before and after
+// a nil vehicle is one that does not exist, so every field of the other one is reported
+func DiffVehicles(before *Vehicle, after *Vehicle) (f []FieldChange) {
+	text := func(v *Vehicle, field string) (s string) {
+		if v != nil {
+			s, _ = v.FieldText(field)
+		}
+		return
+	}
+
+	for _, field := range vehicleChangeFields {
+		b, a := text(before, field), text(after, field)
+		if b != a || before == nil || after == nil {
+			f = append(f, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+
+	return
+}
+
+// VehicleChangeLog is an interface that represents an append-only log of the changes of the vehicles
+// it is a history of the changes, not a tamper-proof audit trail: the actors of the changes are not authenticated
+type VehicleChangeLog interface {
+	// Append is a method that adds a change at the end of the log, returning it with its id
+	Append(ctx context.Context, c VehicleChange) (stored VehicleChange, err error)
+
+	// FindByVehicle is a method that returns the changes of a vehicle in the order they were appended,
+	// or an empty list if it has none
+	FindByVehicle(ctx context.Context, id int) (c []VehicleChange, err error)
+}
+
+// actorKey is the key of the actor in a context
+type actorKey struct{}
+
+// WithActor is a function that returns a copy of ctx with the actor the changes made with it are attributed to
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom is a function that returns the actor of ctx, or AnonymousActor if it has none
+// the actor is only as trustworthy as whoever set it in ctx, e.g. handler.Actor does not authenticate it
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return AnonymousActor
+}
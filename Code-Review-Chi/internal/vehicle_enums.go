@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FuelType is the fuel type of a vehicle
+type FuelType string
+
+const (
+	// FuelTypeGas is the fuel type of vehicles powered by gas
+	FuelTypeGas FuelType = "gas"
+	// FuelTypeGasoline is the fuel type of vehicles powered by gasoline
+	FuelTypeGasoline FuelType = "gasoline"
+	// FuelTypeDiesel is the fuel type of vehicles powered by diesel
+	FuelTypeDiesel FuelType = "diesel"
+	// FuelTypeBiodiesel is the fuel type of vehicles powered by biodiesel
+	FuelTypeBiodiesel FuelType = "biodiesel"
+	// FuelTypeElectric is the fuel type of vehicles powered by electricity
+	FuelTypeElectric FuelType = "electric"
+	// FuelTypeHybrid is the fuel type of vehicles powered by fuel and electricity
+	FuelTypeHybrid FuelType = "hybrid"
+)
+
+// FuelTypes are the allowed fuel types
+var FuelTypes = []FuelType{FuelTypeGas, FuelTypeGasoline, FuelTypeDiesel, FuelTypeBiodiesel, FuelTypeElectric, FuelTypeHybrid}
+
+// Transmission is the transmission of a vehicle
+type Transmission string
+
+const (
+	// TransmissionAutomatic is the transmission of vehicles that change gears automatically
+	TransmissionAutomatic Transmission = "automatic"
+	// TransmissionManual is the transmission of vehicles that change gears manually
+	TransmissionManual Transmission = "manual"
+	// TransmissionSemiAutomatic is the transmission of vehicles that change gears manually without clutch
+	TransmissionSemiAutomatic Transmission = "semi-automatic"
+)
+
+// Transmissions are the allowed transmissions
+var Transmissions = []Transmission{TransmissionAutomatic, TransmissionManual, TransmissionSemiAutomatic}
+
+// defaultNormalizer is the normalizer of the enums parsed without a configured one, see ParseFuelType
+var defaultNormalizer = NewTextNormalizer(nil)
+
+// ParseFuelType is a function that returns the allowed fuel type matching s with DefaultVehicleSynonyms
+// a value written by a client is parsed with the configured synonyms instead, see TextNormalizer.ParseFuelType
+func ParseFuelType(s string) (f FuelType, err error) {
+	return defaultNormalizer.ParseFuelType(s)
+}
+
+// ParseFuelType is a method that returns the allowed fuel type matching s
+// s is compared by key, so ignoring case, spaces and synonyms, e.g. "Petrol" is FuelTypeGasoline by default
+// a nil normalizer uses DefaultVehicleSynonyms
+func (n *TextNormalizer) ParseFuelType(s string) (f FuelType, err error) {
+	if n == nil {
+		n = defaultNormalizer
+	}
+	key := n.Key("fuel_type", s)
+	for _, f := range FuelTypes {
+		if n.Key("fuel_type", string(f)) == key {
+			return f, nil
+		}
+	}
+
+	values := make([]string, len(FuelTypes))
+	for i, f := range FuelTypes {
+		values[i] = string(f)
+	}
+	err = NewFieldError(ErrInvalidFieldEnum, "fuel_type", fmt.Sprintf("fuel_type %q must be one of %s", s, strings.Join(values, ", ")))
+	return
+}
+
+// IsValid is a method that returns true if the fuel type is one of the allowed values
+func (f FuelType) IsValid() bool {
+	for _, value := range FuelTypes {
+		if f == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MarshalText is a method that implements encoding.TextMarshaler
+func (f FuelType) MarshalText() ([]byte, error) {
+	return []byte(f), nil
+}
+
+// UnmarshalText is a method that implements encoding.TextUnmarshaler
+// it accepts any value ParseFuelType does
+func (f *FuelType) UnmarshalText(b []byte) (err error) {
+	*f, err = ParseFuelType(string(b))
+	return
+}
+
+// ParseTransmission is a function that returns the allowed transmission matching s with DefaultVehicleSynonyms
+// a value written by a client is parsed with the configured synonyms instead, see TextNormalizer.ParseTransmission
+func ParseTransmission(s string) (t Transmission, err error) {
+	return defaultNormalizer.ParseTransmission(s)
+}
+
+// ParseTransmission is a method that returns the allowed transmission matching s
+// s is compared by key, so ignoring case, spaces and synonyms, e.g. "Auto" is TransmissionAutomatic by default
+// a nil normalizer uses DefaultVehicleSynonyms
+func (n *TextNormalizer) ParseTransmission(s string) (t Transmission, err error) {
+	if n == nil {
+		n = defaultNormalizer
+	}
+	key := n.Key("transmission", s)
+	for _, t := range Transmissions {
+		if n.Key("transmission", string(t)) == key {
+			return t, nil
+		}
+	}
+
+	values := make([]string, len(Transmissions))
+	for i, t := range Transmissions {
+		values[i] = string(t)
+	}
+	err = NewFieldError(ErrInvalidFieldEnum, "transmission", fmt.Sprintf("transmission %q must be one of %s", s, strings.Join(values, ", ")))
+	return
+}
+
+// IsValid is a method that returns true if the transmission is one of the allowed values
+func (t Transmission) IsValid() bool {
+	for _, value := range Transmissions {
+		if t == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MarshalText is a method that implements encoding.TextMarshaler
+func (t Transmission) MarshalText() ([]byte, error) {
+	return []byte(t), nil
+}
+
+// UnmarshalText is a method that implements encoding.TextUnmarshaler
+// it accepts any value ParseTransmission does
+func (t *Transmission) UnmarshalText(b []byte) (err error) {
+	*t, err = ParseTransmission(string(b))
+	return
+}
@@ -0,0 +1,40 @@
+package internal
+
+import "time"
+
+// VehicleEventType is the type of an event of a vehicle
+type VehicleEventType string
+
+const (
+	// EventCreated is the event of a vehicle that is added, alone or in a batch
+	EventCreated VehicleEventType = "created"
+	// EventUpdated is the event of a vehicle that is replaced or partially updated
+	EventUpdated VehicleEventType = "updated"
+	// EventDeleted is the event of a vehicle that is retired
+	EventDeleted VehicleEventType = "deleted"
+	// EventRestored is the event of a retired vehicle that is put back in service
+	EventRestored VehicleEventType = "restored"
+)
+
+// VehicleEvent is a struct that represents a change of a vehicle published on a VehicleEventBus
+type VehicleEvent struct {
+	// Id is the unique identifier of the event, set by the bus in the order the events are published
+	Id int
+	// Type is the type of the change
+	Type VehicleEventType
+	// Vehicle is the vehicle after the change, retired for EventDeleted
+	Vehicle Vehicle
+	// Timestamp is when the change was made
+	Timestamp time.Time
+}
+
+// VehicleEventBus is an interface that represents an in-process bus of the events of the vehicles
+type VehicleEventBus interface {
+	// Publish is a method that sends an event to every subscriber, returning it with the id set by the bus
+	Publish(e VehicleEvent) (published VehicleEvent)
+	// Subscribe is a method that returns the events after the one with id lastId still kept for replay,
+	// oldest first, and a channel of the events published from then on; lastId 0 replays no events
+	// the channel is closed by unsubscribe, or by the bus if the subscriber does not keep up with it,
+	// so the subscriber subscribes again with the id of the last event it received
+	Subscribe(lastId int) (replay []VehicleEvent, events <-chan VehicleEvent, unsubscribe func())
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vehicleFieldAliases maps column names that are not JSON names to the JSON name of the field
+var vehicleFieldAliases = map[string]string{
+	"fabrication_year": "year",
+	"capacity":         "passengers",
+	"plate":            "registration",
+}
+
+// VehicleFieldByHeader is a function that returns the JSON name of the field of a vehicle named by a column header
+// headers are matched ignoring case and surrounding spaces, using spaces or hyphens instead of underscores,
+// and some aliases, e.g. "Fabrication Year" is "year"
+func VehicleFieldByHeader(header string) (field string, ok bool) {
+	field = strings.ToLower(strings.TrimSpace(header))
+	field = strings.NewReplacer(" ", "_", "-", "_").Replace(field)
+	if alias, isAlias := vehicleFieldAliases[field]; isAlias {
+		field = alias
+	}
+
+	_, isText := vehicleTextFields[field]
+	return field, isText || IsNumberField(field)
+}
+
+// SetFieldText is a method that sets a field of the vehicle, by JSON name, from its text representation
+// values are trimmed, and empty numeric values are left as zero
+// the fuel type and transmission are parsed to their allowed value with the synonyms of nm, see TextNormalizer.ParseFuelType,
+// unless they are empty; other values are not validated
+func (v *Vehicle) SetFieldText(field string, s string, nm *TextNormalizer) (err error) {
+	s = strings.TrimSpace(s)
+	switch field {
+	case "brand":
+		v.Brand = s
+	case "model":
+		v.Model = s
+	case "registration":
+		v.Registration = s
+	case "color":
+		v.Color = s
+	case "fuel_type":
+		if v.FuelType = FuelType(s); s != "" {
+			v.FuelType, err = nm.ParseFuelType(s)
+		}
+	case "transmission":
+		if v.Transmission = Transmission(s); s != "" {
+			v.Transmission, err = nm.ParseTransmission(s)
+		}
+	case "id":
+		v.Id, err = parseIntField(field, s)
+	case "year":
+		v.FabricationYear, err = parseIntField(field, s)
+	case "passengers":
+		v.Capacity, err = parseIntField(field, s)
+	case "max_speed":
+		v.MaxSpeed, err = parseFloatField(field, s)
+	case "weight":
+		v.Weight, err = parseFloatField(field, s)
+	case "height":
+		v.Height, err = parseFloatField(field, s)
+	case "length":
+		v.Length, err = parseFloatField(field, s)
+	case "width":
+		v.Width, err = parseFloatField(field, s)
+	default:
+		err = fmt.Errorf("unknown field %s", field)
+	}
+
+	return
+}
+
+// parseIntField is a function that parses the text of an integer field
+func parseIntField(field string, s string) (n int, err error) {
+	if s == "" {
+		return
+	}
+	if n, err = strconv.Atoi(s); err != nil {
+		err = fmt.Errorf("%s %q must be an integer", field, s)
+	}
+	return
+}
+
+// parseFloatField is a function that parses the text of a numeric field
+func parseFloatField(field string, s string) (n float64, err error) {
+	if s == "" {
+		return
+	}
+	if n, err = strconv.ParseFloat(s, 64); err != nil {
+		err = fmt.Errorf("%s %q must be a number", field, s)
+	}
+	return
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrInvalidFilter is an error that represents a filter that can not be applied to vehicles
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// FilterOperator is the comparison applied by a VehicleCondition
+type FilterOperator string
+
+const (
+	// FilterEq matches values equal to the condition value
+	FilterEq FilterOperator = "eq"
+	// FilterGte matches values greater than or equal to the condition value
+	FilterGte FilterOperator = "gte"
+	// FilterLte matches values less than or equal to the condition value
+	FilterLte FilterOperator = "lte"
+)
+
+// vehicleTextFields are the fields of a vehicle compared as text, by JSON name
+var vehicleTextFields = map[string]func(v Vehicle) string{
+	"brand":        func(v Vehicle) string { return v.Brand },
+	"model":        func(v Vehicle) string { return v.Model },
+	"registration": func(v Vehicle) string { return v.Registration },
+	"color":        func(v Vehicle) string { return v.Color },
+	"fuel_type":    func(v Vehicle) string { return string(v.FuelType) },
+	"transmission": func(v Vehicle) string { return string(v.Transmission) },
+}
+
+// vehicleNumberFields are the fields of a vehicle compared as numbers, by JSON name
+var vehicleNumberFields = map[string]func(v Vehicle) float64{
+	"id":         func(v Vehicle) float64 { return float64(v.Id) },
+	"year":       func(v Vehicle) float64 { return float64(v.FabricationYear) },
+	"passengers": func(v Vehicle) float64 { return float64(v.Capacity) },
+	"max_speed":  func(v Vehicle) float64 { return v.MaxSpeed },
+	"weight":     func(v Vehicle) float64 { return v.Weight },
+	"height":     func(v Vehicle) float64 { return v.Height },
+	"length":     func(v Vehicle) float64 { return v.Length },
+	"width":      func(v Vehicle) float64 { return v.Width },
+}
+
+// VehicleCondition is a struct that represents a condition over a single field of a vehicle
+type VehicleCondition struct {
+	// Field is the JSON name of the field, e.g. "brand" or "year"
+	Field string
+	// Operator is the comparison applied to the field
+	Operator FilterOperator
+	// Text is the value compared against text fields
+	Text string
+	// Number is the value compared against numeric fields
+	Number float64
+}
+
+// TextEq is a function that returns a condition matching a text field equal to value
+func TextEq(field string, value string) VehicleCondition {
+	return VehicleCondition{Field: field, Operator: FilterEq, Text: value}
+}
+
+// NumberEq is a function that returns a condition matching a numeric field equal to value
+func NumberEq(field string, value float64) VehicleCondition {
+	return VehicleCondition{Field: field, Operator: FilterEq, Number: value}
+}
+
+// NumberGte is a function that returns a condition matching a numeric field greater than or equal to value
+func NumberGte(field string, value float64) VehicleCondition {
+	return VehicleCondition{Field: field, Operator: FilterGte, Number: value}
+}
+
+// NumberLte is a function that returns a condition matching a numeric field less than or equal to value
+func NumberLte(field string, value float64) VehicleCondition {
+	return VehicleCondition{Field: field, Operator: FilterLte, Number: value}
+}
+
+// NewVehicleCondition is a function that parses a raw value into a condition over field
+// text fields only support FilterEq, numeric fields support every operator
+func NewVehicleCondition(field string, op FilterOperator, value string) (c VehicleCondition, err error) {
+	if op != FilterEq && op != FilterGte && op != FilterLte {
+		err = NewFieldError(ErrInvalidFilter, field, fmt.Sprintf("unknown operator %s", op))
+		return
+	}
+
+	if _, ok := vehicleTextFields[field]; ok {
+		if op != FilterEq {
+			err = NewFieldError(ErrInvalidFilter, field, fmt.Sprintf("field %s only supports equality", field))
+			return
+		}
+		c = TextEq(field, value)
+		return
+	}
+
+	if _, ok := vehicleNumberFields[field]; ok {
+		var n float64
+		n, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			err = NewFieldError(ErrInvalidFilter, field, fmt.Sprintf("field %s must be a number", field))
+			return
+		}
+		c = VehicleCondition{Field: field, Operator: op, Number: n}
+		return
+	}
+
+	err = NewFieldError(ErrInvalidFilter, field, fmt.Sprintf("unknown field %s", field))
+	return
+}
+
+// FieldNumber is a method that returns the value of a numeric field of the vehicle, by JSON name
+func (v Vehicle) FieldNumber(field string) (n float64, ok bool) {
+	get, ok := vehicleNumberFields[field]
+	if !ok {
+		return
+	}
+
+	n = get(v)
+	return
+}
+
+// FieldText is a method that returns the value of a field of the vehicle formatted as text, by JSON name
+func (v Vehicle) FieldText(field string) (s string, ok bool) {
+	if get, ok := vehicleTextFields[field]; ok {
+		return get(v), true
+	}
+	if get, ok := vehicleNumberFields[field]; ok {
+		return strconv.FormatFloat(get(v), 'f', -1, 64), true
+	}
+
+	return
+}
+
+// IsNumberField is a function that returns true if the field is compared as a number
+func IsNumberField(field string) bool {
+	_, ok := vehicleNumberFields[field]
+	return ok
+}
+
+// Match is a method that returns true if the vehicle satisfies the condition
+// text fields are compared exactly
+func (c VehicleCondition) Match(v Vehicle) bool {
+	return c.MatchNormalized(v, nil)
+}
+
+// MatchNormalized is a method that returns true if the vehicle satisfies the condition
+// text fields are compared by the key of the normalizer, or exactly if it is nil
+func (c VehicleCondition) MatchNormalized(v Vehicle, n *TextNormalizer) bool {
+	if get, ok := vehicleTextFields[c.Field]; ok {
+		if c.Operator != FilterEq {
+			return false
+		}
+		if n == nil {
+			return get(v) == c.Text
+		}
+		return n.Key(c.Field, get(v)) == n.Key(c.Field, c.Text)
+	}
+
+	if get, ok := vehicleNumberFields[c.Field]; ok {
+		n := get(v)
+		switch c.Operator {
+		case FilterEq:
+			return n == c.Number
+		case FilterGte:
+			return n >= c.Number
+		case FilterLte:
+			return n <= c.Number
+		}
+	}
+
+	return false
+}
+
+// VehicleFilter is a set of conditions, a vehicle matches the filter when it satisfies all of them
+// an empty filter matches every vehicle
+type VehicleFilter []VehicleCondition
+
+// Match is a method that returns true if the vehicle satisfies every condition of the filter
+// text fields are compared exactly
+func (f VehicleFilter) Match(v Vehicle) bool {
+	return f.MatchNormalized(v, nil)
+}
+
+// MatchNormalized is a method that returns true if the vehicle satisfies every condition of the filter
+// text fields are compared by the key of the normalizer, or exactly if it is nil
+func (f VehicleFilter) MatchNormalized(v Vehicle, n *TextNormalizer) bool {
+	for _, c := range f {
+		if !c.MatchNormalized(v, n) {
+			return false
+		}
+	}
+
+	return true
+}
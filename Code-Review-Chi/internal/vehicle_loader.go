@@ -1,7 +1,65 @@
 package internal
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidRecord is an error that represents records of a vehicles file that can not be loaded
+	ErrInvalidRecord = errors.New("invalid record")
+)
+
+// RecordError is a struct that represents a record of a vehicles file that can not be loaded
+type RecordError struct {
+	// Line is the line where the record starts, starting at 1
+	Line int
+	// Message describes the error
+	Message string
+}
+
+// LoadError is a struct that represents every invalid record found loading a vehicles file
+type LoadError struct {
+	// Path is the path of the file
+	Path string
+	// Records are the invalid records, in the order of the file
+	Records []RecordError
+}
+
+// Add is a method that adds an invalid record
+func (e *LoadError) Add(line int, message string) {
+	e.Records = append(e.Records, RecordError{Line: line, Message: message})
+}
+
+// Err is a method that returns the load error, or nil if there are no invalid records
+func (e *LoadError) Err() error {
+	if len(e.Records) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Error is a method that returns the error message, with a line per invalid record
+func (e *LoadError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s: %d invalid records", ErrInvalidRecord, e.Path, len(e.Records))
+	for _, r := range e.Records {
+		fmt.Fprintf(&sb, "\n\tline %d: %s", r.Line, r.Message)
+	}
+
+	return sb.String()
+}
+
+// Unwrap is a method that returns ErrInvalidRecord
+func (e *LoadError) Unwrap() error {
+	return ErrInvalidRecord
+}
+
 // VehicleLoader is an interface that represents the loader for vehicles
 type VehicleLoader interface {
 	// Load is a method that loads the vehicles
+	// records that can not be loaded, e.g. malformed or with a duplicate id, are reported in a *LoadError
 	Load() (v map[int]Vehicle, err error)
-}
\ No newline at end of file
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultVehicleSynonyms are the synonyms used when none are configured, by JSON name of the field
+var DefaultVehicleSynonyms = map[string]map[string]string{
+	"fuel_type": {
+		"petrol":     "gasoline",
+		"bio-diesel": "biodiesel",
+	},
+	"transmission": {
+		"auto":           "automatic",
+		"semi automatic": "semi-automatic",
+		"semiautomatic":  "semi-automatic",
+	},
+}
+
+// NewTextNormalizer is a function that returns a new instance of TextNormalizer
+// synonyms map values of a field, by JSON name, to their canonical value; both
+// sides are compared by key, so they are case and whitespace insensitive
+// nil synonyms means DefaultVehicleSynonyms
+func NewTextNormalizer(synonyms map[string]map[string]string) *TextNormalizer {
+	if synonyms == nil {
+		synonyms = DefaultVehicleSynonyms
+	}
+
+	n := &TextNormalizer{synonyms: make(map[string]map[string]string, len(synonyms))}
+	for field, values := range synonyms {
+		n.synonyms[field] = make(map[string]string, len(values))
+		for value, canonical := range values {
+			n.synonyms[field][n.fold(value)] = n.fold(canonical)
+		}
+	}
+
+	return n
+}
+
+// TextNormalizer is a struct that normalizes the text fields of vehicles to compare them
+// values are cleaned, case folded, normalized with Unicode NFKC and resolved to their
+// canonical synonym, so "Petrol ", "petrol" and "GASOLINE" are the same fuel type
+// it is safe for concurrent use by multiple goroutines
+type TextNormalizer struct {
+	// synonyms maps the key of a value to the key of its canonical value, by JSON name of the field
+	synonyms map[string]map[string]string
+}
+
+// fold is a method that returns the value without case, whitespace or compatibility differences
+func (n *TextNormalizer) fold(s string) string {
+	// a Caser keeps state, so a new one is used on every call
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(CleanText(s))))
+}
+
+// Key is a method that returns the value of a field as it should be compared
+// two values of a field match when their keys are equal
+func (n *TextNormalizer) Key(field string, s string) string {
+	key := n.fold(s)
+	if canonical, ok := n.synonyms[field][key]; ok {
+		return canonical
+	}
+
+	return key
+}
+
+// CleanText is a function that returns a text value as it should be stored
+// it trims and collapses whitespace and applies Unicode NFC, but keeps the case
+func CleanText(s string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(s), " "))
+}
+
+// CleanVehicle is a function that returns the vehicle with every text field cleaned
+func CleanVehicle(v Vehicle) Vehicle {
+	v.Brand = CleanText(v.Brand)
+	v.Model = CleanText(v.Model)
+	v.Registration = CleanText(v.Registration)
+	v.Color = CleanText(v.Color)
+	v.FuelType = FuelType(CleanText(string(v.FuelType)))
+	v.Transmission = Transmission(CleanText(string(v.Transmission)))
+
+	return v
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// NormalizeVehiclePartials is a function that checks the fields of a partial update
+// keys are the JSON names of the vehicle fields, and values are converted to the
+// type of the field: FuelType and Transmission for the enums (parsed with the synonyms of nm, so invalid
+// values are RuleEnum violations), string for other text fields (cleaned with
+// CleanText), int for year and passengers and float64 for the other numeric fields
+// numeric values can be any Go number or a json.Number, so maps decoded from JSON can be used as is
+// every invalid field is reported, in a *ValidationError sorted by field, and n has the valid ones
+func NormalizeVehiclePartials(partials map[string]any, nm *TextNormalizer) (n map[string]any, err error) {
+	keys := make([]string, 0, len(partials))
+	for key := range partials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	n = make(map[string]any, len(partials))
+	var ve ValidationError
+	for _, key := range keys {
+		value := partials[key]
+		switch key {
+		case "brand", "model", "registration", "color":
+			s, ok := value.(string)
+			if !ok {
+				ve.Add(key, RuleType, key+" must be a string")
+				continue
+			}
+			n[key] = CleanText(s)
+		case "fuel_type", "transmission":
+			s, ok := value.(string)
+			switch e := value.(type) {
+			case FuelType:
+				s, ok = string(e), true
+			case Transmission:
+				s, ok = string(e), true
+			}
+			if !ok {
+				ve.Add(key, RuleType, key+" must be a string")
+				continue
+			}
+
+			var parsed any
+			var fe *FieldError
+			if key == "fuel_type" {
+				parsed, err = nm.ParseFuelType(s)
+			} else {
+				parsed, err = nm.ParseTransmission(s)
+			}
+			if errors.As(err, &fe) {
+				ve.Add(key, RuleEnum, fe.Message)
+				continue
+			}
+			n[key] = parsed
+		case "year", "passengers":
+			f, ok := toFloat64(value)
+			if !ok || f != math.Trunc(f) {
+				ve.Add(key, RuleType, key+" must be an integer")
+				continue
+			}
+			n[key] = int(f)
+		case "max_speed", "weight", "height", "length", "width":
+			f, ok := toFloat64(value)
+			if !ok {
+				ve.Add(key, RuleType, key+" must be a number")
+				continue
+			}
+			n[key] = f
+		case "id":
+			ve.Add(key, RuleReadOnly, "id can not be updated")
+		default:
+			ve.Add(key, RuleUnknown, "unknown field "+key)
+		}
+	}
+
+	err = ve.Err()
+	return
+}
+
+// toFloat64 is a function that converts a numeric value to float64
+func toFloat64(value any) (f float64, ok bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// ApplyPartials is a method that sets the fields of the vehicle named in partials
+// partials are checked with NormalizeVehiclePartials, with the synonyms of nm, before any field is set
+func (v *Vehicle) ApplyPartials(partials map[string]any, nm *TextNormalizer) (err error) {
+	n, err := NormalizeVehiclePartials(partials, nm)
+	if err != nil {
+		return
+	}
+
+	for key, value := range n {
+		switch key {
+		case "brand":
+			v.Brand = value.(string)
+		case "model":
+			v.Model = value.(string)
+		case "registration":
+			v.Registration = value.(string)
+		case "color":
+			v.Color = value.(string)
+		case "fuel_type":
+			v.FuelType = value.(FuelType)
+		case "transmission":
+			v.Transmission = value.(Transmission)
+		case "year":
+			v.FabricationYear = value.(int)
+		case "passengers":
+			v.Capacity = value.(int)
+		case "max_speed":
+			v.MaxSpeed = value.(float64)
+		case "weight":
+			v.Weight = value.(float64)
+		case "height":
+			v.Height = value.(float64)
+		case "length":
+			v.Length = value.(float64)
+		case "width":
+			v.Width = value.(float64)
+		}
+	}
+
+	return
+}
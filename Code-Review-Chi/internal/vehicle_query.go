@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidQuery is an error that represents a sort or page that can not be applied to vehicles
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+// VehicleSort is a struct that represents a sort key over a field of a vehicle
+type VehicleSort struct {
+	// Field is the JSON name of the field, e.g. "max_speed"
+	Field string
+	// Desc is true when the field is sorted in descending order
+	Desc bool
+}
+
+// ParseVehicleSort is a function that parses a comma separated list of fields
+// a field prefixed with "-" is sorted in descending order, e.g. "max_speed,-year"
+func ParseVehicleSort(spec string) (s []VehicleSort, err error) {
+	if spec == "" {
+		return
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		key := VehicleSort{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field, key.Desc = key.Field[1:], true
+		}
+		if _, ok := vehicleTextFields[key.Field]; !ok && !IsNumberField(key.Field) {
+			return nil, NewFieldError(ErrInvalidQuery, "sort", fmt.Sprintf("unknown sort field %s", key.Field))
+		}
+		s = append(s, key)
+	}
+
+	return
+}
+
+// VehicleQuery is a struct that represents a filtered, sorted and paginated search of vehicles
+type VehicleQuery struct {
+	// Filter is the filter the vehicles must match
+	Filter VehicleFilter
+	// Retired is whether the retired vehicles are searched too
+	Retired Retired
+	// Sort are the sort keys, applied in order
+	// ties are always broken by id in ascending order, so the order is deterministic
+	Sort []VehicleSort
+	// Offset is the number of matching vehicles to skip
+	Offset int
+	// Limit is the maximum number of vehicles to return, 0 means no limit
+	Limit int
+}
+
+// Less is a method that reports whether vehicle a goes before vehicle b in the query order
+func (q VehicleQuery) Less(a, b Vehicle) bool {
+	for _, key := range q.Sort {
+		var cmp int
+		if get, ok := vehicleTextFields[key.Field]; ok {
+			cmp = strings.Compare(get(a), get(b))
+		} else if get, ok := vehicleNumberFields[key.Field]; ok {
+			switch na, nb := get(a), get(b); {
+			case na < nb:
+				cmp = -1
+			case na > nb:
+				cmp = 1
+			}
+		}
+
+		if cmp == 0 {
+			continue
+		}
+		if key.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+
+	return a.Id < b.Id
+}
+
+// Page is a method that returns the [start, end) bounds of the page within total results
+func (q VehicleQuery) Page(total int) (start int, end int) {
+	start, end = q.Offset, total
+	if start > total {
+		start = total
+	}
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	return
+}
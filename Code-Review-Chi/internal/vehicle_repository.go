@@ -1,41 +1,121 @@
 package internal
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 var (
 	// ErrVehicleAlreadyExists is an error that represents a vehicle already exists in the repository
 	ErrVehicleAlreadyExists = errors.New("vehicle already exists in the repository")
-	ErrUnmarshal            = errors.New("error unmarshaling file")
-	ErrMarshal              = errors.New("error marshaling file")
-	ErrWriteFile            = errors.New("error writing file")
-	ErrUnknown              = errors.New("unknown error")
-	ErrVehiclesNotFound     = errors.New("vehicles not found")
-	ErrVehicleNotFound      = errors.New("vehicle not found")
+	// ErrVehicleRegistrationAlreadyExists is an error that represents a registration plate already used by another vehicle
+	ErrVehicleRegistrationAlreadyExists = errors.New("vehicle registration already exists in the repository")
+	ErrUnmarshal                        = errors.New("error unmarshaling file")
+	ErrMarshal                          = errors.New("error marshaling file")
+	ErrWriteFile                        = errors.New("error writing file")
+	ErrUnknown                          = errors.New("unknown error")
+	ErrVehiclesNotFound                 = errors.New("vehicles not found")
+	ErrVehicleNotFound                  = errors.New("vehicle not found")
+	// ErrVehicleVersionMismatch is an error that represents a change of a vehicle made from an outdated version
+	ErrVehicleVersionMismatch = errors.New("vehicle version does not match")
+	// ErrVehicleNotRetired is an error that represents a restore of a vehicle that is in service
+	ErrVehicleNotRetired = errors.New("vehicle is not retired")
 )
 
+// VersionAny is the version a vehicle can be changed from whatever its current version is
+const VersionAny = 0
+
+// VersionMatches is a function that returns true if a vehicle at its current version can be changed from version
+func VersionMatches(current int, version int) bool {
+	return version == VersionAny || version == current
+}
+
 // VehicleRepository is an interface that represents a vehicle repository
+// the changes of a vehicle (UpdateVehicle, UpdatePartials and DeleteVehicle) are made from a version of the vehicle:
+// they return ErrVehicleVersionMismatch, and change nothing, if it is not the current version, unless it is VersionAny
+// the methods return the error of ctx if it is done before they finish, e.g. when the client of a request is gone,
+// and a method that returns the error of ctx does not change the repository
+// a deleted vehicle is retired, not removed: the methods that read vehicles skip it unless their retired param
+// (or field of the query) is IncludeRetired, the changes treat it as not found, and its registration plate is free for other vehicles
 type VehicleRepository interface {
 	// FindAll is a method that returns a map of all vehicles
-	FindAll() (v map[int]Vehicle, err error)
+	FindAll(ctx context.Context, retired Retired) (v map[int]Vehicle, err error)
+
+	// FindByFilter is a method that returns the vehicles matching every condition of the filter
+	FindByFilter(ctx context.Context, f VehicleFilter, retired Retired) (v map[int]Vehicle, err error)
+
+	// Query is a method that returns a page of the vehicles matching the query, in the query order
+	Query(ctx context.Context, q VehicleQuery) (v []Vehicle, total int, err error)
+
+	// Each is a method that calls fn with every vehicle of the page of the query, in the query order, in a single pass
+	// fn is not called while the repository is locked, and the first error of fn stops the pass and is returned
+	Each(ctx context.Context, q VehicleQuery, fn func(v Vehicle) error) (err error)
+
+	// FindById is a method that returns the vehicle with the given id
+	FindById(ctx context.Context, id int, retired Retired) (v Vehicle, err error)
 
-	AddVehicle(v Vehicle) (err error)
+	// FindByRegistration is a method that returns the vehicle with the given registration plate
+	FindByRegistration(ctx context.Context, registration string, retired Retired) (v Vehicle, err error)
 
-	FindByColorAndYear(color string, year int) (v map[int]Vehicle, err error)
+	AddVehicle(ctx context.Context, v Vehicle) (err error)
 
-	FindByBrandAndYearRange(brand string, startYear int, endYear int) (v map[int]Vehicle, err error)
+	// UpdateVehicle is a method that replaces all the attributes of an existing vehicle
+	// version is the version of the vehicle the change is made from
+	UpdateVehicle(ctx context.Context, v Vehicle, version int) (err error)
 
-	GetAverageSpeedByBrand(brand string) (averageSpeed float64, err error)
+	FindByColorAndYear(ctx context.Context, color string, year int, retired Retired) (v map[int]Vehicle, err error)
 
-	AddVehicles(v []Vehicle) (err error)
+	FindByBrandAndYearRange(ctx context.Context, brand string, startYear int, endYear int, retired Retired) (v map[int]Vehicle, err error)
 
-	FindByFuelType(fuelType string) (v map[int]Vehicle, err error)
-	DeleteVehicle(id int) (err error)
-	FindByTransmissionType(transmissionType string) (v map[int]Vehicle, err error)
-	UpdatePartials(id int, partials map[string]interface{}) (err error)
+	GetAverageSpeedByBrand(ctx context.Context, brand string, retired Retired) (averageSpeed float64, err error)
 
-	GetAveragePassengersByBrand(brand string) (averagePassengers float64, err error)
+	// AddVehicles is a method that adds a batch of vehicles
+	// errs has, for each vehicle, ErrVehicleAlreadyExists or ErrVehicleRegistrationAlreadyExists if it
+	// can not be added (the id or registration exists, or appears earlier in the batch), or nil
+	// if atomic is true and any vehicle has an error no vehicle is added, otherwise every vehicle without error is added
+	AddVehicles(ctx context.Context, v []Vehicle, atomic bool) (errs []error, err error)
+
+	FindByFuelType(ctx context.Context, fuelType string, retired Retired) (v map[int]Vehicle, err error)
+	// DeleteVehicle is a method that retires a vehicle, setting when it was retired
+	DeleteVehicle(ctx context.Context, id int, version int) (err error)
+
+	// RestoreVehicle is a method that puts a retired vehicle back in service
+	// it returns ErrVehicleNotRetired if the vehicle is in service, and ErrVehicleRegistrationAlreadyExists
+	// if its registration plate was taken by another vehicle since it was retired
+	RestoreVehicle(ctx context.Context, id int) (err error)
+	FindByTransmissionType(ctx context.Context, transmissionType string, retired Retired) (v map[int]Vehicle, err error)
+	// UpdatePartials is a method that updates some fields of a vehicle, keyed by the JSON name of the field
+	UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error)
+
+	GetAveragePassengersByBrand(ctx context.Context, brand string, retired Retired) (averagePassengers float64, err error)
+
+	FindByDimensions(ctx context.Context, minLength float64, maxLength float64, minWidth float64, maxWidth float64, retired Retired) (v map[int]Vehicle, err error)
+
+	FindByWeightRange(ctx context.Context, minWeight float64, maxWeight float64, retired Retired) (v map[int]Vehicle, err error)
+	// SetObserver is a method that sets the observer of the changes made to the vehicles, nil for none
+	// it is set before the repository is used
+	SetObserver(ob VehicleObserver)
+}
+
+// VehicleObserver is an interface that represents an observer of the changes a repository makes to its vehicles
+// a repository calls Observe with every change once it is made, while it still holds the lock of the change,
+// so the changes are observed in the order they are made, with the vehicles as they were and are without reading them again
+// Observe must not use the repository, and a slow observer delays the changes that follow
+type VehicleObserver interface {
+	// Observe is a method that receives a change of a vehicle: before is nil for a vehicle that is added,
+	// and after is the vehicle after the change, retired for a delete
+	// the change was already made, so an error is logged by the repository and the change does not fail
+	Observe(ctx context.Context, op ChangeOperation, before *Vehicle, after Vehicle) (err error)
+}
 
-	FindByDimensions(minLength float64, maxLength float64, minWidth float64, maxWidth float64) (v map[int]Vehicle, err error)
+// VehicleObservers is a list of observers that implements VehicleObserver
+// every change is sent to every observer in order, even if one returns an error
+type VehicleObservers []VehicleObserver
 
-	FindByWeightRange(minWeight float64, maxWeight float64) (v map[int]Vehicle, err error)
+// Observe is a method that sends a change to every observer in order, returning the errors of the ones that fail joined
+func (o VehicleObservers) Observe(ctx context.Context, op ChangeOperation, before *Vehicle, after Vehicle) (err error) {
+	for _, ob := range o {
+		err = errors.Join(err, ob.Observe(ctx, op, before, after))
+	}
+	return
 }
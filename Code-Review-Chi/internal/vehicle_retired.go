@@ -0,0 +1,22 @@
+package internal
+
+// Retired is a type that represents whether the reads of the vehicles include the retired ones
+type Retired bool
+
+const (
+	// ExcludeRetired is the reads of the vehicles in service only
+	ExcludeRetired Retired = false
+	// IncludeRetired is the reads of the vehicles in service and the retired ones
+	IncludeRetired Retired = true
+)
+
+// Retired is a method that returns true if the vehicle was retired
+func (v Vehicle) Retired() bool {
+	return !v.RetiredAt.IsZero()
+}
+
+// Visible is a method that returns true if the vehicle is read: it is in service,
+// or it was retired and the retired vehicles are included
+func (r Retired) Visible(v Vehicle) bool {
+	return !v.Retired() || bool(r)
+}
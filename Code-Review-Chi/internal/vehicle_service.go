@@ -1,6 +1,9 @@
 package internal
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 var (
 	ErrFieldRequired = errors.New("field is required")
@@ -9,31 +12,69 @@ var (
 )
 
 // VehicleService is an interface that represents a vehicle service
+// the methods return the error of ctx, context.Canceled or context.DeadlineExceeded, if it is done before they finish
+// the methods that read vehicles skip the retired ones unless their retired param (or field of the query) is IncludeRetired
 type VehicleService interface {
 	// FindAll is a method that returns a map of all vehicles
-	FindAll() (v map[int]Vehicle, err error)
+	FindAll(ctx context.Context, retired Retired) (v map[int]Vehicle, err error)
+
+	// FindByFilter is a method that returns the vehicles matching every condition of the filter
+	FindByFilter(ctx context.Context, f VehicleFilter, retired Retired) (v map[int]Vehicle, err error)
+
+	// Query is a method that returns a page of the vehicles matching the query, in the query order
+	Query(ctx context.Context, q VehicleQuery) (v []Vehicle, total int, err error)
+
+	// Each is a method that calls fn with every vehicle of the page of the query, in the query order, in a single pass
+	// the first error of fn stops the pass and is returned
+	Each(ctx context.Context, q VehicleQuery, fn func(v Vehicle) error) (err error)
+
+	// FindById is a method that returns the vehicle with the given id
+	FindById(ctx context.Context, id int, retired Retired) (v Vehicle, err error)
+
+	// FindByRegistration is a method that returns the vehicle with the given registration plate
+	FindByRegistration(ctx context.Context, registration string, retired Retired) (v Vehicle, err error)
 
-	AddVehicle(v Vehicle) (err error)
+	AddVehicle(ctx context.Context, v Vehicle) (err error)
 
-	FindByColorAndYear(color string, year int) (v map[int]Vehicle, err error)
+	// UpdateVehicle is a method that replaces all the attributes of an existing vehicle
+	// version is the version of the vehicle the change is made from, or VersionAny
+	UpdateVehicle(ctx context.Context, v Vehicle, version int) (err error)
 
-	FindByBrandAndYearRange(brand string, startYear int, endYear int) (v map[int]Vehicle, err error)
+	FindByColorAndYear(ctx context.Context, color string, year int, retired Retired) (v map[int]Vehicle, err error)
 
-	GetAverageSpeedByBrand(brand string) (averageSpeed float64, err error)
+	FindByBrandAndYearRange(ctx context.Context, brand string, startYear int, endYear int, retired Retired) (v map[int]Vehicle, err error)
 
-	AddVehicles(v []Vehicle) (err error)
+	GetAverageSpeedByBrand(ctx context.Context, brand string, retired Retired) (averageSpeed float64, err error)
 
-	FindByFuelType(fuelType string) (v map[int]Vehicle, err error)
+	// AddVehicles is a method that validates and adds a batch of vehicles, returning the result of each one
+	AddVehicles(ctx context.Context, v []Vehicle, mode BatchMode) (r []VehicleBatchResult, err error)
 
-	DeleteVehicle(id int) (err error)
+	FindByFuelType(ctx context.Context, fuelType string, retired Retired) (v map[int]Vehicle, err error)
 
-	FindByTransmissionType(transmissionType string) (v map[int]Vehicle, err error)
+	// DeleteVehicle is a method that retires a vehicle, which is kept but skipped by the reads, see Retired
+	DeleteVehicle(ctx context.Context, id int, version int) (err error)
 
-	UpdatePartials(id int, partials map[string]interface{}) (err error)
+	// RestoreVehicle is a method that puts a retired vehicle back in service
+	RestoreVehicle(ctx context.Context, id int) (err error)
 
-	GetAveragePassengersByBrand(brand string) (averagePassengers float64, err error)
+	FindByTransmissionType(ctx context.Context, transmissionType string, retired Retired) (v map[int]Vehicle, err error)
 
-	FindByDimensions(minLength float64, maxLength float64, minWidth float64, maxWidth float64) (v map[int]Vehicle, err error)
+	// UpdatePartials is a method that updates some fields of a vehicle, keyed by the JSON name of the field
+	UpdatePartials(ctx context.Context, id int, version int, partials map[string]interface{}) (err error)
+
+	GetAveragePassengersByBrand(ctx context.Context, brand string, retired Retired) (averagePassengers float64, err error)
+
+	FindByDimensions(ctx context.Context, minLength float64, maxLength float64, minWidth float64, maxWidth float64, retired Retired) (v map[int]Vehicle, err error)
+
+	FindByWeightRange(ctx context.Context, minWeight float64, maxWeight float64, retired Retired) (v map[int]Vehicle, err error)
+
+	// GetStats is a method that returns the statistics of the vehicles matching the query, by group
+	GetStats(ctx context.Context, q VehicleStatsQuery) (g []VehicleStatsGroup, err error)
+}
 
-	FindByWeightRange(minWeight float64, maxWeight float64) (v map[int]Vehicle, err error)
+// VehicleHistoryService is an interface that represents a service of the change history of the vehicles
+type VehicleHistoryService interface {
+	// History is a method that returns the changes of a vehicle, oldest first
+	// it returns ErrVehicleNotFound if the vehicle has no changes and does not exist
+	History(ctx context.Context, id int) (c []VehicleChange, err error)
 }
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidStats is an error that represents statistics that can not be computed for vehicles
+	ErrInvalidStats = errors.New("invalid statistics")
+)
+
+// StatsMetrics are the numeric fields of a vehicle statistics can be computed for, by JSON name
+var StatsMetrics = []string{"max_speed", "weight", "passengers", "year", "height", "length", "width"}
+
+// StatsGroups are the categorical fields of a vehicle statistics can be grouped by, by JSON name
+var StatsGroups = []string{"brand", "model", "color", "fuel_type", "transmission", "year"}
+
+// StatsAggregations are the aggregations statistics can compute
+var StatsAggregations = []string{"avg", "min", "max", "sum", "p50", "p95", "count"}
+
+// VehicleStatsQuery is a struct that represents a request for statistics over vehicles
+type VehicleStatsQuery struct {
+	// Filter is the filter the vehicles must match to be part of the statistics
+	Filter VehicleFilter
+	// Retired is whether the retired vehicles are part of the statistics too
+	Retired Retired
+	// Metrics are the numeric fields to aggregate, by JSON name
+	Metrics []string
+	// GroupBy is the field the vehicles are grouped by, by JSON name
+	// when empty every vehicle belongs to a single group
+	GroupBy string
+	// Aggregations are the aggregations computed for each metric
+	Aggregations []string
+}
+
+// Validate is a method that returns an error if the query has unknown metrics, group or aggregations
+func (q VehicleStatsQuery) Validate() (err error) {
+	if len(q.Metrics) == 0 {
+		return NewFieldError(ErrInvalidStats, "metric", "at least one metric is required")
+	}
+	for _, m := range q.Metrics {
+		if !contains(StatsMetrics, m) {
+			return NewFieldError(ErrInvalidStats, "metric", fmt.Sprintf("unknown metric %s, must be one of %s", m, strings.Join(StatsMetrics, ",")))
+		}
+	}
+
+	if q.GroupBy != "" && !contains(StatsGroups, q.GroupBy) {
+		return NewFieldError(ErrInvalidStats, "group_by", fmt.Sprintf("unknown group %s, must be one of %s", q.GroupBy, strings.Join(StatsGroups, ",")))
+	}
+
+	if len(q.Aggregations) == 0 {
+		return NewFieldError(ErrInvalidStats, "agg", "at least one aggregation is required")
+	}
+	for _, a := range q.Aggregations {
+		if !contains(StatsAggregations, a) {
+			return NewFieldError(ErrInvalidStats, "agg", fmt.Sprintf("unknown aggregation %s, must be one of %s", a, strings.Join(StatsAggregations, ",")))
+		}
+	}
+
+	return
+}
+
+// VehicleStatsGroup is a struct that represents the statistics of a group of vehicles
+type VehicleStatsGroup struct {
+	// Key is the value of the group field shared by the vehicles of the group
+	Key string
+	// Count is the number of vehicles of the group
+	Count int
+	// Metrics are the aggregations of each metric, e.g. Metrics["max_speed"]["avg"]
+	Metrics map[string]map[string]float64
+}
+
+// contains is a function that returns true if the slice contains the value
+func contains(s []string, value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
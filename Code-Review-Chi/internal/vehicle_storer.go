@@ -0,0 +1,7 @@
+package internal
+
+// VehicleStorer is an interface that represents the storer for vehicles
+type VehicleStorer interface {
+	// Store is a method that stores the vehicles, replacing any previous content
+	Store(v map[int]Vehicle) (err error)
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrValidation is an error that represents a vehicle with one or more invalid fields
+	ErrValidation = errors.New("validation failed")
+)
+
+const (
+	// RuleRequired is the rule of a field that can not be empty
+	RuleRequired = "required"
+	// RulePositive is the rule of a numeric field that must be greater than zero
+	RulePositive = "positive"
+	// RuleMin is the rule of a numeric field that has a lower bound
+	RuleMin = "min"
+	// RuleEnum is the rule of a field that must be one of the allowed values
+	RuleEnum = "enum"
+	// RuleType is the rule of a field that must be of a given type
+	RuleType = "type"
+	// RuleUnknown is the rule of a field that does not exist
+	RuleUnknown = "unknown"
+	// RuleReadOnly is the rule of a field that can not be updated
+	RuleReadOnly = "read_only"
+)
+
+// Violation is a struct that represents a rule broken by a field
+type Violation struct {
+	// Field is the JSON name of the field, e.g. "passengers"
+	Field string
+	// Rule is the rule the field breaks, e.g. RuleRequired
+	Rule string
+	// Message describes the violation
+	Message string
+}
+
+// ValidationError is a struct that represents every violation found validating a vehicle
+type ValidationError struct {
+	// Violations are the violations, in the order they were found
+	Violations []Violation
+}
+
+// Add is a method that adds a violation
+func (e *ValidationError) Add(field string, rule string, message string) {
+	e.Violations = append(e.Violations, Violation{Field: field, Rule: rule, Message: message})
+}
+
+// Merge is a method that adds the violations of other, with prefix added to their fields
+// e.g. prefix "[3]." reports the field "year" of the fourth vehicle of a batch as "[3].year"
+func (e *ValidationError) Merge(prefix string, other *ValidationError) {
+	for _, v := range other.Violations {
+		e.Add(prefix+v.Field, v.Rule, v.Message)
+	}
+}
+
+// Err is a method that returns the validation error, or nil if there are no violations
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Error is a method that returns the error message
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Unwrap is a method that returns ErrValidation
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
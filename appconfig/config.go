@@ -0,0 +1,208 @@
+// Package appconfig loads the configuration of an application from the command line, the environment
+// and a JSON config file, given a table of the options of the configuration
+package appconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidConfig is an error that represents a configuration the application can not run with
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
+// Config is an interface that represents the configuration of an application, usually a pointer to a struct
+type Config interface {
+	// Validate returns an error reporting every setting the application can not run with
+	Validate() (err error)
+}
+
+// Option is a struct that represents a setting of a configuration given by a key of the config file, a flag or an environment variable
+type Option[C Config] struct {
+	// Key is the name of the setting in the config file, e.g. server_addr
+	// the flag is the key with hyphens (-server-addr) and the variable is the key in upper case (SERVER_ADDR)
+	Key string
+	// Usage is the description of the setting
+	Usage string
+	// Set is a function that parses a value of the setting into cfg
+	Set func(cfg C, value string) error
+	// Decode is a function that decodes the value of the setting in the config file into cfg
+	// an option with Decode is only read from the config file
+	Decode func(cfg C, raw json.RawMessage) error
+}
+
+// Text is a function that returns an option of a text setting
+func Text[C Config](key string, usage string, field func(cfg C) *string) Option[C] {
+	return Option[C]{Key: key, Usage: usage, Set: func(cfg C, value string) error {
+		*field(cfg) = value
+		return nil
+	}}
+}
+
+// Duration is a function that returns an option of a duration setting, e.g. 30s or 2m
+func Duration[C Config](key string, usage string, field func(cfg C) *time.Duration) Option[C] {
+	return Option[C]{Key: key, Usage: usage, Set: func(cfg C, value string) (err error) {
+		*field(cfg), err = time.ParseDuration(value)
+		return
+	}}
+}
+
+// Int is a function that returns an option of an integer setting
+func Int[C Config](key string, usage string, field func(cfg C) *int) Option[C] {
+	return Option[C]{Key: key, Usage: usage, Set: func(cfg C, value string) (err error) {
+		*field(cfg), err = strconv.Atoi(value)
+		return
+	}}
+}
+
+// flagName is a function that returns the flag of the key of an option
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName is a function that returns the environment variable of the key of an option
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
+
+// Load is a function that sets into cfg the settings of options given by the command line args,
+// the environment variables looked up with lookupEnv and an optional config file, and validates cfg
+// - name is the name of the flag set, shown by -help
+// - a flag takes precedence over a variable, a variable over the config file and the config file over the values already in cfg
+// - the config file is a JSON object with the keys of the settings, given by -config or CONFIG_FILE; every value is a string
+// except the ones of the options with Decode
+// - empty variables are ignored
+// - an error parsing args, e.g. flag.ErrHelp, is returned as is; otherwise every invalid setting is reported in the error
+func Load[C Config](cfg C, name string, options []Option[C], args []string, lookupEnv func(key string) (string, bool)) (err error) {
+	// flags
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a JSON config file (env CONFIG_FILE)")
+	flags := make(map[string]*string, len(options))
+	for _, o := range options {
+		if o.Set == nil {
+			continue
+		}
+		flags[o.Key] = fs.String(flagName(o.Key), "", fmt.Sprintf("%s (env %s)", o.Usage, envName(o.Key)))
+	}
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var errs []error
+	// - config file
+	path := *configFile
+	if v, ok := lookupEnv("CONFIG_FILE"); ok && v != "" && !given["config"] {
+		path = v
+	}
+	if path != "" {
+		errs = append(errs, loadFile(cfg, options, path)...)
+	}
+	// - environment variables
+	for _, o := range options {
+		if o.Set == nil {
+			continue
+		}
+		if v, ok := lookupEnv(envName(o.Key)); ok && v != "" {
+			if e := o.Set(cfg, v); e != nil {
+				errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, envName(o.Key), e))
+			}
+		}
+	}
+	// - flags
+	for _, o := range options {
+		if o.Set != nil && given[flagName(o.Key)] {
+			if e := o.Set(cfg, *flags[o.Key]); e != nil {
+				errs = append(errs, fmt.Errorf("%w: -%s: %v", ErrInvalidConfig, flagName(o.Key), e))
+			}
+		}
+	}
+
+	// validation
+	errs = append(errs, cfg.Validate())
+	return errors.Join(errs...)
+}
+
+// loadFile is a function that sets the settings of the JSON config file in path into cfg
+// it returns an error for each key that is unknown or has an invalid value
+func loadFile[C Config](cfg C, options []Option[C], path string) (errs []error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("%w: %v", ErrInvalidConfig, err)}
+	}
+	var file map[string]json.RawMessage
+	if err = json.Unmarshal(data, &file); err != nil {
+		return []error{fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)}
+	}
+
+	byKey := make(map[string]Option[C], len(options))
+	for _, o := range options {
+		byKey[o.Key] = o
+	}
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := file[key]
+		invalid := func(e error) {
+			errs = append(errs, fmt.Errorf("%w: %s: %s: %v", ErrInvalidConfig, path, key, e))
+		}
+
+		o, ok := byKey[key]
+		switch {
+		case !ok:
+			invalid(errors.New("unknown setting"))
+		case o.Decode != nil:
+			if e := o.Decode(cfg, raw); e != nil {
+				invalid(e)
+			}
+		default:
+			var value string
+			if e := json.Unmarshal(raw, &value); e != nil {
+				invalid(errors.New("must be a string"))
+				continue
+			}
+			if e := o.Set(cfg, value); e != nil {
+				invalid(e)
+			}
+		}
+	}
+
+	return
+}
+
+// ValidateAddress is a function that returns an error if the setting key is not an address host:port
+func ValidateAddress(key string, address string) (err error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %q must be host:port", ErrInvalidConfig, key, address)
+	}
+	if p, e := strconv.Atoi(port); e != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("%w: %s: port %q must be a number from 0 to 65535", ErrInvalidConfig, key, port)
+	}
+	return nil
+}
+
+// ValidateFile is a function that returns an error if the setting key is not the path of an existing file
+func ValidateFile(key string, path string) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, key, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s: %s is a directory", ErrInvalidConfig, key, path)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package appconfig_test
+
+import (
+	"appconfig"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// config is a configuration of an application
+type config struct {
+	Addr     string
+	Timeout  time.Duration
+	Size     int
+	Synonyms map[string]string
+	// invalid is the error returned by Validate
+	invalid error
+}
+
+// Validate returns the error of the configuration
+func (c *config) Validate() (err error) {
+	return c.invalid
+}
+
+// options are the settings of config
+var options = []appconfig.Option[*config]{
+	appconfig.Text("addr", "address", func(cfg *config) *string { return &cfg.Addr }),
+	appconfig.Duration("timeout", "timeout", func(cfg *config) *time.Duration { return &cfg.Timeout }),
+	appconfig.Int("size", "size", func(cfg *config) *int { return &cfg.Size }),
+	{Key: "synonyms", Decode: func(cfg *config, raw json.RawMessage) error {
+		if json.Unmarshal(raw, &cfg.Synonyms) != nil {
+			return errors.New("must be an object of strings")
+		}
+		return nil
+	}},
+}
+
+// env returns a lookup of the variables of vars
+func env(vars map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+// TestLoad tests the precedence and validation of the sources of the configuration
+func TestLoad(t *testing.T) {
+	// file is a config file
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"addr": ":9001", "timeout": "10s", "size": "1", "synonyms": {"rojo": "red"}}`), 0o644))
+
+	t.Run("defaults", func(t *testing.T) {
+		// arrange
+		cfg := &config{Addr: ":8080", Size: 5}
+
+		// act
+		err := appconfig.Load(cfg, "test", options, nil, env(nil))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, &config{Addr: ":8080", Size: 5}, cfg)
+	})
+
+	t.Run("flags over environment over file", func(t *testing.T) {
+		// arrange
+		cfg := &config{}
+		args := []string{"-addr", ":9003"}
+		vars := map[string]string{"CONFIG_FILE": file, "ADDR": ":9002", "TIMEOUT": "15s", "SIZE": ""}
+
+		// act
+		err := appconfig.Load(cfg, "test", options, args, env(vars))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, &config{Addr: ":9003", Timeout: 15 * time.Second, Size: 1, Synonyms: map[string]string{"rojo": "red"}}, cfg)
+	})
+
+	t.Run("config file flag over environment", func(t *testing.T) {
+		// arrange
+		cfg := &config{}
+
+		// act
+		err := appconfig.Load(cfg, "test", options, []string{"-config", file}, env(map[string]string{"CONFIG_FILE": "missing.json"}))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, ":9001", cfg.Addr)
+	})
+
+	t.Run("options with decode are only read from the file", func(t *testing.T) {
+		// act
+		err := appconfig.Load(&config{}, "test", options, []string{"-synonyms", "{}"}, env(nil))
+
+		// assert
+		require.ErrorContains(t, err, "flag provided but not defined: -synonyms")
+		require.NotErrorIs(t, err, appconfig.ErrInvalidConfig)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		// act
+		err := appconfig.Load(&config{}, "test", options, []string{"-help"}, env(nil))
+
+		// assert
+		require.ErrorIs(t, err, flag.ErrHelp)
+	})
+
+	t.Run("every invalid setting is reported", func(t *testing.T) {
+		// arrange
+		bad := filepath.Join(t.TempDir(), "bad.json")
+		require.NoError(t, os.WriteFile(bad, []byte(`{"port": "80", "addr": 8080, "size": "x", "synonyms": []}`), 0o644))
+		cfg := &config{invalid: errors.New("invalid by validate")}
+		args := []string{"-config", bad, "-timeout", "5"}
+
+		// act
+		err := appconfig.Load(cfg, "test", options, args, env(map[string]string{"SIZE": "-"}))
+
+		// assert
+		require.ErrorIs(t, err, appconfig.ErrInvalidConfig)
+		for _, problem := range []string{
+			bad + ": addr: must be a string",
+			bad + ": port: unknown setting",
+			bad + ": size: strconv.Atoi",
+			bad + ": synonyms: must be an object of strings",
+			"SIZE: strconv.Atoi",
+			"-timeout: time: missing unit",
+			"invalid by validate",
+		} {
+			require.Contains(t, err.Error(), problem)
+		}
+	})
+}
+
+// TestValidate tests the validation of the settings shared by the applications
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.json")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	cases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "address", err: appconfig.ValidateAddress("addr", "localhost:8080")},
+		{name: "address without port", err: appconfig.ValidateAddress("addr", "localhost"), expected: `addr: "localhost" must be host:port`},
+		{name: "address with invalid port", err: appconfig.ValidateAddress("addr", ":70000"), expected: `addr: port "70000" must be a number from 0 to 65535`},
+		{name: "file", err: appconfig.ValidateFile("path", file)},
+		{name: "missing file", err: appconfig.ValidateFile("path", filepath.Join(dir, "missing.json")), expected: "path: stat"},
+		{name: "directory", err: appconfig.ValidateFile("path", dir), expected: "path: " + dir + " is a directory"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.expected == "" {
+				require.NoError(t, c.err)
+				return
+			}
+			require.ErrorIs(t, c.err, appconfig.ErrInvalidConfig)
+			require.ErrorContains(t, c.err, c.expected)
+		})
+	}
+}
@@ -2,17 +2,28 @@ package main
 
 import (
 	"app/scaffolding/internal/application"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 )
 
 func main() {
 
 	// app
-	// - config
-	app := application.NewDefaultHTTP(":8080")
+	// - config: flags take precedence over environment variables, and these over the config file, see -help
+	cfg, err := application.LoadConfigDefaultHTTP(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	app := application.NewDefaultHTTP(cfg)
 	// - run
 	if err := app.Run(); err != nil {
-		fmt.Println(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
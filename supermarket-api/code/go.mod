@@ -3,6 +3,7 @@ module app/scaffolding
 go 1.21.4
 
 require (
+	appconfig v0.0.0
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/stretchr/testify v1.8.4
 )
@@ -12,3 +13,5 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace appconfig => ../../appconfig
@@ -0,0 +1,49 @@
+package application
+
+import (
+	"appconfig"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidConfig represents a configuration the server can not run with
+	ErrInvalidConfig = appconfig.ErrInvalidConfig
+)
+
+// DefaultConfigDefaultHTTP returns the configuration used for the settings that are not given
+func DefaultConfigDefaultHTTP() *ConfigDefaultHTTP {
+	return &ConfigDefaultHTTP{
+		ServerAddr:       ":8080",
+		ProductsFilePath: "internal/products.json",
+	}
+}
+
+// configOptions are the settings of ConfigDefaultHTTP
+var configOptions = []appconfig.Option[*ConfigDefaultHTTP]{
+	appconfig.Text("server_addr", "address where the server listens, e.g. :8080", func(cfg *ConfigDefaultHTTP) *string { return &cfg.ServerAddr }),
+	appconfig.Text("products_file_path", "path to the JSON file of the products", func(cfg *ConfigDefaultHTTP) *string { return &cfg.ProductsFilePath }),
+}
+
+// LoadConfigDefaultHTTP returns the configuration given by the command line args, the environment
+// variables looked up with lookupEnv and an optional config file, over DefaultConfigDefaultHTTP (see appconfig.Load)
+func LoadConfigDefaultHTTP(args []string, lookupEnv func(key string) (string, bool)) (cfg *ConfigDefaultHTTP, err error) {
+	cfg = DefaultConfigDefaultHTTP()
+	if err = appconfig.Load(cfg, "products", configOptions, args, lookupEnv); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Validate returns an error reporting every setting the server can not run with
+func (c *ConfigDefaultHTTP) Validate() (err error) {
+	errs := []error{appconfig.ValidateAddress("server_addr", c.ServerAddr)}
+
+	if c.ProductsFilePath == "" {
+		errs = append(errs, fmt.Errorf("%w: products_file_path: is required", ErrInvalidConfig))
+	} else {
+		errs = append(errs, appconfig.ValidateFile("products_file_path", c.ProductsFilePath))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package application_test
+
+import (
+	"app/scaffolding/internal/application"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a lookup of the variables of vars
+func env(vars map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+// TestLoadConfigDefaultHTTP tests the settings of the configuration, the sources and their precedence are tested by appconfig
+func TestLoadConfigDefaultHTTP(t *testing.T) {
+	// products is a file the products file path can point to
+	products := filepath.Join(t.TempDir(), "products.json")
+	require.NoError(t, os.WriteFile(products, nil, 0o644))
+
+	t.Run("settings", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"server_addr": ":9001"}`), 0o644))
+
+		// act
+		cfg, err := application.LoadConfigDefaultHTTP([]string{"-config", file}, env(map[string]string{"PRODUCTS_FILE_PATH": products}))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, &application.ConfigDefaultHTTP{ServerAddr: ":9001", ProductsFilePath: products}, cfg)
+	})
+
+	t.Run("every invalid setting is reported", func(t *testing.T) {
+		// arrange
+		args := []string{"-server-addr", "localhost", "-products-file-path", filepath.Join(t.TempDir(), "missing.json")}
+
+		// act
+		cfg, err := application.LoadConfigDefaultHTTP(args, env(nil))
+
+		// assert
+		require.Nil(t, cfg)
+		require.ErrorIs(t, err, application.ErrInvalidConfig)
+		require.ErrorContains(t, err, `server_addr: "localhost" must be host:port`)
+		require.ErrorContains(t, err, "products_file_path: stat")
+	})
+
+	t.Run("products file path is required", func(t *testing.T) {
+		// act
+		_, err := application.LoadConfigDefaultHTTP([]string{"-products-file-path", ""}, env(nil))
+
+		// assert
+		require.ErrorContains(t, err, "products_file_path: is required")
+	})
+}
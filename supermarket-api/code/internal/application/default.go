@@ -10,24 +10,49 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func NewDefaultHTTP(addr string) *DefaultHTTP {
+// ConfigDefaultHTTP is the configuration of the http server
+type ConfigDefaultHTTP struct {
+	// ServerAddr is the address of the http server
+	ServerAddr string
+	// ProductsFilePath is the path to the JSON file of the products
+	ProductsFilePath string
+}
+
+func NewDefaultHTTP(cfg *ConfigDefaultHTTP) *DefaultHTTP {
 	// default config / values
-	// ...
+	defaultCfg := DefaultConfigDefaultHTTP()
+	if cfg != nil {
+		if cfg.ServerAddr != "" {
+			defaultCfg.ServerAddr = cfg.ServerAddr
+		}
+		if cfg.ProductsFilePath != "" {
+			defaultCfg.ProductsFilePath = cfg.ProductsFilePath
+		}
+	}
+
 	return &DefaultHTTP{
-		addr: addr,
+		addr:             defaultCfg.ServerAddr,
+		productsFilePath: defaultCfg.ProductsFilePath,
 	}
 }
 
 type DefaultHTTP struct {
 	// addr is the address of the http server
 	addr string
+	// productsFilePath is the path to the JSON file of the products
+	productsFilePath string
 }
 
 // Run runs the http server
 func (h *DefaultHTTP) Run() (err error) {
 	// // initialize dependencies
 	// // - repository
+	products, err := repository.LoadProductsFromJSON(h.productsFilePath)
+	if err != nil {
+		return
+	}
 	rp := repository.NewProductMap(make(map[int]internal.Product), 0)
+	rp.InitializeProducts(products)
 	// // - service
 	sv := service.NewProductDefault(rp)
 	// // - handler
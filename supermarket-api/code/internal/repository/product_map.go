@@ -8,21 +8,10 @@ import (
 )
 
 func NewProductMap(db map[int]internal.Product, lastId int) *ProductMap {
-
-	productsFromFile, err := loadProductsFromJSON("/Users/jguarinhenao/Desktop/bootcamp-go/supermarket-api/code/internal/products.json")
-	if err != nil {
-		fmt.Printf("Error loading products from JSON: %v\n", err)
-		return nil
-	}
-
-	productMap := &ProductMap{
+	return &ProductMap{
 		db:     db,
 		lastId: lastId,
 	}
-
-	(*productMap).initializeProducts(productsFromFile)
-
-	return productMap
 }
 
 type ProductMap struct {
@@ -90,7 +79,8 @@ func (r *ProductMap) Delete(id int) error {
 	return nil
 }
 
-func loadProductsFromJSON(filePath string) ([]internal.Product, error) {
+// LoadProductsFromJSON reads the products of the JSON file in filePath
+func LoadProductsFromJSON(filePath string) ([]internal.Product, error) {
 	fileContent, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %v", err)
@@ -105,9 +95,12 @@ func loadProductsFromJSON(filePath string) ([]internal.Product, error) {
 	return products, nil
 }
 
-func (r *ProductMap) initializeProducts(productsFromFile []internal.Product) {
+// InitializeProducts adds the products loaded from a file, keeping the last id as the greatest one
+func (r *ProductMap) InitializeProducts(productsFromFile []internal.Product) {
 	for _, product := range productsFromFile {
 		r.db[product.ID] = product
-		r.lastId = product.ID
+		if product.ID > r.lastId {
+			r.lastId = product.ID
+		}
 	}
 }
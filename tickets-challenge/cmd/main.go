@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"tickets-challenge/internal/application"
@@ -8,27 +10,30 @@ import (
 
 func main() {
 	// env
-	// ...
+	// - flags take precedence over environment variables, and these over the config file, see -help
+	cfg, err := application.LoadConfigAppDefault(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	// application
-	// - config
-	cfg := &application.ConfigAppDefault{
-		ServerAddr: os.Getenv("SERVER_ADDR"),
-		DbFile:     os.Getenv("DB_FILE"),
-	}
 	app := application.NewApplicationDefault(cfg)
 
 	// - setup
-	err := app.SetUp()
+	err = app.SetUp()
 	if err != nil {
-		fmt.Println("run setup", err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	// - run
 	err = app.Run()
 	if err != nil {
-		fmt.Println("run app", err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
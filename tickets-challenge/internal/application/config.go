@@ -0,0 +1,49 @@
+package application
+
+import (
+	"appconfig"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidConfig represents a configuration the application can not run with
+	ErrInvalidConfig = appconfig.ErrInvalidConfig
+)
+
+// DefaultConfigAppDefault returns the configuration used for the settings that are not given
+func DefaultConfigAppDefault() *ConfigAppDefault {
+	return &ConfigAppDefault{
+		ServerAddr: ":8080",
+		DbFile:     "./tickets.csv",
+	}
+}
+
+// configOptions are the settings of ConfigAppDefault
+var configOptions = []appconfig.Option[*ConfigAppDefault]{
+	appconfig.Text("server_addr", "address where the server listens, e.g. :8080", func(cfg *ConfigAppDefault) *string { return &cfg.ServerAddr }),
+	appconfig.Text("db_file", "path to the CSV file of the tickets", func(cfg *ConfigAppDefault) *string { return &cfg.DbFile }),
+}
+
+// LoadConfigAppDefault returns the configuration given by the command line args, the environment
+// variables looked up with lookupEnv and an optional config file, over DefaultConfigAppDefault (see appconfig.Load)
+func LoadConfigAppDefault(args []string, lookupEnv func(key string) (string, bool)) (cfg *ConfigAppDefault, err error) {
+	cfg = DefaultConfigAppDefault()
+	if err = appconfig.Load(cfg, "tickets", configOptions, args, lookupEnv); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Validate returns an error reporting every setting the application can not run with
+func (c *ConfigAppDefault) Validate() (err error) {
+	errs := []error{appconfig.ValidateAddress("server_addr", c.ServerAddr)}
+
+	if c.DbFile == "" {
+		errs = append(errs, fmt.Errorf("%w: db_file: is required", ErrInvalidConfig))
+	} else {
+		errs = append(errs, appconfig.ValidateFile("db_file", c.DbFile))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package application_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"tickets-challenge/internal/application"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a lookup of the variables of vars
+func env(vars map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+// TestLoadConfigAppDefault tests the settings of the configuration, the sources and their precedence are tested by appconfig
+func TestLoadConfigAppDefault(t *testing.T) {
+	// tickets is a file the db file can point to
+	tickets := filepath.Join(t.TempDir(), "tickets.csv")
+	require.NoError(t, os.WriteFile(tickets, nil, 0o644))
+
+	t.Run("settings", func(t *testing.T) {
+		// arrange
+		file := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"server_addr": ":9001"}`), 0o644))
+
+		// act
+		cfg, err := application.LoadConfigAppDefault([]string{"-config", file}, env(map[string]string{"DB_FILE": tickets}))
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, &application.ConfigAppDefault{ServerAddr: ":9001", DbFile: tickets}, cfg)
+	})
+
+	t.Run("every invalid setting is reported", func(t *testing.T) {
+		// arrange
+		args := []string{"-server-addr", "localhost", "-db-file", filepath.Join(t.TempDir(), "missing.csv")}
+
+		// act
+		cfg, err := application.LoadConfigAppDefault(args, env(nil))
+
+		// assert
+		require.Nil(t, cfg)
+		require.ErrorIs(t, err, application.ErrInvalidConfig)
+		require.ErrorContains(t, err, `server_addr: "localhost" must be host:port`)
+		require.ErrorContains(t, err, "db_file: stat")
+	})
+
+	t.Run("db file is required", func(t *testing.T) {
+		// act
+		_, err := application.LoadConfigAppDefault([]string{"-db-file", ""}, env(nil))
+
+		// assert
+		require.ErrorContains(t, err, "db_file: is required")
+	})
+}
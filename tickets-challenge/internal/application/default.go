@@ -22,10 +22,7 @@ type ConfigAppDefault struct {
 func NewApplicationDefault(cfg *ConfigAppDefault) *ApplicationDefault {
 	// default values
 	defaultRouter := chi.NewRouter()
-	defaultConfig := &ConfigAppDefault{
-		ServerAddr: ":8080",
-		DbFile:     "",
-	}
+	defaultConfig := DefaultConfigAppDefault()
 	if cfg != nil {
 		if cfg.ServerAddr != "" {
 			defaultConfig.ServerAddr = cfg.ServerAddr